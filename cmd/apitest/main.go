@@ -11,6 +11,8 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"apitest/internal/config"
+	"apitest/internal/httpx"
+	"apitest/internal/openapi"
 	"apitest/internal/report"
 	"apitest/internal/runner"
 	"apitest/internal/templ"
@@ -37,7 +39,13 @@ func main() {
 	var insecure bool
 	var verbose bool
 	var envFile string
+	var maxResponseSize string
+	var unixSocket string
+	var rate string
+	var specFile string
+	var updateSnapshots bool
 	var vars stringList
+	var resolve stringList
 
 	fs.StringVar(&planFile, "f", "", "Path to plan YAML file")
 	fs.StringVar(&planFile, "file", "", "Path to plan YAML file")
@@ -47,7 +55,13 @@ func main() {
 	fs.BoolVar(&insecure, "insecure", false, "Skip TLS verification")
 	fs.BoolVar(&verbose, "verbose", false, "Verbose execution log")
 	fs.StringVar(&envFile, "env", "", "Additional vars yaml file")
+	fs.StringVar(&maxResponseSize, "max-response-size", "", "In-memory response body limit, e.g. 50MB")
+	fs.StringVar(&unixSocket, "unix-socket", "", "Send requests over this Unix domain socket")
+	fs.BoolVar(&updateSnapshots, "update-snapshots", false, "Rewrite golden files of snapshot assertions")
+	fs.StringVar(&specFile, "openapi", "", "Validate steps against this OpenAPI spec (overrides the plan's openapi)")
+	fs.StringVar(&rate, "rate", "", "Run-wide rate limit, e.g. 10/s (overrides rate_limit.rate)")
 	fs.Var(&vars, "var", "Extra variable k=v (repeatable)")
+	fs.Var(&resolve, "resolve", "Resolve host:port to addr, e.g. api.example.com:443:10.0.0.5 (repeatable)")
 
 	if err := fs.Parse(os.Args[2:]); err != nil {
 		os.Exit(2)
@@ -58,17 +72,64 @@ func main() {
 		os.Exit(2)
 	}
 
-	exitCode := execute(planFile, output, baseURL, insecure, verbose, envFile, vars)
+	var maxBody int64
+	if maxResponseSize != "" {
+		n, err := config.ParseByteSize(maxResponseSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--max-response-size: %v\n", err)
+			os.Exit(2)
+		}
+		maxBody = n
+	}
+
+	if _, err := httpx.ParseResolve(resolve); err != nil {
+		fmt.Fprintf(os.Stderr, "--resolve: %v\n", err)
+		os.Exit(2)
+	}
+
+	var perSecond float64
+	if rate != "" {
+		n, err := config.ParseRate(rate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--rate: %v\n", err)
+			os.Exit(2)
+		}
+		perSecond = n
+	}
+
+	exitCode := execute(planFile, output, baseURL, insecure, verbose, envFile, vars, maxBody, transportOptions{unixSocket: unixSocket, resolve: resolve, rate: perSecond}, specFile, updateSnapshots)
 	os.Exit(exitCode)
 }
 
-func execute(planFile, output, baseURL string, insecure, verbose bool, envFile string, vars []string) int {
+// transportOptions carries the connection-level CLI flags.
+type transportOptions struct {
+	unixSocket string
+	resolve    []string
+	rate       float64
+}
+
+func execute(planFile, output, baseURL string, insecure, verbose bool, envFile string, vars []string, maxResponseSize int64, transport transportOptions, specFile string, updateSnapshots bool) int {
 	plan, err := config.LoadPlan(planFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "load plan: %v\n", err)
 		return 2
 	}
 
+	if specFile == "" && plan.OpenAPI != "" {
+		specFile = plan.OpenAPI
+		if !filepath.IsAbs(specFile) {
+			specFile = filepath.Join(plan.Dir, specFile)
+		}
+	}
+	var spec *openapi.Spec
+	if specFile != "" {
+		spec, err = openapi.Load(specFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "openapi: %v\n", err)
+			return 2
+		}
+	}
+
 	cliVars, err := parseVars(vars)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "vars: %v\n", err)
@@ -87,11 +148,17 @@ func execute(planFile, output, baseURL string, insecure, verbose bool, envFile s
 	allVars := templ.MergeContexts(envVars, cliVars)
 
 	res := runner.Execute(plan, runner.RunnerOptions{
-		BaseURL:  baseURL,
-		Vars:     allVars,
-		Insecure: insecure,
-		Verbose:  verbose,
-		Progress: printProgress,
+		BaseURL:         baseURL,
+		Vars:            allVars,
+		Insecure:        insecure,
+		Verbose:         verbose,
+		Progress:        printProgress,
+		MaxResponseSize: maxResponseSize,
+		UnixSocket:      transport.unixSocket,
+		Resolve:         transport.resolve,
+		Rate:            transport.rate,
+		OpenAPI:         spec,
+		UpdateSnapshots: updateSnapshots,
 	})
 
 	if err := ensureDir(output); err != nil {
@@ -0,0 +1,202 @@
+package assert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"apitest/internal/config"
+)
+
+// assertArray implements the json ops that work on a whole array: length
+// checks, per-element sub-assertions, membership, uniqueness and ordering.
+func assertArray(a config.Assertion, res gjson.Result, ctx map[string]string) Result {
+	label := a.Path
+	if label == "" {
+		label = "(root)"
+	}
+	items, ok := res.Value().([]interface{})
+	if !ok {
+		if !res.Exists() {
+			return Result{Pass: false, Message: fmt.Sprintf("json path %s not found", label)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s is not an array", label)}
+	}
+	switch a.Op {
+	case "len_eq", "len_gt", "len_lt":
+		expect, err := applyExpectTemplates(a.Expect, ctx)
+		if err != nil {
+			return invalidf("expect template: %v", err)
+		}
+		n, ok := jsonNumber(expect)
+		if !ok {
+			return invalidf("%s expects a number, got %v", a.Op, a.Expect)
+		}
+		ops := map[string]string{"len_eq": "==", "len_gt": ">", "len_lt": "<"}
+		if pass, _ := compareOrdered(ops[a.Op], float64(len(items)), n); pass {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s length %s %v", label, ops[a.Op], n)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s length %d not %s %v", label, len(items), ops[a.Op], n)}
+	case "every", "any", "none":
+		if len(a.Assert) == 0 {
+			return invalidf("%s needs nested assert entries", a.Op)
+		}
+		return matchElements(a, label, items, ctx)
+	case "contains_item":
+		expect, err := applyExpectTemplates(a.Expect, ctx)
+		if err != nil {
+			return invalidf("expect template: %v", err)
+		}
+		for _, item := range items {
+			var diffs []string
+			structureDiff("", expect, item, true, &diffs)
+			if len(diffs) == 0 {
+				return Result{Pass: true, Message: fmt.Sprintf("json %s contains item %s", label, compactJSON(expect))}
+			}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s has no item matching %s among %d item(s)", label, compactJSON(expect), len(items))}
+	case "unique_by":
+		seen := map[string]int{}
+		var details []string
+		for i, item := range items {
+			key, ok := elementKey(item, a.By)
+			if !ok {
+				details = append(details, fmt.Sprintf("%s: missing %s", joinPath(a.Path, strconv.Itoa(i)), a.By))
+				continue
+			}
+			id := compactJSON(key)
+			if first, dup := seen[id]; dup {
+				details = append(details, fmt.Sprintf("%s: duplicate %s, same as item %d", joinPath(a.Path, strconv.Itoa(i)), id, first))
+				continue
+			}
+			seen[id] = i
+		}
+		if len(details) == 0 {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s items unique by %s", label, byLabel(a.By))}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s items not unique by %s: %s", label, byLabel(a.By), details[0]), Details: details}
+	case "sorted_by":
+		order := strings.ToLower(strings.TrimSpace(fmt.Sprint(a.Expect)))
+		if a.Expect == nil || order == "" {
+			order = "asc"
+		}
+		if order != "asc" && order != "desc" {
+			return invalidf("sorted_by expects asc or desc, got %s", order)
+		}
+		for i := 1; i < len(items); i++ {
+			prev, ok1 := elementKey(items[i-1], a.By)
+			cur, ok2 := elementKey(items[i], a.By)
+			if !ok1 || !ok2 {
+				return Result{Pass: false, Message: fmt.Sprintf("json %s item %d or %d has no %s", label, i-1, i, byLabel(a.By))}
+			}
+			cmp, ok := compareKeys(prev, cur)
+			if !ok {
+				return Result{Pass: false, Message: fmt.Sprintf("json %s items %d and %d are not comparable: %s vs %s", label, i-1, i, compactJSON(prev), compactJSON(cur))}
+			}
+			if (order == "asc" && cmp > 0) || (order == "desc" && cmp < 0) {
+				return Result{Pass: false, Message: fmt.Sprintf("json %s not sorted by %s %s: item %d %s comes before item %d %s", label, byLabel(a.By), order, i-1, compactJSON(prev), i, compactJSON(cur))}
+			}
+		}
+		return Result{Pass: true, Message: fmt.Sprintf("json %s sorted by %s %s", label, byLabel(a.By), order)}
+	}
+	return invalidf("unknown json op %s", a.Op)
+}
+
+// matchElements runs the nested assertions against every element, using the
+// element as the JSON body so paths are relative to it.
+func matchElements(a config.Assertion, label string, items []interface{}, ctx map[string]string) Result {
+	var matched []int
+	var details []string
+	for i, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return Result{Pass: false, Message: fmt.Sprintf("json %s item %d: %v", label, i, err)}
+		}
+		failure := ""
+		for _, r := range Evaluate(a.Assert, string(data), nil, 0, ctx) {
+			if r.Invalid {
+				// a broken nested check must not count as a non-match for none
+				return invalidf("json %s item %d: %s", label, i, r.Message)
+			}
+			if !r.Pass {
+				failure = r.Message
+				break
+			}
+		}
+		at := joinPath(a.Path, strconv.Itoa(i))
+		if failure == "" {
+			matched = append(matched, i)
+			if a.Op == "none" {
+				details = append(details, fmt.Sprintf("%s: matches", at))
+			}
+			continue
+		}
+		if a.Op == "every" {
+			details = append(details, fmt.Sprintf("%s: %s", at, failure))
+		}
+	}
+	switch a.Op {
+	case "every":
+		if len(details) == 0 {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s: all %d item(s) match", label, len(items))}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s: %d of %d item(s) do not match, first %s", label, len(details), len(items), details[0]), Details: details}
+	case "any":
+		if len(matched) > 0 {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s: item %d matches", label, matched[0])}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s: none of %d item(s) match", label, len(items))}
+	default:
+		if len(matched) == 0 {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s: no item matches", label)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s: %d item(s) match, first item %d", label, len(matched), matched[0]), Details: details}
+	}
+}
+
+// elementKey reads the by path from an array element.
+func elementKey(item interface{}, by string) (interface{}, bool) {
+	if by == "" {
+		return item, item != nil
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, false
+	}
+	res := gjson.Get(string(data), by)
+	return res.Value(), res.Exists()
+}
+
+// compareKeys orders two numbers or two strings; strings compare bytewise,
+// which also orders ISO dates and zero-padded timestamps.
+func compareKeys(a, b interface{}) (int, bool) {
+	if x, ok := jsonNumber(a); ok {
+		y, ok := jsonNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok1 := a.(string)
+	y, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+func byLabel(by string) string {
+	if by == "" {
+		return "value"
+	}
+	return by
+}
@@ -2,96 +2,316 @@ package assert
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tidwall/gjson"
 
 	"apitest/internal/config"
+	"apitest/internal/httpx"
+	"apitest/internal/schema"
 	"apitest/internal/templ"
+	"apitest/internal/xpath"
 )
 
 // Result describes single assertion result.
 type Result struct {
 	Pass    bool
 	Message string
+	// Details lists individual problems behind a failure, e.g. every schema
+	// violation with its JSON pointer.
+	Details []string
+	// Diff is a unified diff for failed snapshot comparisons.
+	Diff string
+	// Skipped marks an assertion that was not evaluated because an earlier
+	// one failed; Message then describes the assertion.
+	Skipped bool
+	// Children are the nested results of not, any_of and all_of.
+	Children []Result
+	// Invalid marks a failure caused by the assertion itself (unknown op,
+	// bad regex, unusable expect) rather than by the response.
+	Invalid bool
+}
+
+// invalidf reports a misconfigured assertion. It fails like any other
+// result, and not, any_of and all_of pass it through instead of treating it
+// as an ordinary failure.
+func invalidf(format string, args ...interface{}) Result {
+	return Result{Pass: false, Invalid: true, Message: fmt.Sprintf(format, args...)}
 }
 
 // Evaluate executes assertions against response.
 func Evaluate(assertions []config.Assertion, respBody string, headers http.Header, status int, ctx map[string]string) []Result {
+	return EvaluateResponse(assertions, httpx.ResponseInfo{StatusCode: status, Headers: headers, Body: respBody}, ctx)
+}
+
+// EvaluateResponse executes assertions against the full response, including
+// timing data. It stops at the first failure; the remaining assertions are
+// returned as skipped.
+func EvaluateResponse(assertions []config.Assertion, resp httpx.ResponseInfo, ctx map[string]string) []Result {
 	results := make([]Result, 0, len(assertions))
-	for _, a := range assertions {
-		r := evaluateOne(a, respBody, headers, status, ctx)
+	for i, a := range assertions {
+		r := evaluateOne(a, resp, ctx)
 		results = append(results, r)
 		if !r.Pass {
+			for _, rest := range assertions[i+1:] {
+				results = append(results, Result{Skipped: true, Message: Describe(rest)})
+			}
 			break
 		}
 	}
 	return results
 }
 
-func evaluateOne(a config.Assertion, body string, headers http.Header, status int, ctx map[string]string) Result {
+// EvaluateSoft executes every assertion regardless of earlier failures.
+func EvaluateSoft(assertions []config.Assertion, resp httpx.ResponseInfo, ctx map[string]string) []Result {
+	results := make([]Result, 0, len(assertions))
+	for _, a := range assertions {
+		results = append(results, evaluateOne(a, resp, ctx))
+	}
+	return results
+}
+
+// Describe renders an assertion as "type path op expect" for reports.
+func Describe(a config.Assertion) string {
+	switch {
+	case a.Not != nil:
+		return "not (" + Describe(*a.Not) + ")"
+	case len(a.AnyOf) > 0, len(a.AllOf) > 0:
+		name, nested := "any_of", a.AnyOf
+		if len(a.AnyOf) == 0 {
+			name, nested = "all_of", a.AllOf
+		}
+		items := make([]string, len(nested))
+		for i, n := range nested {
+			items[i] = Describe(n)
+		}
+		return name + " (" + strings.Join(items, "; ") + ")"
+	}
+	parts := []string{strings.ToLower(a.Type)}
+	if a.Path != "" {
+		parts = append(parts, a.Path)
+	} else if a.Name != "" {
+		parts = append(parts, a.Name)
+	}
+	if a.Op != "" {
+		parts = append(parts, a.Op)
+	}
+	if a.Expect != nil {
+		parts = append(parts, compactJSON(a.Expect))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Failures returns the results that were evaluated and failed.
+func Failures(results []Result) []Result {
+	var out []Result
+	for _, r := range results {
+		if !r.Pass && !r.Skipped {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func evaluateOne(a config.Assertion, resp httpx.ResponseInfo, ctx map[string]string) Result {
+	if isWrapper(a) {
+		return evaluateWrapper(a, resp, ctx)
+	}
 	switch strings.ToLower(a.Type) {
 	case "status":
-		return assertStatus(a, status)
+		return assertStatus(a, resp.StatusCode)
 	case "header":
-		return assertHeader(a, headers)
+		return assertHeader(a, resp.Headers, ctx)
 	case "body":
-		return assertBody(a, body)
+		return assertBody(a, resp)
 	case "json":
-		return assertJSON(a, body, ctx)
+		if resp.BodyTruncated && !gjson.Valid(resp.Body) {
+			return Result{Pass: false, Message: fmt.Sprintf("response body truncated to %d of %d bytes; raise max_response_size", len(resp.Body), resp.BodySize)}
+		}
+		return assertJSON(a, resp.Body, ctx)
+	case "xml":
+		if resp.BodyTruncated && !xpath.Valid(resp.Body) {
+			return Result{Pass: false, Message: fmt.Sprintf("response body truncated to %d of %d bytes; raise max_response_size", len(resp.Body), resp.BodySize)}
+		}
+		return assertXML(a, resp.Body, ctx)
+	case "schema":
+		if resp.BodyTruncated && !gjson.Valid(resp.Body) {
+			return Result{Pass: false, Message: fmt.Sprintf("response body truncated to %d of %d bytes; raise max_response_size", len(resp.Body), resp.BodySize)}
+		}
+		return assertSchema(a, resp.Body)
+	case "snapshot":
+		return assertSnapshot(a, resp)
+	case "timing":
+		return assertTiming(a, resp.Timing)
+	case "latency":
+		return assertLatency(a, resp.Duration)
+	case "size":
+		return assertSize(a, resp)
+	case "encoding":
+		return assertEncoding(a, resp)
 	default:
-		return Result{Pass: false, Message: fmt.Sprintf("unknown assertion type %s", a.Type)}
+		return invalidf("unknown assertion type %s", a.Type)
 	}
 }
 
 func assertStatus(a config.Assertion, status int) Result {
-	expect := toFloat(a.Expect)
-	switch a.Op {
-	case "==":
-		if float64(status) == expect {
-			return Result{Pass: true, Message: fmt.Sprintf("status == %v", expect)}
+	op := a.Op
+	if op == "" {
+		// expect_status and bare status checks: a list means any of
+		op = "=="
+		if _, ok := a.Expect.([]interface{}); ok {
+			op = "in"
 		}
-		return Result{Pass: false, Message: fmt.Sprintf("status %d != %v", status, expect)}
-	case "!=":
-		if float64(status) != expect {
-			return Result{Pass: true, Message: fmt.Sprintf("status != %v", expect)}
+	}
+	switch op {
+	case "==", "!=":
+		match, err := statusMatches(a.Expect, status)
+		if err != nil {
+			return invalidf("%v", err)
 		}
-		return Result{Pass: false, Message: fmt.Sprintf("status %d == %v", status, expect)}
-	case ">=":
-		if float64(status) >= expect {
-			return Result{Pass: true, Message: fmt.Sprintf("status >= %v", expect)}
+		if match == (op == "==") {
+			return Result{Pass: true, Message: fmt.Sprintf("status %s %v", op, a.Expect)}
 		}
-		return Result{Pass: false, Message: fmt.Sprintf("status %d < %v", status, expect)}
-	case "<=":
-		if float64(status) <= expect {
-			return Result{Pass: true, Message: fmt.Sprintf("status <= %v", expect)}
+		return Result{Pass: false, Message: fmt.Sprintf("status %d %s %v", status, negatedOps[op], a.Expect)}
+	case "in", "not_in":
+		list, ok := a.Expect.([]interface{})
+		if !ok {
+			return invalidf("status %s expects a list, got %v", op, a.Expect)
+		}
+		found := false
+		for _, item := range list {
+			match, err := statusMatches(item, status)
+			if err != nil {
+				return invalidf("%v", err)
+			}
+			if match {
+				found = true
+				break
+			}
+		}
+		if found == (op == "in") {
+			return Result{Pass: true, Message: fmt.Sprintf("status %d %s %v", status, op, list)}
+		}
+		if op == "in" {
+			return Result{Pass: false, Message: fmt.Sprintf("status %d not in %v", status, list)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("status %d in %v", status, list)}
+	case "between":
+		list, ok := a.Expect.([]interface{})
+		if !ok || len(list) != 2 {
+			return invalidf("status between expects [low, high], got %v", a.Expect)
+		}
+		low, err := statusBound(list[0], false)
+		if err != nil {
+			return invalidf("%v", err)
 		}
-		return Result{Pass: false, Message: fmt.Sprintf("status %d > %v", status, expect)}
+		high, err := statusBound(list[1], true)
+		if err != nil {
+			return invalidf("%v", err)
+		}
+		if status >= low && status <= high {
+			return Result{Pass: true, Message: fmt.Sprintf("status %d between %v and %v", status, list[0], list[1])}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("status %d not between %v and %v", status, list[0], list[1])}
+	case ">=", "<=", ">", "<", "gt", "lt":
+		// a class bound means its last code for > and <=, its first otherwise
+		symbol := orderedOps[op]
+		bound, err := statusBound(a.Expect, symbol == ">" || symbol == "<=")
+		if err != nil {
+			return invalidf("%v", err)
+		}
+		pass, _ := compareOrdered(op, float64(status), float64(bound))
+		if pass {
+			return Result{Pass: true, Message: fmt.Sprintf("status %s %v", op, a.Expect)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("status %d %s %v", status, negatedOps[symbol], a.Expect)}
 	default:
-		return Result{Pass: false, Message: fmt.Sprintf("unknown status op %s", a.Op)}
+		return invalidf("unknown status op %s", a.Op)
+	}
+}
+
+// statusMatches compares status with a code (200, "200") or a class such
+// as "2xx".
+func statusMatches(expect interface{}, status int) (bool, error) {
+	if s, ok := expect.(string); ok {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+			return status/100 == int(s[0]-'0'), nil
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil {
+			return false, fmt.Errorf("invalid status %q, want a code or a class like 2xx", s)
+		}
+		return status == code, nil
+	}
+	code, ok := expectNumber(expect)
+	if !ok {
+		return false, fmt.Errorf("invalid status %v, want a code or a class like 2xx", expect)
 	}
+	return float64(status) == code, nil
 }
 
-func assertHeader(a config.Assertion, headers http.Header) Result {
+// statusBound reads a code or class used as a range bound; a class stands
+// for its first code, or its last when upper is set (2xx is 200 or 299).
+func statusBound(v interface{}, upper bool) (int, error) {
+	if s, ok := v.(string); ok {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+			first := int(s[0]-'0') * 100
+			if upper {
+				return first + 99, nil
+			}
+			return first, nil
+		}
+	}
+	code, ok := expectNumber(v)
+	if !ok || code != math.Trunc(code) {
+		return 0, fmt.Errorf("invalid status bound %v, want a code or a class like 2xx", v)
+	}
+	return int(code), nil
+}
+
+func assertHeader(a config.Assertion, headers http.Header, ctx map[string]string) Result {
 	name := a.Path
 	if name == "" {
 		name = a.Name
 	}
 	if name == "" {
-		return Result{Pass: false, Message: "header name missing"}
+		return invalidf("header name missing")
 	}
 	values := headers.Values(name)
-	switch a.Op {
-	case "exists":
+	if sharedOps[a.Op] {
+		expect, err := applyExpectTemplates(a.Expect, ctx)
+		if err != nil {
+			return invalidf("expect template: %v", err)
+		}
+		if len(values) == 0 {
+			return Result{Pass: false, Message: fmt.Sprintf("header %s not found", name)}
+		}
+		return compareScalar("header", name, a.Op, textScalar(values[0]), expect, a.Tolerance)
+	}
+	if a.Op == "exists" {
 		if len(values) > 0 {
 			return Result{Pass: true, Message: fmt.Sprintf("header %s exists", name)}
 		}
 		return Result{Pass: false, Message: fmt.Sprintf("header %s not found", name)}
+	}
+	expect := fmt.Sprint(a.Expect)
+	if strings.Contains(expect, "{{") {
+		replaced, err := templ.ApplyString(expect, ctx)
+		if err != nil {
+			return invalidf("expect template: %v", err)
+		}
+		expect = replaced
+	}
+	switch a.Op {
 	case "contains":
-		expect := fmt.Sprint(a.Expect)
 		for _, v := range values {
 			if strings.Contains(v, expect) {
 				return Result{Pass: true, Message: fmt.Sprintf("header %s contains %s", name, expect)}
@@ -99,23 +319,22 @@ func assertHeader(a config.Assertion, headers http.Header) Result {
 		}
 		return Result{Pass: false, Message: fmt.Sprintf("header %s does not contain %s", name, expect)}
 	case "==":
-		expect := fmt.Sprint(a.Expect)
 		if len(values) > 0 && values[0] == expect {
 			return Result{Pass: true, Message: fmt.Sprintf("header %s == %s", name, expect)}
 		}
 		return Result{Pass: false, Message: fmt.Sprintf("header %s value %v != %s", name, values, expect)}
 	case "!=":
-		expect := fmt.Sprint(a.Expect)
 		if len(values) == 0 || values[0] != expect {
 			return Result{Pass: true, Message: fmt.Sprintf("header %s != %s", name, expect)}
 		}
 		return Result{Pass: false, Message: fmt.Sprintf("header %s equals %s", name, expect)}
 	default:
-		return Result{Pass: false, Message: fmt.Sprintf("unknown header op %s", a.Op)}
+		return invalidf("unknown header op %s", a.Op)
 	}
 }
 
-func assertBody(a config.Assertion, body string) Result {
+func assertBody(a config.Assertion, resp httpx.ResponseInfo) Result {
+	body := resp.Body
 	switch a.Op {
 	case "contains":
 		expect := fmt.Sprint(a.Expect)
@@ -123,11 +342,17 @@ func assertBody(a config.Assertion, body string) Result {
 			return Result{Pass: true, Message: fmt.Sprintf("body contains %s", expect)}
 		}
 		return Result{Pass: false, Message: fmt.Sprintf("body does not contain %s", expect)}
+	case "not_contains":
+		expect := fmt.Sprint(a.Expect)
+		if !strings.Contains(body, expect) {
+			return Result{Pass: true, Message: fmt.Sprintf("body does not contain %s", expect)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("body contains %s", expect)}
 	case "regex":
 		pattern := fmt.Sprint(a.Expect)
 		re, err := regexp.Compile(pattern)
 		if err != nil {
-			return Result{Pass: false, Message: fmt.Sprintf("invalid regex: %v", err)}
+			return invalidf("invalid regex: %v", err)
 		}
 		if re.MatchString(body) {
 			return Result{Pass: true, Message: fmt.Sprintf("body matches %s", pattern)}
@@ -145,8 +370,25 @@ func assertBody(a config.Assertion, body string) Result {
 			return Result{Pass: true, Message: fmt.Sprintf("body length == %d", expect)}
 		}
 		return Result{Pass: false, Message: fmt.Sprintf("body length %d != %d", len(body), expect)}
+	case "size_eq", "size_gt", "size_lt":
+		expect, err := toByteSize(a.Expect)
+		if err != nil {
+			return invalidf("%v", err)
+		}
+		ops := map[string]string{"size_eq": "==", "size_gt": ">", "size_lt": "<"}
+		pass, _ := compareOrdered(ops[a.Op], float64(resp.BodySize), float64(expect))
+		if pass {
+			return Result{Pass: true, Message: fmt.Sprintf("body size %s %d", ops[a.Op], expect)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("body size %d not %s %d", resp.BodySize, ops[a.Op], expect)}
+	case "sha256":
+		expect := strings.ToLower(strings.TrimSpace(fmt.Sprint(a.Expect)))
+		if resp.BodySHA256 == expect {
+			return Result{Pass: true, Message: fmt.Sprintf("body sha256 == %s", expect)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("body sha256 %s != %s", resp.BodySHA256, expect)}
 	default:
-		return Result{Pass: false, Message: fmt.Sprintf("unknown body op %s", a.Op)}
+		return invalidf("unknown body op %s", a.Op)
 	}
 }
 
@@ -154,124 +396,324 @@ func assertJSON(a config.Assertion, body string, ctx map[string]string) Result {
 	if !gjson.Valid(body) {
 		return Result{Pass: false, Message: "response body is not valid JSON"}
 	}
+	if a.Coerce != "" && a.Coerce != "number" {
+		return invalidf("unknown coerce %s, want number", a.Coerce)
+	}
 	res := gjson.Parse(body).Get(a.Path)
+	if a.Coerce != "" && a.Op != "exists" {
+		coerced, err := coerceJSON(a.Coerce, a.Path, res)
+		if err != nil {
+			return Result{Pass: false, Message: err.Error()}
+		}
+		res = coerced
+	}
+	if sharedOps[a.Op] {
+		expect, err := applyExpectTemplates(a.Expect, ctx)
+		if err != nil {
+			return invalidf("expect template: %v", err)
+		}
+		if !res.Exists() {
+			return Result{Pass: false, Message: fmt.Sprintf("json path %s does not exist", a.Path)}
+		}
+		return compareScalar("json", a.Path, a.Op, scalar{text: res.String(), num: res.Num, isNum: res.Type == gjson.Number}, expect, a.Tolerance)
+	}
 	switch a.Op {
 	case "exists":
 		if res.Exists() && res.Value() != nil {
 			return Result{Pass: true, Message: fmt.Sprintf("json %s exists", a.Path)}
 		}
 		return Result{Pass: false, Message: fmt.Sprintf("json path %s does not exist", a.Path)}
-	case "==", "!=", "contains", "gt", "lt":
-		var expectVal interface{} = a.Expect
-		if str, ok := a.Expect.(string); ok {
-			if strings.Contains(str, "{{") {
-				replaced, err := templ.ApplyString(str, ctx)
-				if err != nil {
-					return Result{Pass: false, Message: fmt.Sprintf("expect template: %v", err)}
-				}
-				expectVal = replaced
+	case "==", "!=", "contains":
+		// templates stay text here, so an extracted "42" still equals a string field
+		expect := a.Expect
+		if str, ok := a.Expect.(string); ok && strings.Contains(str, "{{") {
+			replaced, err := templ.ApplyString(str, ctx)
+			if err != nil {
+				return invalidf("expect template: %v", err)
 			}
+			expect = replaced
 		}
-		return compareJSON(a.Op, a.Path, res, expectVal)
+		return compareJSON(a.Op, a.Path, res, expect)
+	case "equals_json", "matches_json":
+		expect, err := applyExpectTemplates(a.Expect, ctx)
+		if err != nil {
+			return invalidf("expect template: %v", err)
+		}
+		return compareStructure(a.Op, a.Path, res, expect)
+	case "type", "is_null", "not_empty", "format":
+		return assertValue(a, res)
+	case "before", "after", "within", "tz":
+		return assertTime(a, res, ctx)
+	case "len_eq", "len_gt", "len_lt", "every", "any", "none", "contains_item", "unique_by", "sorted_by":
+		return assertArray(a, res, ctx)
 	default:
-		return Result{Pass: false, Message: fmt.Sprintf("unknown json op %s", a.Op)}
+		return invalidf("unknown json op %s", a.Op)
 	}
 }
 
-func compareJSON(op, path string, val gjson.Result, expect interface{}) Result {
+// assertXML mirrors assertJSON with an XPath expression in path.
+func assertXML(a config.Assertion, body string, ctx map[string]string) Result {
+	root, err := xpath.Parse(body)
+	if err != nil {
+		return Result{Pass: false, Message: fmt.Sprintf("response body is not valid XML: %v", err)}
+	}
+	res, err := xpath.Eval(root, a.Path, a.Namespaces)
+	if err != nil {
+		return invalidf("%v", err)
+	}
+	switch a.Op {
+	case "exists":
+		if res.Exists() {
+			return Result{Pass: true, Message: fmt.Sprintf("xml %s exists", a.Path)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("xml path %s does not exist", a.Path)}
+	case "==", "!=", "contains":
+		expect := a.Expect
+		if str, ok := a.Expect.(string); ok && strings.Contains(str, "{{") {
+			replaced, err := templ.ApplyString(str, ctx)
+			if err != nil {
+				return invalidf("expect template: %v", err)
+			}
+			expect = replaced
+		}
+		return compareXML(a.Op, a.Path, res, expect)
+	default:
+		if sharedOps[a.Op] {
+			expect, err := applyExpectTemplates(a.Expect, ctx)
+			if err != nil {
+				return invalidf("expect template: %v", err)
+			}
+			if !res.Exists() {
+				return Result{Pass: false, Message: fmt.Sprintf("xml path %s not found", a.Path)}
+			}
+			return compareScalar("xml", a.Path, a.Op, textScalar(res.String()), expect, a.Tolerance)
+		}
+		return invalidf("unknown xml op %s", a.Op)
+	}
+}
+
+func compareXML(op, path string, res xpath.Result, expect interface{}) Result {
+	if !res.Exists() {
+		return Result{Pass: false, Message: fmt.Sprintf("xml path %s not found", path)}
+	}
+	actual := res.String()
 	if op == "contains" {
 		expStr := fmt.Sprint(expect)
-		actual := val.String()
 		if strings.Contains(actual, expStr) {
-			return Result{Pass: true, Message: fmt.Sprintf("json %s contains %s", path, expStr)}
+			return Result{Pass: true, Message: fmt.Sprintf("xml %s contains %s", path, expStr)}
 		}
-		return Result{Pass: false, Message: fmt.Sprintf("json %s value %s does not contain %s", path, actual, expStr)}
+		return Result{Pass: false, Message: fmt.Sprintf("xml %s value %s does not contain %s", path, actual, expStr)}
 	}
+	return compareScalar("xml", path, op, textScalar(actual), expect, nil)
+}
 
-	if op == "gt" || op == "lt" {
-		if !val.Exists() {
-			return Result{Pass: false, Message: fmt.Sprintf("json path %s does not exist", path)}
-		}
-		if val.Type != gjson.Number {
-			return Result{Pass: false, Message: fmt.Sprintf("json %s not a number", path)}
-		}
-		actualNum := val.Num()
-		expNum := toFloat(expect)
-		if op == "gt" {
-			if actualNum > expNum {
-				return Result{Pass: true, Message: fmt.Sprintf("json %s %f > %f", path, actualNum, expNum)}
-			}
-			return Result{Pass: false, Message: fmt.Sprintf("json %s %f <= %f", path, actualNum, expNum)}
+// assertSchema validates the body, or the sub-tree selected by path, against
+// an inline schema or schema_file and reports every violation.
+func assertSchema(a config.Assertion, body string) Result {
+	var s *schema.Schema
+	name := "inline schema"
+	switch {
+	case a.SchemaFile != "":
+		file := a.SchemaFile
+		if !filepath.IsAbs(file) && a.BaseDir != "" {
+			file = filepath.Join(a.BaseDir, file)
 		}
-		if actualNum < expNum {
-			return Result{Pass: true, Message: fmt.Sprintf("json %s %f < %f", path, actualNum, expNum)}
+		var err error
+		if s, err = schema.Load(file); err != nil {
+			return invalidf("%v", err)
+		}
+		name = a.SchemaFile
+	case a.Schema != nil:
+		s = schema.New(a.Schema, a.BaseDir)
+	default:
+		return invalidf("schema assertion needs schema or schema_file")
+	}
+	if !gjson.Valid(body) {
+		return Result{Pass: false, Message: "response body is not valid JSON"}
+	}
+	target, what := body, "body"
+	if a.Path != "" {
+		res := gjson.Get(body, a.Path)
+		if !res.Exists() {
+			return Result{Pass: false, Message: fmt.Sprintf("json path %s does not exist", a.Path)}
 		}
-		return Result{Pass: false, Message: fmt.Sprintf("json %s %f >= %f", path, actualNum, expNum)}
+		target, what = res.Raw, "json "+a.Path
+	}
+	doc, err := schema.DecodeJSON(target)
+	if err != nil {
+		return Result{Pass: false, Message: fmt.Sprintf("decode %s: %v", what, err)}
+	}
+	violations := s.Validate(doc)
+	if len(violations) == 0 {
+		return Result{Pass: true, Message: fmt.Sprintf("%s matches %s", what, name)}
 	}
+	details := make([]string, 0, len(violations))
+	for _, v := range violations {
+		details = append(details, v.String())
+	}
+	return Result{
+		Pass:    false,
+		Message: fmt.Sprintf("%s does not match %s: %d violation(s), first %s", what, name, len(violations), details[0]),
+		Details: details,
+	}
+}
 
-	// equality/inequality
-	actual := val.Value()
-	if actual == nil {
-		return Result{Pass: false, Message: fmt.Sprintf("json path %s not found", path)}
+func assertTiming(a config.Assertion, timing httpx.Timing) Result {
+	metric := a.Path
+	if metric == "" {
+		metric = a.Name
+	}
+	if metric == "" {
+		metric = "total"
+	}
+	actual, ok := timing.Metric(metric)
+	if !ok {
+		return invalidf("unknown timing metric %s", metric)
 	}
+	return compareScalar("timing", metric, a.Op, durationScalar(actual), a.Expect, a.Tolerance)
+}
 
-	// type-aware comparison
-	switch exp := expect.(type) {
-	case bool:
-		if val.Type != gjson.True && val.Type != gjson.False {
-			return Result{Pass: false, Message: fmt.Sprintf("json %s not a bool", path)}
-		}
-		actBool := val.Bool()
-		pass := (op == "==" && actBool == exp) || (op == "!=" && actBool != exp)
-		if pass {
-			return Result{Pass: true, Message: fmt.Sprintf("json %s bool comparison pass", path)}
-		}
-		return Result{Pass: false, Message: fmt.Sprintf("json %s bool comparison fail (expect %v, got %v)", path, exp, actBool)}
-	case int:
-		if val.Type != gjson.Number {
-			return Result{Pass: false, Message: fmt.Sprintf("json %s not a number", path)}
-		}
-		return compareNumbers(op, path, val.Num(), float64(exp))
-	case int64:
-		if val.Type != gjson.Number {
-			return Result{Pass: false, Message: fmt.Sprintf("json %s not a number", path)}
+// assertLatency compares the response time of the whole request with a
+// duration such as "500ms".
+func assertLatency(a config.Assertion, actual time.Duration) Result {
+	return compareScalar("latency", "", a.Op, durationScalar(actual), a.Expect, a.Tolerance)
+}
+
+// assertSize checks the body size in bytes (path body, the default) or the
+// number of response headers (path headers).
+func assertSize(a config.Assertion, resp httpx.ResponseInfo) Result {
+	metric := strings.ToLower(a.Path)
+	if metric == "" {
+		metric = strings.ToLower(a.Name)
+	}
+	var actual scalar
+	switch metric {
+	case "", "body":
+		metric = "body"
+		actual = countScalar(float64(resp.BodySize), byteUnit)
+	case "headers":
+		actual = countScalar(float64(len(resp.Headers)), nil)
+	default:
+		return invalidf("unknown size metric %s", metric)
+	}
+	return compareScalar("size", metric, a.Op, actual, a.Expect, a.Tolerance)
+}
+
+// assertEncoding checks the negotiated Content-Encoding, or with path set one of
+// encoded_size, size and ratio (encoded / decoded bytes).
+func assertEncoding(a config.Assertion, resp httpx.ResponseInfo) Result {
+	metric := strings.ToLower(a.Path)
+	if metric == "" {
+		metric = strings.ToLower(a.Name)
+	}
+	switch metric {
+	case "", "content-encoding":
+		actual := resp.ContentEncoding
+		if actual == "" {
+			actual = "identity"
 		}
-		return compareNumbers(op, path, val.Num(), float64(exp))
-	case float64:
-		if val.Type != gjson.Number {
-			return Result{Pass: false, Message: fmt.Sprintf("json %s not a number", path)}
+		expect := strings.ToLower(fmt.Sprint(a.Expect))
+		switch a.Op {
+		case "==":
+			if actual == expect {
+				return Result{Pass: true, Message: fmt.Sprintf("content encoding == %s", expect)}
+			}
+			return Result{Pass: false, Message: fmt.Sprintf("content encoding %s != %s", actual, expect)}
+		case "!=":
+			if actual != expect {
+				return Result{Pass: true, Message: fmt.Sprintf("content encoding != %s", expect)}
+			}
+			return Result{Pass: false, Message: fmt.Sprintf("content encoding is %s", actual)}
+		default:
+			return invalidf("unknown encoding op %s", a.Op)
 		}
-		return compareNumbers(op, path, val.Num(), exp)
-	case string:
-		actualStr := val.String()
-		pass := (op == "==" && actualStr == exp) || (op == "!=" && actualStr != exp)
-		if pass {
-			return Result{Pass: true, Message: fmt.Sprintf("json %s string comparison pass", path)}
+	case "encoded_size", "compressed_size", "size", "decoded_size", "ratio":
+		var actual scalar
+		switch metric {
+		case "encoded_size", "compressed_size":
+			actual = countScalar(float64(resp.EncodedSize), byteUnit)
+		case "size", "decoded_size":
+			actual = countScalar(float64(resp.BodySize), byteUnit)
+		default:
+			var ratio float64
+			if resp.BodySize > 0 {
+				ratio = float64(resp.EncodedSize) / float64(resp.BodySize)
+			}
+			actual = countScalar(ratio, nil)
 		}
-		return Result{Pass: false, Message: fmt.Sprintf("json %s string comparison fail (expect %s, got %s)", path, exp, actualStr)}
+		return compareScalar("encoding", metric, a.Op, actual, a.Expect, a.Tolerance)
 	default:
-		actualStr := fmt.Sprint(actual)
-		expectStr := fmt.Sprint(expect)
-		pass := (op == "==" && actualStr == expectStr) || (op == "!=" && actualStr != expectStr)
-		if pass {
-			return Result{Pass: true, Message: fmt.Sprintf("json %s stringified comparison pass", path)}
+		return invalidf("unknown encoding metric %s", metric)
+	}
+}
+
+// compareJSON handles contains, == and != on a json value; equality goes
+// through compareScalar, with bools required to be JSON booleans.
+func compareJSON(op, path string, val gjson.Result, expect interface{}) Result {
+	if op == "contains" {
+		expStr := fmt.Sprint(expect)
+		actual := val.String()
+		if strings.Contains(actual, expStr) {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s contains %s", path, expStr)}
 		}
-		return Result{Pass: false, Message: fmt.Sprintf("json %s stringified comparison fail (expect %s, got %s)", path, expectStr, actualStr)}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s value %s does not contain %s", path, actual, expStr)}
+	}
+	if val.Value() == nil {
+		return Result{Pass: false, Message: fmt.Sprintf("json path %s not found", path)}
 	}
+	if _, ok := expect.(bool); ok && val.Type != gjson.True && val.Type != gjson.False {
+		return Result{Pass: false, Message: fmt.Sprintf("json %s not a bool: %s", path, val.Raw)}
+	}
+	return compareScalar("json", path, op, scalar{text: val.String(), num: val.Num, isNum: val.Type == gjson.Number}, expect, nil)
 }
 
-func compareNumbers(op, path string, actual, expect float64) Result {
-	pass := false
+// compareOrdered applies a comparison operator to two numbers; ok is false for unknown ops.
+func compareOrdered(op string, actual, expect float64) (pass bool, ok bool) {
 	switch op {
 	case "==":
-		pass = actual == expect
+		return actual == expect, true
 	case "!=":
-		pass = actual != expect
+		return actual != expect, true
+	case "<", "lt":
+		return actual < expect, true
+	case "<=":
+		return actual <= expect, true
+	case ">", "gt":
+		return actual > expect, true
+	case ">=":
+		return actual >= expect, true
+	default:
+		return false, false
+	}
+}
+
+// toDuration accepts Go duration strings ("200ms") or plain numbers meaning milliseconds.
+func toDuration(v interface{}) (time.Duration, error) {
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(strings.TrimSpace(s)); err == nil {
+			return d, nil
+		}
+	}
+	ms, ok := expectNumber(v)
+	if !ok {
+		if s, isStr := v.(string); isStr {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return 0, fmt.Errorf("invalid duration %v", v)
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+// toByteSize accepts plain numbers or human readable sizes like "50MB".
+func toByteSize(v interface{}) (int64, error) {
+	if s, ok := v.(string); ok {
+		return config.ParseByteSize(s)
 	}
-	if pass {
-		return Result{Pass: true, Message: fmt.Sprintf("json %s number comparison pass", path)}
+	n, ok := jsonNumber(v)
+	if !ok {
+		return 0, fmt.Errorf("invalid size %v", v)
 	}
-	return Result{Pass: false, Message: fmt.Sprintf("json %s number comparison fail (expect %v, got %v)", path, expect, actual)}
+	return int64(n), nil
 }
 
 func toFloat(v interface{}) float64 {
@@ -1,10 +1,16 @@
 package assert
 
 import (
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"apitest/internal/config"
+	"apitest/internal/httpx"
 )
 
 func TestJSONAssertions(t *testing.T) {
@@ -46,3 +52,580 @@ func TestJSONAssertions(t *testing.T) {
 		t.Fatalf("expected gt pass: %v", res[0].Message)
 	}
 }
+
+func TestTimingAssertions(t *testing.T) {
+	resp := httpx.ResponseInfo{StatusCode: 200, Timing: httpx.Timing{TTFB: 120 * time.Millisecond, Total: 300 * time.Millisecond}}
+
+	res := EvaluateResponse([]config.Assertion{{Type: "timing", Path: "ttfb", Op: "<", Expect: "200ms"}}, resp, nil)
+	if !res[0].Pass {
+		t.Fatalf("expected ttfb pass: %v", res[0].Message)
+	}
+
+	// plain numbers are milliseconds
+	res = EvaluateResponse([]config.Assertion{{Type: "timing", Name: "total", Op: "<=", Expect: 250}}, resp, nil)
+	if res[0].Pass {
+		t.Fatalf("expected total fail: %v", res[0].Message)
+	}
+
+	res = EvaluateResponse([]config.Assertion{{Type: "timing", Path: "latency", Op: "<", Expect: "1s"}}, resp, nil)
+	if res[0].Pass {
+		t.Fatalf("expected unknown metric to fail")
+	}
+}
+
+func TestEncodingAssertions(t *testing.T) {
+	resp := httpx.ResponseInfo{ContentEncoding: "gzip", EncodedSize: 300, BodySize: 4096}
+	checks := []config.Assertion{
+		{Type: "encoding", Op: "==", Expect: "gzip"},
+		{Type: "encoding", Path: "encoded_size", Op: "<", Expect: "1KB"},
+		{Type: "encoding", Path: "ratio", Op: "lt", Expect: 0.5},
+	}
+	for _, res := range EvaluateResponse(checks, resp, nil) {
+		if !res.Pass {
+			t.Fatalf("expected encoding pass: %v", res.Message)
+		}
+	}
+
+	res := EvaluateResponse([]config.Assertion{{Type: "encoding", Op: "==", Expect: "gzip"}}, httpx.ResponseInfo{}, nil)
+	if res[0].Pass {
+		t.Fatalf("uncompressed response should not match gzip")
+	}
+}
+
+func TestXMLAssertions(t *testing.T) {
+	body := `<r:result xmlns:r="urn:r"><r:code>0</r:code><r:user id="9"><r:name>bob</r:name></r:user></r:result>`
+	ns := map[string]string{"r": "urn:r"}
+	checks := []config.Assertion{
+		{Type: "xml", Path: "/r:result/r:user/@id", Op: "exists", Namespaces: ns},
+		{Type: "xml", Path: "//code", Op: "==", Expect: 0},
+		{Type: "xml", Path: "//r:name", Op: "==", Expect: "{{who}}", Namespaces: ns},
+		{Type: "xml", Path: "//user/@id", Op: "gt", Expect: 5},
+		{Type: "xml", Path: "count(//user)", Op: "==", Expect: 1},
+	}
+	for _, res := range Evaluate(checks, body, http.Header{}, 200, map[string]string{"who": "bob"}) {
+		if !res.Pass {
+			t.Fatalf("expected xml pass: %v", res.Message)
+		}
+	}
+
+	res := Evaluate([]config.Assertion{{Type: "xml", Path: "//missing", Op: "exists"}}, body, http.Header{}, 200, nil)
+	if res[0].Pass {
+		t.Fatalf("expected missing node to fail")
+	}
+	res = Evaluate([]config.Assertion{{Type: "xml", Path: "//code", Op: "exists"}}, `{"code": 0}`, http.Header{}, 200, nil)
+	if res[0].Pass {
+		t.Fatalf("expected non-xml body to fail")
+	}
+}
+
+func TestSchemaAssertions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "user.json"), []byte(`{"type": "object", "required": ["id", "name"], "properties": {"id": {"type": "integer"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"code": 0, "data": {"id": "7"}}`
+	inline := map[string]interface{}{"type": "object", "required": []interface{}{"code", "data"}}
+	checks := []config.Assertion{
+		{Type: "schema", Schema: inline},
+		{Type: "schema", Path: "data", SchemaFile: "user.json", BaseDir: dir},
+	}
+	res := Evaluate(checks, body, http.Header{}, 200, nil)
+	if len(res) != 2 || !res[0].Pass {
+		t.Fatalf("expected inline schema pass: %v", res)
+	}
+	if res[1].Pass || len(res[1].Details) != 2 {
+		t.Fatalf("expected two violations, got %v", res[1])
+	}
+	if res[1].Details[0] != `(root): missing required property "name"` || res[1].Details[1] != "/id: expected integer, got string" {
+		t.Fatalf("unexpected details %v", res[1].Details)
+	}
+}
+
+func TestLatencyAndSizeAssertions(t *testing.T) {
+	resp := httpx.ResponseInfo{
+		StatusCode: 200,
+		Duration:   320 * time.Millisecond,
+		BodySize:   2048,
+		Headers:    http.Header{"Content-Type": {"application/json"}, "X-Trace": {"a"}},
+	}
+	checks := []config.Assertion{
+		{Type: "latency", Op: "lt", Expect: "500ms"},
+		{Type: "latency", Op: ">=", Expect: 300},
+		{Type: "size", Op: "<=", Expect: "2KB"},
+		{Type: "size", Path: "headers", Op: "==", Expect: 2},
+	}
+	for _, res := range EvaluateResponse(checks, resp, nil) {
+		if !res.Pass {
+			t.Fatalf("expected pass: %v", res.Message)
+		}
+	}
+	res := EvaluateResponse([]config.Assertion{{Type: "latency", Op: "<", Expect: "0.3s"}}, resp, nil)
+	if res[0].Pass || res[0].Message != "latency 320ms not < 300ms" {
+		t.Fatalf("expected latency failure, got %+v", res[0])
+	}
+	res = EvaluateResponse([]config.Assertion{{Type: "size", Path: "trailers", Op: "==", Expect: 0}}, resp, nil)
+	if res[0].Pass {
+		t.Fatalf("expected unknown size metric to fail")
+	}
+
+	resp.Timing = httpx.Timing{TTFB: 120 * time.Millisecond}
+	resp.EncodedSize = 512
+	shared := []config.Assertion{
+		{Type: "latency", Op: "between", Expect: []interface{}{"300ms", "1s"}},
+		{Type: "latency", Op: "approx", Expect: "300ms", Tolerance: "25ms"},
+		{Type: "timing", Path: "ttfb", Op: "in", Expect: []interface{}{100, "120ms"}},
+		{Type: "size", Op: "between", Expect: []interface{}{"1KB", "4KB"}},
+		{Type: "size", Path: "headers", Op: "in", Expect: []interface{}{1, 2}},
+		{Type: "encoding", Path: "ratio", Op: "approx", Expect: 0.25, Tolerance: "1%"},
+	}
+	for _, r := range EvaluateResponse(shared, resp, nil) {
+		if !r.Pass {
+			t.Fatalf("expected shared op pass: %v", r.Message)
+		}
+	}
+	failing := map[string]config.Assertion{
+		"latency 320ms not between 100ms and 300ms":    {Type: "latency", Op: "between", Expect: []interface{}{100, "300ms"}},
+		"timing ttfb 120ms not within ±10ms of 100ms":  {Type: "timing", Path: "ttfb", Op: "approx", Expect: "100ms", Tolerance: 10},
+		"size body 2048 not between 0 and 1024":        {Type: "size", Op: "between", Expect: []interface{}{0, "1KB"}},
+		"latency < expects a number, got soon":         {Type: "latency", Op: "<", Expect: "soon"},
+		"encoding encoded_size value 512 not in [1KB]": {Type: "encoding", Path: "encoded_size", Op: "in", Expect: []interface{}{"1KB"}},
+	}
+	for want, a := range failing {
+		if r := EvaluateResponse([]config.Assertion{a}, resp, nil)[0]; r.Pass || r.Message != want {
+			t.Fatalf("%s: got %+v, want %q", Describe(a), r, want)
+		}
+	}
+}
+
+func TestStructuralJSONAssertions(t *testing.T) {
+	body := `{"data": {"id": 42, "name": "alice", "tags": ["a", "b"], "meta": {"score": 1.50}}}`
+	expect := map[string]interface{}{
+		"meta": map[string]interface{}{"score": 1.5},
+		"tags": []interface{}{"a", "b"},
+		"name": "alice",
+		"id":   "{{id}}",
+	}
+	checks := []config.Assertion{
+		{Type: "json", Path: "data", Op: "equals_json", Expect: expect},
+		{Type: "json", Path: "data", Op: "matches_json", Expect: map[string]interface{}{"name": "alice", "meta": map[string]interface{}{}}},
+	}
+	for _, res := range Evaluate(checks, body, http.Header{}, 200, map[string]string{"id": "42"}) {
+		if !res.Pass {
+			t.Fatalf("expected pass: %v", res.Message)
+		}
+	}
+
+	res := Evaluate([]config.Assertion{{Type: "json", Path: "data", Op: "equals_json", Expect: map[string]interface{}{
+		"id": 41, "name": "alice", "tags": []interface{}{"a"}, "owner": "bob",
+	}}}, body, http.Header{}, 200, nil)
+	want := []string{
+		"data.id: expected 41, got 42",
+		`data.owner: missing, expected "bob"`,
+		"data.tags: expected 1 items, got 2",
+		`data.meta: unexpected field with value {"score":1.5}`,
+	}
+	if res[0].Pass || strings.Join(res[0].Details, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected differences %q", res[0].Details)
+	}
+
+	res = Evaluate([]config.Assertion{{Type: "json", Path: "data.tags", Op: "matches_json", Expect: []interface{}{"a", "c"}}}, body, http.Header{}, 200, nil)
+	if res[0].Pass || res[0].Message != `json data.tags matches_json failed with 1 difference(s), first data.tags.1: expected "c", got "b"` {
+		t.Fatalf("unexpected result %+v", res[0])
+	}
+	res = Evaluate([]config.Assertion{{Type: "json", Path: "missing", Op: "matches_json", Expect: map[string]interface{}{}}}, body, http.Header{}, 200, nil)
+	if res[0].Pass {
+		t.Fatalf("expected missing path to fail")
+	}
+}
+
+func TestArrayAssertions(t *testing.T) {
+	body := `{"data": {"rows": [
+		{"id": 3, "status": 1, "createTime": "2024-03-02 10:00:00"},
+		{"id": 7, "status": 1, "createTime": "2024-03-01 09:30:00"},
+		{"id": 9, "status": 1, "createTime": "2024-02-28 18:00:00"}
+	]}}`
+	statusOK := []config.Assertion{{Type: "json", Path: "status", Op: "==", Expect: 1}}
+	checks := []config.Assertion{
+		{Type: "json", Path: "data.rows", Op: "len_gt", Expect: 0},
+		{Type: "json", Path: "data.rows", Op: "len_lt", Expect: 101},
+		{Type: "json", Path: "data.rows", Op: "len_eq", Expect: "{{n}}"},
+		{Type: "json", Path: "data.rows", Op: "every", Assert: statusOK},
+		{Type: "json", Path: "data.rows", Op: "any", Assert: []config.Assertion{{Type: "json", Path: "id", Op: "gt", Expect: 8}}},
+		{Type: "json", Path: "data.rows", Op: "none", Assert: []config.Assertion{{Type: "json", Path: "status", Op: "==", Expect: 0}}},
+		{Type: "json", Path: "data.rows", Op: "contains_item", Expect: map[string]interface{}{"id": "{{created_id}}"}},
+		{Type: "json", Path: "data.rows", Op: "unique_by", By: "id"},
+		{Type: "json", Path: "data.rows", Op: "sorted_by", By: "createTime", Expect: "desc"},
+		{Type: "json", Path: "data.rows", Op: "sorted_by", By: "id"},
+	}
+	ctx := map[string]string{"n": "3", "created_id": "7"}
+	res := Evaluate(checks, body, http.Header{}, 200, ctx)
+	if len(res) != len(checks) {
+		t.Fatalf("expected %d results, got %v", len(checks), res)
+	}
+	for _, r := range res {
+		if !r.Pass {
+			t.Fatalf("expected pass: %v", r.Message)
+		}
+	}
+
+	failing := []struct {
+		check config.Assertion
+		want  string
+	}{
+		{config.Assertion{Type: "json", Path: "data.rows", Op: "every", Assert: []config.Assertion{{Type: "json", Path: "id", Op: "lt", Expect: 5}}},
+			"json data.rows: 2 of 3 item(s) do not match, first data.rows.1: json id 7 not lt 5"},
+		{config.Assertion{Type: "json", Path: "data.rows", Op: "none", Assert: statusOK},
+			"json data.rows: 3 item(s) match, first item 0"},
+		{config.Assertion{Type: "json", Path: "data.rows", Op: "contains_item", Expect: map[string]interface{}{"id": 4}},
+			`json data.rows has no item matching {"id":4} among 3 item(s)`},
+		{config.Assertion{Type: "json", Path: "data.rows", Op: "unique_by", By: "status"},
+			"json data.rows items not unique by status: data.rows.1: duplicate 1, same as item 0"},
+		{config.Assertion{Type: "json", Path: "data.rows", Op: "sorted_by", By: "createTime"},
+			`json data.rows not sorted by createTime asc: item 0 "2024-03-02 10:00:00" comes before item 1 "2024-03-01 09:30:00"`},
+		{config.Assertion{Type: "json", Path: "data", Op: "len_eq", Expect: 1},
+			"json data is not an array"},
+	}
+	for _, tc := range failing {
+		r := Evaluate([]config.Assertion{tc.check}, body, http.Header{}, 200, ctx)[0]
+		if r.Pass || r.Message != tc.want {
+			t.Fatalf("%s: got %+v, want %q", tc.check.Op, r, tc.want)
+		}
+	}
+}
+
+func TestJSONPathSyntax(t *testing.T) {
+	body := `{"data": {"items": [{"id": 1, "name": "x", "tags": ["new"]}, {"id": 2, "name": "y", "tags": []}], "v.2": true, "ref": null}}`
+	checks := []config.Assertion{
+		{Type: "json", Path: "data.items.#", Op: "==", Expect: 2},
+		{Type: "json", Path: `data.items.#(name=="y").id`, Op: "==", Expect: 2},
+		{Type: "json", Path: "data.items.#.id", Op: "equals_json", Expect: []interface{}{1, 2}},
+		{Type: "json", Path: `data.items.#(tags.#>0)#.name`, Op: "equals_json", Expect: []interface{}{"x"}},
+		{Type: "json", Path: "data.items|@reverse|0.name", Op: "==", Expect: "y"},
+		{Type: "json", Path: `data.v\.2`, Op: "==", Expect: true},
+		{Type: "json", Path: "data.ref", Op: "equals_json", Expect: nil},
+	}
+	for _, res := range Evaluate(checks, body, http.Header{}, 200, nil) {
+		if !res.Pass {
+			t.Fatalf("expected pass: %v", res.Message)
+		}
+	}
+	res := Evaluate([]config.Assertion{{Type: "json", Path: "data.ref", Op: "exists"}}, body, http.Header{}, 200, nil)
+	if res[0].Pass {
+		t.Fatalf("null should not satisfy exists")
+	}
+}
+
+func TestStatusAssertions(t *testing.T) {
+	pass := []config.Assertion{
+		{Type: "status", Op: "in", Expect: []interface{}{200.0, 201.0, 204.0}},
+		{Type: "status", Op: "in", Expect: []interface{}{"3xx", 201}},
+		{Type: "status", Op: "==", Expect: "2xx"},
+		{Type: "status", Op: "!=", Expect: "5XX"},
+		{Type: "status", Op: "between", Expect: []interface{}{200, 299}},
+		{Type: "status", Op: "gt", Expect: 200},
+		{Type: "status", Op: "lt", Expect: 300},
+		{Type: "status", Expect: "201"},
+		{Type: "status", Expect: []interface{}{"2xx"}},
+		{Type: "status", Op: "between", Expect: []interface{}{"2xx", "3xx"}},
+		{Type: "status", Op: ">=", Expect: "2xx"},
+		{Type: "status", Op: "<=", Expect: "2xx"},
+		{Type: "status", Op: "<", Expect: "3xx"},
+	}
+	for _, r := range Evaluate(pass, "", http.Header{}, 201, nil) {
+		if !r.Pass {
+			t.Fatalf("expected pass: %v", r.Message)
+		}
+	}
+	failing := []struct {
+		check config.Assertion
+		want  string
+	}{
+		{config.Assertion{Type: "status", Op: "in", Expect: []interface{}{200, 204}}, "status 201 not in [200 204]"},
+		{config.Assertion{Type: "status", Op: "==", Expect: "4xx"}, "status 201 != 4xx"},
+		{config.Assertion{Type: "status", Op: "between", Expect: []interface{}{202, 299}}, "status 201 not between 202 and 299"},
+		{config.Assertion{Type: "status", Op: "gt", Expect: 201}, "status 201 <= 201"},
+		{config.Assertion{Type: "status", Op: "==", Expect: "ok"}, `invalid status "ok", want a code or a class like 2xx`},
+		{config.Assertion{Type: "status", Op: ">", Expect: "2xx"}, "status 201 <= 2xx"},
+		{config.Assertion{Type: "status", Op: "between", Expect: []interface{}{"3xx", "5xx"}}, "status 201 not between 3xx and 5xx"},
+		{config.Assertion{Type: "status", Op: "between", Expect: []interface{}{"ok", 299}}, "invalid status bound ok, want a code or a class like 2xx"},
+		{config.Assertion{Type: "status", Op: ">=", Expect: "abc"}, "invalid status bound abc, want a code or a class like 2xx"},
+		{config.Assertion{Type: "status", Op: "==", Expect: true}, "invalid status true, want a code or a class like 2xx"},
+	}
+	for _, tc := range failing {
+		r := Evaluate([]config.Assertion{tc.check}, "", http.Header{}, 201, nil)[0]
+		if r.Pass || r.Message != tc.want {
+			t.Fatalf("%s %v: got %+v, want %q", tc.check.Op, tc.check.Expect, r, tc.want)
+		}
+	}
+}
+
+func TestValueAssertions(t *testing.T) {
+	body := `{"id": "6f1c2a9e-3b4d-4e5f-8a7b-1c2d3e4f5a6b", "count": 3, "price": 9.5, "ok": true,
+		"tags": [], "meta": {}, "note": null, "email": "bob@example.com", "created": "2024-03-01T10:00:00+08:00",
+		"ip": "10.0.0.1", "phone": "+86 138-0013-8000", "sku": "ORD-20240301"}`
+	pass := []config.Assertion{
+		{Type: "json", Path: "id", Op: "type", Expect: "string"},
+		{Type: "json", Path: "count", Op: "type", Expect: "integer"},
+		{Type: "json", Path: "count", Op: "type", Expect: "number"},
+		{Type: "json", Path: "price", Op: "type", Expect: "number"},
+		{Type: "json", Path: "ok", Op: "type", Expect: "bool"},
+		{Type: "json", Path: "tags", Op: "type", Expect: "array"},
+		{Type: "json", Path: "meta", Op: "type", Expect: "object"},
+		{Type: "json", Path: "note", Op: "type", Expect: []interface{}{"string", "null"}},
+		{Type: "json", Path: "note", Op: "is_null"},
+		{Type: "json", Path: "count", Op: "not_empty"},
+		{Type: "json", Path: "sku", Op: "not_empty"},
+		{Type: "json", Path: "id", Op: "format", Expect: "uuid"},
+		{Type: "json", Path: "email", Op: "format", Expect: "email"},
+		{Type: "json", Path: "created", Op: "format", Expect: "date-time"},
+		{Type: "json", Path: "ip", Op: "format", Expect: "ipv4"},
+		{Type: "json", Path: "phone", Op: "format", Expect: "phone"},
+		{Type: "json", Path: "sku", Op: "format", Expect: `regex:^ORD-\d{8}$`},
+	}
+	for _, r := range Evaluate(pass, body, http.Header{}, 200, nil) {
+		if !r.Pass {
+			t.Fatalf("expected pass: %v", r.Message)
+		}
+	}
+	failing := []struct {
+		check config.Assertion
+		want  string
+	}{
+		{config.Assertion{Type: "json", Path: "price", Op: "type", Expect: "integer"}, "json price is number, not integer"},
+		{config.Assertion{Type: "json", Path: "count", Op: "type", Expect: "string"}, "json count is integer, not string"},
+		{config.Assertion{Type: "json", Path: "missing", Op: "is_null"}, "json path missing not found"},
+		{config.Assertion{Type: "json", Path: "ok", Op: "is_null"}, "json ok is bool true, not null"},
+		{config.Assertion{Type: "json", Path: "tags", Op: "not_empty"}, "json tags is empty: []"},
+		{config.Assertion{Type: "json", Path: "note", Op: "not_empty"}, "json note is empty: null"},
+		{config.Assertion{Type: "json", Path: "email", Op: "format", Expect: "uuid"}, `json email value "bob@example.com" is not a valid uuid`},
+		{config.Assertion{Type: "json", Path: "count", Op: "format", Expect: "phone"}, "json count is integer, not a phone string"},
+		{config.Assertion{Type: "json", Path: "sku", Op: "format", Expect: "iban"}, "unknown format iban, use regex:<pattern> for custom formats"},
+	}
+	for _, tc := range failing {
+		r := Evaluate([]config.Assertion{tc.check}, body, http.Header{}, 200, nil)[0]
+		if r.Pass || r.Message != tc.want {
+			t.Fatalf("%s %s: got %+v, want %q", tc.check.Path, tc.check.Op, r, tc.want)
+		}
+	}
+	if r := Evaluate([]config.Assertion{{Type: "json", Path: "note", Op: "exists"}}, body, http.Header{}, 200, nil)[0]; r.Pass {
+		t.Fatalf("exists should still treat null as missing")
+	}
+}
+
+func TestUnifiedOperators(t *testing.T) {
+	body := `{"id": "42", "amount": 19.999, "score": 87.5, "state": "PAID", "count": 3}`
+	headers := http.Header{"X-Total": {"120"}, "X-Request-Id": {"req-9f2"}}
+	xmlBody := `<order><total>10.01</total><state>PAID</state></order>`
+	checks := []config.Assertion{
+		{Type: "json", Path: "count", Op: ">=", Expect: 3},
+		{Type: "json", Path: "count", Op: "<=", Expect: "{{max}}"},
+		{Type: "json", Path: "id", Op: "==", Expect: 42, Coerce: "number"},
+		{Type: "json", Path: "id", Op: "gt", Expect: 41, Coerce: "number"},
+		{Type: "json", Path: "id", Op: "between", Expect: []interface{}{1, 100}, Coerce: "number"},
+		{Type: "json", Path: "amount", Op: "approx", Expect: 20, Tolerance: 0.01},
+		{Type: "json", Path: "score", Op: "approx", Expect: 88, Tolerance: "1%"},
+		{Type: "json", Path: "state", Op: "in", Expect: []interface{}{"PAID", "SHIPPED"}},
+		{Type: "json", Path: "state", Op: "not_in", Expect: []interface{}{"CANCELLED"}},
+		{Type: "json", Path: "state", Op: "regex", Expect: "^[A-Z]+$"},
+		{Type: "json", Path: "state", Op: "not_contains", Expect: "FAIL"},
+		{Type: "header", Path: "X-Total", Op: ">", Expect: 100},
+		{Type: "header", Path: "X-Total", Op: "between", Expect: []interface{}{100, 200}},
+		{Type: "header", Path: "X-Request-Id", Op: "regex", Expect: "^req-[0-9a-f]+$"},
+		{Type: "status", Op: "<", Expect: 300},
+	}
+	for _, r := range Evaluate(checks, body, headers, 200, map[string]string{"max": "3"}) {
+		if !r.Pass {
+			t.Fatalf("expected pass: %v", r.Message)
+		}
+	}
+	xmlChecks := []config.Assertion{
+		{Type: "xml", Path: "/order/total", Op: ">=", Expect: 10},
+		{Type: "xml", Path: "/order/total", Op: "approx", Expect: 10, Tolerance: 0.05},
+		{Type: "xml", Path: "/order/state", Op: "in", Expect: []interface{}{"PAID"}},
+	}
+	for _, r := range Evaluate(xmlChecks, xmlBody, http.Header{}, 200, nil) {
+		if !r.Pass {
+			t.Fatalf("expected xml pass: %v", r.Message)
+		}
+	}
+
+	failing := []struct {
+		check config.Assertion
+		want  string
+	}{
+		{config.Assertion{Type: "json", Path: "id", Op: "gt", Expect: 41}, "json id not a number: 42"},
+		{config.Assertion{Type: "json", Path: "count", Op: ">=", Expect: "abc"}, "json count >= expects a number, got abc"},
+		{config.Assertion{Type: "json", Path: "count", Op: ">=", Expect: 4}, "json count 3 not >= 4"},
+		{config.Assertion{Type: "json", Path: "state", Op: "==", Expect: 1, Coerce: "number"}, `json state value "PAID" is not numeric`},
+		{config.Assertion{Type: "json", Path: "amount", Op: "approx", Expect: 20, Tolerance: 0.0001}, "json amount 19.999 not within ±0.0001 of 20"},
+		{config.Assertion{Type: "json", Path: "state", Op: "not_in", Expect: []interface{}{"PAID"}}, "json state value PAID is in [PAID]"},
+		{config.Assertion{Type: "header", Path: "X-Total", Op: "<=", Expect: 100}, "header X-Total 120 not <= 100"},
+		{config.Assertion{Type: "json", Path: "count", Op: "approx", Expect: 3, Tolerance: "x%"}, `invalid tolerance "x%"`},
+	}
+	for _, tc := range failing {
+		r := Evaluate([]config.Assertion{tc.check}, body, headers, 200, nil)[0]
+		if r.Pass || r.Message != tc.want {
+			t.Fatalf("%s %s: got %+v, want %q", tc.check.Path, tc.check.Op, r, tc.want)
+		}
+	}
+	equality := []struct {
+		check config.Assertion
+		want  string
+	}{
+		{config.Assertion{Type: "json", Path: "count", Op: "==", Expect: 4}, "json count 3 != 4"},
+		{config.Assertion{Type: "json", Path: "id", Op: "==", Expect: 42}, "json id not a number: 42"},
+		{config.Assertion{Type: "json", Path: "state", Op: "!=", Expect: "PAID"}, "json state PAID == PAID"},
+		{config.Assertion{Type: "json", Path: "state", Op: "==", Expect: true}, `json state not a bool: "PAID"`},
+		{config.Assertion{Type: "json", Path: "count", Op: "==", Expect: []interface{}{3}}, "json count == expects a single value, got [3]; use equals_json for objects and arrays"},
+	}
+	for _, tc := range equality {
+		r := Evaluate([]config.Assertion{tc.check}, body, headers, 200, nil)[0]
+		if r.Pass || r.Message != tc.want {
+			t.Fatalf("%s %s: got %+v, want %q", tc.check.Path, tc.check.Op, r, tc.want)
+		}
+	}
+	templated := []config.Assertion{
+		{Type: "json", Path: "id", Op: "==", Expect: "{{id}}"},
+		{Type: "header", Path: "X-Total", Op: ">", Expect: "{{min}}"},
+		{Type: "header", Path: "X-Total", Op: "between", Expect: []interface{}{"{{min}}", 200}},
+		{Type: "header", Path: "X-Request-Id", Op: "==", Expect: "req-{{rid}}"},
+	}
+	for _, r := range Evaluate(templated, body, headers, 200, map[string]string{"id": "42", "min": "100", "rid": "9f2"}) {
+		if !r.Pass {
+			t.Fatalf("expected templated pass: %v", r.Message)
+		}
+	}
+	xmlCheck := config.Assertion{Type: "xml", Path: "/order/total", Op: ">", Expect: "abc"}
+	if r := Evaluate([]config.Assertion{xmlCheck}, xmlBody, headers, 200, nil)[0]; r.Pass || r.Message != "xml /order/total > expects a number, got abc" {
+		t.Fatalf("xml ordered op with a non-numeric expect: got %+v", r)
+	}
+}
+
+func TestTimeAssertions(t *testing.T) {
+	now := time.Now()
+	shanghai := time.FixedZone("CST", 8*3600)
+	body := fmt.Sprintf(`{"updated": %q, "local": %q, "epoch": %d, "epoch_ms": %d, "old": "2020-01-01T00:00:00Z", "created": %q, "wall": %q}`,
+		now.Add(-2*time.Minute).In(shanghai).Format(time.RFC3339), now.Add(-time.Hour).UTC().Format("2006-01-02 15:04:05"),
+		now.Unix(), now.Add(-30*time.Second).UnixMilli(), now.Add(-3*time.Minute).UTC().Format(time.RFC3339Nano),
+		now.In(shanghai).Format("2006/01/02 15:04"))
+	ctx := map[string]string{"created": now.Add(-3 * time.Minute).UTC().Format(time.RFC3339)}
+	checks := []config.Assertion{
+		{Type: "json", Path: "updated", Op: "within", Expect: "5m"},
+		{Type: "json", Path: "updated", Op: "after", Expect: "{{created}}"},
+		{Type: "json", Path: "updated", Op: "within", Expect: "90s", Of: "{{created}}"},
+		{Type: "json", Path: "updated", Op: "tz", Expect: "+08:00"},
+		{Type: "json", Path: "created", Op: "tz", Expect: "Z"},
+		{Type: "json", Path: "local", Op: "before", Expect: "now-50m", Layout: "2006-01-02 15:04:05"},
+		{Type: "json", Path: "local", Op: "after", Expect: "now-2h"},
+		{Type: "json", Path: "epoch", Op: "within", Expect: "2s", Layout: "epoch"},
+		{Type: "json", Path: "epoch_ms", Op: "within", Expect: "1m"},
+		{Type: "json", Path: "epoch_ms", Op: "before", Expect: "now", Layout: "epoch_ms"},
+		{Type: "json", Path: "local", Op: "before", Expect: "now - 50m"},
+		{Type: "json", Path: "wall", Op: "within", Expect: "2m", Layout: "2006/01/02 15:04", Timezone: "+08:00"},
+	}
+	for _, r := range Evaluate(checks, body, http.Header{}, 200, ctx) {
+		if !r.Pass {
+			t.Fatalf("expected pass: %v", r.Message)
+		}
+	}
+	failing := []struct {
+		check config.Assertion
+		want  string
+	}{
+		{config.Assertion{Type: "json", Path: "old", Op: "after", Expect: "now-24h"}, "json old 2020-01-01T00:00:00Z is not after now-24h"},
+		{config.Assertion{Type: "json", Path: "old", Op: "within", Expect: "10m"}, "json old 2020-01-01T00:00:00Z is "},
+		{config.Assertion{Type: "json", Path: "updated", Op: "tz", Expect: "Z"}, "json updated offset is +08:00, not Z"},
+		{config.Assertion{Type: "json", Path: "local", Op: "tz", Expect: "+08:00", Layout: "2006-01-02 15:04:05"}, "carries no timezone offset"},
+		{config.Assertion{Type: "json", Path: "local", Op: "before", Expect: "now", Layout: "rfc3339"}, "is not an RFC 3339 time"},
+		{config.Assertion{Type: "json", Path: "old", Op: "before", Expect: "yesterday"}, `reference time: "yesterday" is not an RFC 3339 time`},
+		{config.Assertion{Type: "json", Path: "wall", Op: "within", Expect: "2m", Layout: "2006/01/02 15:04"}, "more than 2m0s"},
+		{config.Assertion{Type: "json", Path: "wall", Op: "within", Expect: "2m", Timezone: "Mars/Olympus"}, `invalid timezone "Mars/Olympus"`},
+		{config.Assertion{Type: "json", Path: "old", Op: "before", Expect: "now 5m"}, `invalid reference time "now 5m"`},
+	}
+	for _, tc := range failing {
+		r := Evaluate([]config.Assertion{tc.check}, body, http.Header{}, 200, ctx)[0]
+		if r.Pass || !strings.Contains(r.Message, tc.want) {
+			t.Fatalf("%s %s: got %+v, want %q", tc.check.Path, tc.check.Op, r, tc.want)
+		}
+	}
+}
+
+func TestLogicalAssertions(t *testing.T) {
+	body := `{"data": {"id": 1}, "msg": "ok"}`
+	statusOK := config.Assertion{Type: "status", Op: "==", Expect: 200}
+	hasData := config.Assertion{Type: "json", Path: "data", Op: "exists"}
+	noContent := config.Assertion{Type: "status", Op: "==", Expect: 204}
+	either := config.Assertion{AnyOf: []config.Assertion{{AllOf: []config.Assertion{statusOK, hasData}}, noContent}}
+
+	res := Evaluate([]config.Assertion{either}, body, http.Header{}, 200, nil)[0]
+	if !res.Pass || res.Message != "any_of: 1 of 2 passed" || len(res.Children) != 2 {
+		t.Fatalf("unexpected any_of result %+v", res)
+	}
+	if all := res.Children[0]; !all.Pass || len(all.Children) != 2 || res.Children[1].Pass {
+		t.Fatalf("expected the all_of branch to pass and the 204 branch to fail, got %+v", res.Children)
+	}
+	if res := Evaluate([]config.Assertion{either}, "", http.Header{}, 204, nil)[0]; !res.Pass {
+		t.Fatalf("expected 204 to satisfy any_of: %v", res.Message)
+	}
+	res = Evaluate([]config.Assertion{either}, `{"msg": "ok"}`, http.Header{}, 200, nil)[0]
+	if res.Pass || res.Message != "any_of: none of 2 passed" {
+		t.Fatalf("unexpected any_of failure %+v", res)
+	}
+	if all := res.Children[0]; all.Pass || all.Message != "all_of: 1 of 2 failed, first json path data does not exist" {
+		t.Fatalf("unexpected all_of failure %+v", all)
+	}
+
+	notError := config.Assertion{Not: &config.Assertion{Type: "body", Op: "regex", Expect: "(?i)error"}}
+	res = Evaluate([]config.Assertion{notError}, body, http.Header{}, 200, nil)[0]
+	if !res.Pass || res.Message != `not (body regex "(?i)error")` || len(res.Children) != 1 || res.Children[0].Pass {
+		t.Fatalf("unexpected not result %+v", res)
+	}
+	res = Evaluate([]config.Assertion{notError}, `{"msg": "Error"}`, http.Header{}, 200, nil)[0]
+	if res.Pass || res.Message != `not (body regex "(?i)error") failed: body matches (?i)error` {
+		t.Fatalf("unexpected not failure %+v", res)
+	}
+
+	invalid := []config.Assertion{
+		{Not: &config.Assertion{Type: "json", Path: "data.id", Op: "equal", Expect: 1}},
+		{Not: &config.Assertion{Type: "body", Op: "regex", Expect: "[unclosed"}},
+		{Not: &config.Assertion{Type: "json", Path: "data.id", Op: ">", Expect: "abc"}},
+		{Not: &config.Assertion{Type: "json", Path: "data.id", Op: "approx", Expect: 1, Tolerance: "x%"}},
+		{Not: &config.Assertion{Type: "json", Path: "missing", Op: "type", Expect: "strnig"}},
+		{Not: &config.Assertion{Type: "json", Path: "data.id", Op: "within", Expect: "5m", Of: "{{unset}}"}},
+		{Not: &config.Assertion{AnyOf: []config.Assertion{noContent, {Type: "status", Op: "~", Expect: 200}}}},
+		{AnyOf: []config.Assertion{statusOK, {Type: "header", Path: "X-Id", Op: "regex", Expect: "("}}},
+		{AllOf: []config.Assertion{hasData, {Type: "stauts", Expect: 200}}},
+		{Type: "json", Path: "rows", Op: "none", Assert: []config.Assertion{{Type: "json", Path: "id", Op: "eq", Expect: 1}}},
+	}
+	for _, a := range invalid {
+		res := Evaluate([]config.Assertion{a}, `{"data": {"id": 1}, "rows": [{"id": 1}]}`, http.Header{"X-Id": {"a"}}, 200, nil)[0]
+		if res.Pass || !res.Invalid {
+			t.Fatalf("%s: a misconfigured nested assertion must fail, got %+v", Describe(a), res)
+		}
+	}
+}
+
+func TestSnapshotIgnorePaths(t *testing.T) {
+	body := `{"a.b": 1, "meta": {"ts1": 5, "ts2": 6, "keep": 7}, "rows": [{"id": 1, "k": "x"}, {"id": 2, "k": "y"}]}`
+	got, ext, err := normalizeSnapshot(body, []string{`a\.b`, "meta.ts*", `rows.#(k=="y").id`, "rows.#.k"})
+	if err != nil || ext != ".json" {
+		t.Fatalf("normalize: %v %s", err, ext)
+	}
+	for _, want := range []string{`"a.b": "<ignored>"`, `"ts1": "<ignored>"`, `"keep": 7`, `"id": 1`, `"id": "<ignored>"`, `"k": "<ignored>"`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %s in\n%s", want, got)
+		}
+	}
+	if _, _, err := normalizeSnapshot(body, []string{"meta.missing"}); err == nil || !strings.Contains(err.Error(), "ignore path meta.missing matches nothing") {
+		t.Fatalf("expected unmatched ignore path to fail, got %v", err)
+	}
+
+	empty := `{"items": [], "rows": [{"id": 1}]}`
+	got, _, err = normalizeSnapshot(empty, []string{"items.#.id", `rows.#(id==2)#`, "rows.#.ts"})
+	if err != nil || !strings.Contains(got, `"id": 1`) {
+		t.Fatalf("empty multi-results should mask nothing, got %v\n%s", err, got)
+	}
+	if _, _, err := normalizeSnapshot(empty, []string{"missing.#.id"}); err == nil || !strings.Contains(err.Error(), "matches nothing") {
+		t.Fatalf("expected a projection over a missing array to fail, got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package assert
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// sharedOps are the operators json, xml and header assertions all accept on
+// top of their own ==, !=, contains and exists.
+var sharedOps = map[string]bool{
+	">": true, ">=": true, "<": true, "<=": true, "gt": true, "lt": true,
+	"in": true, "not_in": true, "between": true, "approx": true,
+	"regex": true, "not_contains": true,
+}
+
+// orderedOps maps the numeric comparison operators to their symbol, and
+// negatedOps gives the symbol that describes a failure.
+var (
+	orderedOps = map[string]string{">": ">", ">=": ">=", "<": "<", "<=": "<=", "gt": ">", "lt": "<"}
+	negatedOps = map[string]string{">": "<=", ">=": "<", "<": ">=", "<=": ">", "==": "!=", "!=": "=="}
+)
+
+// coerceJSON converts a json value as requested by the coerce option.
+func coerceJSON(mode, path string, res gjson.Result) (gjson.Result, error) {
+	switch mode {
+	case "number":
+		if !res.Exists() || res.Type == gjson.Number {
+			return res, nil
+		}
+		if res.Type != gjson.String {
+			return res, fmt.Errorf("json %s cannot be coerced to a number: %s", path, res.Raw)
+		}
+		s := strings.TrimSpace(res.Str)
+		if gjson.Valid(s) && gjson.Parse(s).Type == gjson.Number {
+			return gjson.Parse(s), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return res, fmt.Errorf("json %s value %q is not numeric", path, res.Str)
+		}
+		return gjson.Parse(strconv.FormatFloat(f, 'f', -1, 64)), nil
+	}
+	return res, fmt.Errorf("unknown coerce %s, want number", mode)
+}
+
+// scalar is a value reduced for comparison: its text and, when it is or
+// parses as one, its number.
+type scalar struct {
+	text  string
+	num   float64
+	isNum bool
+	// unit, when set, reads expectations such as "500ms" or "2KB" into the
+	// unit of num and formats numbers in messages.
+	unit *unit
+}
+
+// unit converts expectations for a measured quantity; format may be nil.
+type unit struct {
+	parse  func(interface{}) (float64, error)
+	format func(float64) string
+}
+
+var (
+	durationUnit = &unit{
+		parse: func(v interface{}) (float64, error) {
+			d, err := toDuration(v)
+			return float64(d), err
+		},
+		format: func(n float64) string { return time.Duration(n).String() },
+	}
+	byteUnit = &unit{parse: func(v interface{}) (float64, error) {
+		n, err := toByteSize(v)
+		return float64(n), err
+	}}
+)
+
+// durationScalar and countScalar wrap measured values for compareScalar.
+func durationScalar(d time.Duration) scalar {
+	return scalar{text: d.String(), num: float64(d), isNum: true, unit: durationUnit}
+}
+
+func countScalar(n float64, u *unit) scalar {
+	return scalar{text: fmt.Sprint(n), num: n, isNum: true, unit: u}
+}
+
+// expectNumber reads a bound for the ordered operators, between and approx:
+// any number, a numeric string or, with a unit, a value the unit parses.
+func (s scalar) expectNumber(v interface{}) (float64, bool) {
+	if s.unit != nil {
+		n, err := s.unit.parse(v)
+		return n, err == nil
+	}
+	return expectNumber(v)
+}
+
+// literalNumber reads an item compared by ==, != and in. Without a unit
+// only actual numbers count, so a quoted "7" is compared as text.
+func (s scalar) literalNumber(v interface{}) (float64, bool) {
+	if s.unit != nil {
+		return s.expectNumber(v)
+	}
+	return jsonNumber(v)
+}
+
+func (s scalar) show(n float64) string {
+	if s.unit != nil && s.unit.format != nil {
+		return s.unit.format(n)
+	}
+	return fmt.Sprint(n)
+}
+
+func textScalar(s string) scalar {
+	v := scalar{text: s}
+	if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+		v.num, v.isNum = f, true
+	}
+	return v
+}
+
+// compareScalar implements sharedOps, plus == and != for callers that have
+// no equality of their own. kind and label prefix the messages, e.g.
+// "header X-Total"; label may be empty.
+func compareScalar(kind, label, op string, actual scalar, expect, tolerance interface{}) Result {
+	subject := strings.TrimSpace(kind + " " + label)
+	switch op {
+	case "==", "!=":
+		var equal bool
+		var got, want string
+		switch expect.(type) {
+		case []interface{}, map[string]interface{}:
+			return invalidf("%s %s expects a single value, got %v; use equals_json for objects and arrays", subject, op, expect)
+		}
+		if exp, ok := actual.literalNumber(expect); ok {
+			if !actual.isNum {
+				return Result{Pass: false, Message: fmt.Sprintf("%s not a number: %s", subject, actual.text)}
+			}
+			equal, got, want = actual.num == exp, actual.show(actual.num), actual.show(exp)
+		} else {
+			want = fmt.Sprint(expect)
+			if expect == nil {
+				want = "null"
+			}
+			equal, got = actual.text == want, actual.text
+		}
+		if equal == (op == "==") {
+			return Result{Pass: true, Message: fmt.Sprintf("%s %s %s", subject, op, want)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("%s %s %s %s", subject, got, negatedOps[op], want)}
+	case ">", ">=", "<", "<=", "gt", "lt":
+		exp, ok := actual.expectNumber(expect)
+		if !ok {
+			return invalidf("%s %s expects a number, got %v", subject, op, expect)
+		}
+		if !actual.isNum {
+			return Result{Pass: false, Message: fmt.Sprintf("%s not a number: %s", subject, actual.text)}
+		}
+		if pass, _ := compareOrdered(op, actual.num, exp); pass {
+			return Result{Pass: true, Message: fmt.Sprintf("%s %s %s %s", subject, actual.show(actual.num), op, actual.show(exp))}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("%s %s not %s %s", subject, actual.show(actual.num), op, actual.show(exp))}
+	case "in", "not_in":
+		list, ok := expect.([]interface{})
+		if !ok {
+			return invalidf("%s %s expects a list, got %v", subject, op, expect)
+		}
+		found := false
+		for _, item := range list {
+			if n, ok := actual.literalNumber(item); ok {
+				found = actual.isNum && n == actual.num
+			} else {
+				found = fmt.Sprint(item) == actual.text
+			}
+			if found {
+				break
+			}
+		}
+		switch {
+		case found == (op == "in"):
+			return Result{Pass: true, Message: fmt.Sprintf("%s %s %s %v", subject, actual.text, op, list)}
+		case found:
+			return Result{Pass: false, Message: fmt.Sprintf("%s value %s is in %v", subject, actual.text, list)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("%s value %s not in %v", subject, actual.text, list)}
+	case "between":
+		list, ok := expect.([]interface{})
+		if !ok || len(list) != 2 {
+			return invalidf("%s between expects [low, high], got %v", subject, expect)
+		}
+		low, ok1 := actual.expectNumber(list[0])
+		high, ok2 := actual.expectNumber(list[1])
+		if !ok1 || !ok2 {
+			return invalidf("%s between expects numbers, got %v", subject, expect)
+		}
+		if !actual.isNum {
+			return Result{Pass: false, Message: fmt.Sprintf("%s not a number: %s", subject, actual.text)}
+		}
+		if actual.num >= low && actual.num <= high {
+			return Result{Pass: true, Message: fmt.Sprintf("%s %s between %s and %s", subject, actual.show(actual.num), actual.show(low), actual.show(high))}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("%s %s not between %s and %s", subject, actual.show(actual.num), actual.show(low), actual.show(high))}
+	case "approx":
+		exp, ok := actual.expectNumber(expect)
+		if !ok {
+			return invalidf("%s approx expects a number, got %v", subject, expect)
+		}
+		if !actual.isNum {
+			return Result{Pass: false, Message: fmt.Sprintf("%s not a number: %s", subject, actual.text)}
+		}
+		tol, desc, err := parseTolerance(tolerance, exp, actual)
+		if err != nil {
+			return invalidf("%v", err)
+		}
+		if math.Abs(actual.num-exp) <= tol {
+			return Result{Pass: true, Message: fmt.Sprintf("%s %s ≈ %s (±%s)", subject, actual.show(actual.num), actual.show(exp), desc)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("%s %s not within ±%s of %s", subject, actual.show(actual.num), desc, actual.show(exp))}
+	case "regex":
+		pattern := fmt.Sprint(expect)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return invalidf("invalid regex: %v", err)
+		}
+		if re.MatchString(actual.text) {
+			return Result{Pass: true, Message: fmt.Sprintf("%s matches %s", subject, pattern)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("%s value %s does not match %s", subject, actual.text, pattern)}
+	case "not_contains":
+		exp := fmt.Sprint(expect)
+		if !strings.Contains(actual.text, exp) {
+			return Result{Pass: true, Message: fmt.Sprintf("%s does not contain %s", subject, exp)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("%s value %s contains %s", subject, actual.text, exp)}
+	}
+	return invalidf("unknown %s op %s", kind, op)
+}
+
+// expectNumber reads a numeric expectation, also from a string such as a
+// resolved template.
+func expectNumber(v interface{}) (float64, bool) {
+	if n, ok := jsonNumber(v); ok {
+		return n, true
+	}
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parseTolerance reads an approx tolerance: a number (or a value in the unit
+// of actual, such as "20ms") is absolute, "0.5%" is relative to expect. It
+// defaults to 1e-9 to absorb float rounding.
+func parseTolerance(v interface{}, expect float64, actual scalar) (float64, string, error) {
+	if v == nil {
+		return 1e-9, "1e-09", nil
+	}
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		if pct, ok := strings.CutSuffix(s, "%"); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+			if err != nil || f < 0 {
+				return 0, "", fmt.Errorf("invalid tolerance %q", s)
+			}
+			return math.Abs(expect) * f / 100, s, nil
+		}
+	}
+	f, ok := actual.expectNumber(v)
+	if !ok || f < 0 {
+		return 0, "", fmt.Errorf("invalid tolerance %v", v)
+	}
+	return f, actual.show(f), nil
+}
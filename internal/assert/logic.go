@@ -0,0 +1,61 @@
+package assert
+
+import (
+	"fmt"
+
+	"apitest/internal/config"
+	"apitest/internal/httpx"
+)
+
+// isWrapper reports whether a nests other assertions with not, any_of or
+// all_of.
+func isWrapper(a config.Assertion) bool {
+	return a.Not != nil || len(a.AnyOf) > 0 || len(a.AllOf) > 0
+}
+
+// evaluateWrapper evaluates every nested assertion, so the report can show
+// the whole tree, and combines the results. An invalid nested assertion makes
+// the wrapper fail as invalid: a typo must not turn a negation into a pass.
+func evaluateWrapper(a config.Assertion, resp httpx.ResponseInfo, ctx map[string]string) Result {
+	switch {
+	case a.Not != nil:
+		child := evaluateOne(*a.Not, resp, ctx)
+		desc := Describe(a)
+		if child.Invalid {
+			return Result{Pass: false, Invalid: true, Message: fmt.Sprintf("%s is invalid: %s", desc, child.Message), Children: []Result{child}}
+		}
+		if !child.Pass {
+			return Result{Pass: true, Message: desc, Children: []Result{child}}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("%s failed: %s", desc, child.Message), Children: []Result{child}}
+	case len(a.AnyOf) > 0:
+		children := EvaluateSoft(a.AnyOf, resp, ctx)
+		if bad, ok := firstInvalid(children); ok {
+			return Result{Pass: false, Invalid: true, Message: "any_of: invalid assertion: " + bad.Message, Children: children}
+		}
+		passed := len(children) - len(Failures(children))
+		if passed > 0 {
+			return Result{Pass: true, Message: fmt.Sprintf("any_of: %d of %d passed", passed, len(children)), Children: children}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("any_of: none of %d passed", len(children)), Children: children}
+	default:
+		children := EvaluateSoft(a.AllOf, resp, ctx)
+		if bad, ok := firstInvalid(children); ok {
+			return Result{Pass: false, Invalid: true, Message: "all_of: invalid assertion: " + bad.Message, Children: children}
+		}
+		failed := Failures(children)
+		if len(failed) == 0 {
+			return Result{Pass: true, Message: fmt.Sprintf("all_of: %d passed", len(children)), Children: children}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("all_of: %d of %d failed, first %s", len(failed), len(children), failed[0].Message), Children: children}
+	}
+}
+
+func firstInvalid(results []Result) (Result, bool) {
+	for _, r := range results {
+		if r.Invalid {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
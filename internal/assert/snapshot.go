@@ -0,0 +1,161 @@
+package assert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"apitest/internal/config"
+	"apitest/internal/diff"
+	"apitest/internal/httpx"
+)
+
+// ignoredValue replaces volatile fields so their presence is still compared.
+const ignoredValue = "<ignored>"
+
+// assertSnapshot compares the normalized body with the golden file. A
+// missing file is created; with UpdateSnapshot the file is rewritten.
+func assertSnapshot(a config.Assertion, resp httpx.ResponseInfo) Result {
+	if a.SnapshotFile == "" {
+		return invalidf("snapshot assertions are only supported in step assert lists")
+	}
+	if resp.BodyTruncated {
+		return Result{Pass: false, Message: fmt.Sprintf("response body truncated to %d of %d bytes; raise max_response_size", len(resp.Body), resp.BodySize)}
+	}
+	actual, ext, err := normalizeSnapshot(resp.Body, a.Ignore)
+	if err != nil {
+		return Result{Pass: false, Message: fmt.Sprintf("snapshot: %v", err)}
+	}
+	file := a.SnapshotFile + ext
+	golden, err := os.ReadFile(file)
+	missing := errors.Is(err, os.ErrNotExist)
+	if err != nil && !missing {
+		return Result{Pass: false, Message: fmt.Sprintf("read snapshot: %v", err)}
+	}
+	if a.UpdateSnapshot || missing {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return Result{Pass: false, Message: fmt.Sprintf("write snapshot: %v", err)}
+		}
+		if err := os.WriteFile(file, []byte(actual), 0o644); err != nil {
+			return Result{Pass: false, Message: fmt.Sprintf("write snapshot: %v", err)}
+		}
+		verb := "updated"
+		if missing {
+			verb = "created"
+		}
+		return Result{Pass: true, Message: fmt.Sprintf("snapshot %s %s", file, verb)}
+	}
+	expected := string(golden)
+	if ext == ".json" {
+		// re-normalize so hand-edited golden files compare cleanly
+		if normalized, _, err := normalizeSnapshot(expected, a.Ignore); err == nil {
+			expected = normalized
+		}
+	}
+	d := diff.Unified(file, "response", expected, actual, 3)
+	if d == "" {
+		return Result{Pass: true, Message: fmt.Sprintf("body matches snapshot %s", file)}
+	}
+	return Result{
+		Pass:    false,
+		Message: fmt.Sprintf("body does not match snapshot %s; run with --update-snapshots to accept", file),
+		Diff:    d,
+	}
+}
+
+// normalizeSnapshot pretty-prints JSON bodies with sorted keys and masked
+// ignore paths; other bodies are kept as text.
+func normalizeSnapshot(body string, ignore []string) (string, string, error) {
+	if !gjson.Valid(body) {
+		text := strings.ReplaceAll(body, "\r\n", "\n")
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return text, ".txt", nil
+	}
+	masked, err := maskIgnored(body, ignore)
+	if err != nil {
+		return "", "", err
+	}
+	dec := json.NewDecoder(strings.NewReader(masked))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return "", "", err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return "", "", err
+	}
+	return buf.String(), ".json", nil
+}
+
+// maskIgnored replaces every value selected by the ignore paths with
+// ignoredValue. Paths are resolved by gjson, so escapes, wildcards and
+// #(...) queries work as in json assertions. A path that does not exist is an
+// error rather than a silently unmasked field, while a projection or query
+// over an empty array simply has nothing to mask.
+func maskIgnored(body string, ignore []string) (string, error) {
+	type span struct{ start, end int }
+	var spans []span
+	for _, path := range ignore {
+		res := gjson.Get(body, path)
+		if !res.Exists() {
+			return "", fmt.Errorf("ignore path %s matches nothing", path)
+		}
+		starts := res.Indexes
+		if starts == nil {
+			if res.Index == 0 && res.IsArray() && len(res.Array()) == 0 {
+				// a #(...)# query without matches
+				continue
+			}
+			starts = []int{res.Index}
+		}
+		for _, start := range starts {
+			if start <= 0 {
+				return "", fmt.Errorf("ignore path %s does not select a value in the body", path)
+			}
+			spans = append(spans, span{start, start + rawValueLen(body[start:])})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+	var sb strings.Builder
+	last := 0
+	for _, sp := range spans {
+		if sp.start < last {
+			// inside a value that is already masked
+			continue
+		}
+		sb.WriteString(body[last:sp.start])
+		sb.WriteString(strconv.Quote(ignoredValue))
+		last = sp.end
+	}
+	sb.WriteString(body[last:])
+	return sb.String(), nil
+}
+
+// rawValueLen returns the length of the JSON value at the start of s.
+func rawValueLen(s string) int {
+	var raw json.RawMessage
+	dec := json.NewDecoder(strings.NewReader(s))
+	if err := dec.Decode(&raw); err != nil {
+		return 0
+	}
+	return int(dec.InputOffset())
+}
@@ -0,0 +1,180 @@
+package assert
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"apitest/internal/templ"
+)
+
+// compareStructure implements equals_json (deep equality, key order and
+// number formatting ignored) and matches_json (every expected key must match,
+// extra keys in the response are allowed). Arrays are compared element by
+// element in both modes.
+func compareStructure(op, path string, val gjson.Result, expect interface{}) Result {
+	label := path
+	if label == "" {
+		label = "(root)"
+	}
+	actual := val.Value()
+	if !val.Exists() {
+		return Result{Pass: false, Message: fmt.Sprintf("json path %s not found", label)}
+	}
+	var diffs []string
+	structureDiff(path, expect, actual, op == "matches_json", &diffs)
+	if len(diffs) == 0 {
+		return Result{Pass: true, Message: fmt.Sprintf("json %s %s", label, op)}
+	}
+	return Result{
+		Pass:    false,
+		Message: fmt.Sprintf("json %s %s failed with %d difference(s), first %s", label, op, len(diffs), diffs[0]),
+		Details: diffs,
+	}
+}
+
+func structureDiff(path string, expect, actual interface{}, subset bool, out *[]string) {
+	at := path
+	if at == "" {
+		at = "(root)"
+	}
+	if en, ok := jsonNumber(expect); ok {
+		if an, ok := jsonNumber(actual); !ok || an != en {
+			*out = append(*out, fmt.Sprintf("%s: expected %s, got %s", at, compactJSON(expect), compactJSON(actual)))
+		}
+		return
+	}
+	switch e := expect.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			*out = append(*out, fmt.Sprintf("%s: expected object, got %s", at, compactJSON(actual)))
+			return
+		}
+		keys := make([]string, 0, len(e))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := joinPath(path, k)
+			av, ok := a[k]
+			if !ok {
+				*out = append(*out, fmt.Sprintf("%s: missing, expected %s", child, compactJSON(e[k])))
+				continue
+			}
+			structureDiff(child, e[k], av, subset, out)
+		}
+		if subset {
+			return
+		}
+		extra := make([]string, 0)
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				extra = append(extra, k)
+			}
+		}
+		sort.Strings(extra)
+		for _, k := range extra {
+			*out = append(*out, fmt.Sprintf("%s: unexpected field with value %s", joinPath(path, k), compactJSON(a[k])))
+		}
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			*out = append(*out, fmt.Sprintf("%s: expected array, got %s", at, compactJSON(actual)))
+			return
+		}
+		if len(a) != len(e) {
+			*out = append(*out, fmt.Sprintf("%s: expected %d items, got %d", at, len(e), len(a)))
+		}
+		for i := 0; i < len(e) && i < len(a); i++ {
+			structureDiff(joinPath(path, strconv.Itoa(i)), e[i], a[i], subset, out)
+		}
+	default:
+		if expect != actual {
+			*out = append(*out, fmt.Sprintf("%s: expected %s, got %s", at, compactJSON(expect), compactJSON(actual)))
+		}
+	}
+}
+
+// applyExpectTemplates resolves templates in an expected structure. A string
+// that is a single placeholder becomes a number or bool when the variable
+// holds one, so {{id}} can match a numeric id.
+func applyExpectTemplates(v interface{}, ctx map[string]string) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		if !strings.Contains(t, "{{") {
+			return t, nil
+		}
+		replaced, err := templ.ApplyString(t, ctx)
+		if err != nil {
+			return nil, err
+		}
+		trimmed := strings.TrimSpace(t)
+		if strings.HasPrefix(trimmed, "{{") && strings.HasSuffix(trimmed, "}}") && strings.Count(trimmed, "{{") == 1 {
+			if f, err := strconv.ParseFloat(replaced, 64); err == nil {
+				return f, nil
+			}
+			if b, err := strconv.ParseBool(replaced); err == nil {
+				return b, nil
+			}
+		}
+		return replaced, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i := range t {
+			r, err := applyExpectTemplates(t[i], ctx)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			r, err := applyExpectTemplates(item, ctx)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func jsonNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func compactJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
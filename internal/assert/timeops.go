@@ -0,0 +1,229 @@
+package assert
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"apitest/internal/config"
+	"apitest/internal/templ"
+)
+
+// fallbackLayouts are tried after RFC 3339 when no layout is given.
+var fallbackLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// assertTime implements the json time ops: before and after a reference,
+// within a duration of one, and tz for the UTC offset.
+func assertTime(a config.Assertion, res gjson.Result, ctx map[string]string) Result {
+	label := a.Path
+	if label == "" {
+		label = "(root)"
+	}
+	if !res.Exists() || res.Type == gjson.Null {
+		return Result{Pass: false, Message: fmt.Sprintf("json path %s not found", label)}
+	}
+	loc, err := timeLocation(a.Timezone)
+	if err != nil {
+		return invalidf("%v", err)
+	}
+	t, err := parseTime(res.String(), res.Type == gjson.Number, a.Layout, loc)
+	if err != nil {
+		return Result{Pass: false, Message: fmt.Sprintf("json %s: %v", label, err)}
+	}
+	shown := res.String()
+	switch a.Op {
+	case "before", "after":
+		refText, ref, err := referenceTime(fmt.Sprint(a.Expect), a.Layout, loc, ctx)
+		if err != nil {
+			return invalidf("%v", err)
+		}
+		if (a.Op == "before" && t.Before(ref)) || (a.Op == "after" && t.After(ref)) {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s %s is %s %s", label, shown, a.Op, refText)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s %s is not %s %s (%s)", label, shown, a.Op, refText, ref.Format(time.RFC3339))}
+	case "within":
+		window, err := toDuration(a.Expect)
+		if err != nil {
+			return invalidf("%v", err)
+		}
+		of := a.Of
+		if of == "" {
+			of = "now"
+		}
+		refText, ref, err := referenceTime(of, a.Layout, loc, ctx)
+		if err != nil {
+			return invalidf("%v", err)
+		}
+		delta := t.Sub(ref)
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta <= window {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s %s is within %s of %s", label, shown, window, refText)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s %s is %s away from %s, more than %s", label, shown, delta.Round(time.Millisecond), refText, window)}
+	case "tz":
+		if !hasZone(a.Layout, res) {
+			return Result{Pass: false, Message: fmt.Sprintf("json %s %s carries no timezone offset", label, shown)}
+		}
+		want, err := parseOffset(fmt.Sprint(a.Expect))
+		if err != nil {
+			return invalidf("%v", err)
+		}
+		_, offset := t.Zone()
+		if offset == want {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s offset is %s", label, formatOffset(offset))}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s offset is %s, not %s", label, formatOffset(offset), formatOffset(want))}
+	}
+	return invalidf("unknown json op %s", a.Op)
+}
+
+// timeLocation resolves the timezone option; values without a zone are UTC
+// unless told otherwise, so results do not depend on the machine's TZ.
+func timeLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "" || strings.EqualFold(name, "utc") || strings.EqualFold(name, "z"):
+		return time.UTC, nil
+	case strings.EqualFold(name, "local"):
+		return time.Local, nil
+	case name[0] == '+' || name[0] == '-':
+		offset, err := parseOffset(name)
+		if err != nil {
+			return nil, err
+		}
+		return time.FixedZone(name, offset), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", name, err)
+	}
+	return loc, nil
+}
+
+// parseTime reads a timestamp with layout, placing zoneless values in loc;
+// numbers default to epoch seconds, or milliseconds when they are too large
+// to be seconds.
+func parseTime(s string, isNumber bool, layout string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(layout) {
+	case "epoch", "epoch_s", "unix":
+		return parseEpoch(s, time.Second)
+	case "epoch_ms", "unix_ms":
+		return parseEpoch(s, time.Millisecond)
+	case "", "rfc3339":
+		if isNumber && layout == "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			if math.Abs(f) >= 1e12 {
+				return parseEpoch(s, time.Millisecond)
+			}
+			return parseEpoch(s, time.Second)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, nil
+		}
+		if layout == "" {
+			for _, l := range fallbackLayouts {
+				if t, err := time.ParseInLocation(l, s, loc); err == nil {
+					return t, nil
+				}
+			}
+		}
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time", s)
+	default:
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q does not match layout %s", s, layout)
+		}
+		return t, nil
+	}
+}
+
+func parseEpoch(s string, unit time.Duration) (time.Time, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an epoch timestamp", s)
+	}
+	return time.Unix(0, int64(f*float64(unit))), nil
+}
+
+// referenceTime resolves "now", "now-1h", "now + 5m", or a templated time
+// such as {{createdAt}} parsed like the asserted value.
+func referenceTime(expr, layout string, loc *time.Location, ctx map[string]string) (string, time.Time, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.Contains(expr, "{{") {
+		replaced, err := templ.ApplyString(expr, ctx)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("expect template: %v", err)
+		}
+		expr = replaced
+	}
+	if rest, ok := strings.CutPrefix(expr, "now"); ok {
+		ref := time.Now()
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return expr, ref, nil
+		}
+		sign := rest[0]
+		d, err := time.ParseDuration(strings.TrimSpace(rest[1:]))
+		if (sign != '+' && sign != '-') || err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid reference time %q", expr)
+		}
+		if sign == '-' {
+			d = -d
+		}
+		return expr, ref.Add(d), nil
+	}
+	_, numErr := strconv.ParseFloat(expr, 64)
+	t, err := parseTime(expr, numErr == nil, layout, loc)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("reference time: %v", err)
+	}
+	return expr, t, nil
+}
+
+// hasZone reports whether the value states its own offset: epoch numbers and
+// layouts without a zone do not.
+func hasZone(layout string, res gjson.Result) bool {
+	switch strings.ToLower(layout) {
+	case "epoch", "epoch_s", "unix", "epoch_ms", "unix_ms":
+		return false
+	case "", "rfc3339":
+		if res.Type != gjson.String {
+			return false
+		}
+		_, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(res.Str))
+		return err == nil
+	}
+	return strings.Contains(layout, "07") || strings.Contains(layout, "MST")
+}
+
+// parseOffset accepts Z, UTC, +08:00, +0800 or +08.
+func parseOffset(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "z") || strings.EqualFold(s, "utc") {
+		return 0, nil
+	}
+	for _, layout := range []string{"-07:00", "-0700", "-07"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			_, offset := t.Zone()
+			return offset, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid timezone offset %q, want Z or +08:00", s)
+}
+
+func formatOffset(offset int) string {
+	if offset == 0 {
+		return "Z"
+	}
+	return time.Unix(0, 0).In(time.FixedZone("", offset)).Format("-07:00")
+}
@@ -0,0 +1,140 @@
+package assert
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"apitest/internal/config"
+	"apitest/internal/schema"
+)
+
+// formats lists the names accepted by the json format op; anything else
+// must be written as regex:<pattern>.
+var formats = map[string]bool{
+	"uuid": true, "email": true, "date-time": true, "date": true, "time": true,
+	"ipv4": true, "ipv6": true, "hostname": true, "uri": true, "phone": true,
+}
+
+// jsonTypes are the names the type op accepts, as reported by jsonType.
+var jsonTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "bool": true, "array": true, "object": true, "null": true,
+}
+
+// assertValue implements the json ops that check the kind of a value rather
+// than compare it: type, is_null, not_empty and format.
+func assertValue(a config.Assertion, res gjson.Result) Result {
+	label := a.Path
+	if label == "" {
+		label = "(root)"
+	}
+	var wanted []string
+	if a.Op == "type" {
+		var err error
+		if wanted, err = wantedTypes(a.Expect); err != nil {
+			return invalidf("%v", err)
+		}
+	}
+	if !res.Exists() {
+		return Result{Pass: false, Message: fmt.Sprintf("json path %s not found", label)}
+	}
+	switch a.Op {
+	case "type":
+		actual := jsonType(res)
+		for _, w := range wanted {
+			if w == actual || (w == "number" && actual == "integer") {
+				return Result{Pass: true, Message: fmt.Sprintf("json %s is %s", label, w)}
+			}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s is %s, not %s", label, actual, strings.Join(wanted, " or "))}
+	case "is_null":
+		if res.Type == gjson.Null {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s is null", label)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s is %s %s, not null", label, jsonType(res), res.Raw)}
+	case "not_empty":
+		empty := false
+		switch {
+		case res.Type == gjson.Null:
+			empty = true
+		case res.Type == gjson.String:
+			empty = res.Str == ""
+		case res.IsArray():
+			empty = len(res.Array()) == 0
+		case res.IsObject():
+			empty = len(res.Map()) == 0
+		}
+		if !empty {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s is not empty", label)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s is empty: %s", label, res.Raw)}
+	case "format":
+		format := strings.TrimSpace(fmt.Sprint(a.Expect))
+		if res.Type != gjson.String {
+			return Result{Pass: false, Message: fmt.Sprintf("json %s is %s, not a %s string", label, jsonType(res), format)}
+		}
+		if pattern, ok := strings.CutPrefix(format, "regex:"); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return invalidf("invalid regex: %v", err)
+			}
+			if re.MatchString(res.Str) {
+				return Result{Pass: true, Message: fmt.Sprintf("json %s matches %s", label, pattern)}
+			}
+			return Result{Pass: false, Message: fmt.Sprintf("json %s value %q does not match %s", label, res.Str, pattern)}
+		}
+		if !formats[format] {
+			return invalidf("unknown format %s, use regex:<pattern> for custom formats", format)
+		}
+		if schema.CheckFormat(format, res.Str) {
+			return Result{Pass: true, Message: fmt.Sprintf("json %s is a valid %s", label, format)}
+		}
+		return Result{Pass: false, Message: fmt.Sprintf("json %s value %q is not a valid %s", label, res.Str, format)}
+	}
+	return invalidf("unknown json op %s", a.Op)
+}
+
+// wantedTypes normalizes the expect of the type op, a name or a list.
+func wantedTypes(expect interface{}) ([]string, error) {
+	items := []interface{}{expect}
+	if list, ok := expect.([]interface{}); ok {
+		items = list
+	}
+	wanted := make([]string, 0, len(items))
+	for _, item := range items {
+		w := strings.ToLower(strings.TrimSpace(fmt.Sprint(item)))
+		if w == "boolean" {
+			w = "bool"
+		}
+		if !jsonTypes[w] {
+			return nil, fmt.Errorf("unknown json type %v, want string, number, integer, bool, array, object or null", item)
+		}
+		wanted = append(wanted, w)
+	}
+	return wanted, nil
+}
+
+// jsonType names the JSON type of res, telling integers apart from other
+// numbers.
+func jsonType(res gjson.Result) string {
+	switch {
+	case res.Type == gjson.Null:
+		return "null"
+	case res.Type == gjson.True || res.Type == gjson.False:
+		return "bool"
+	case res.Type == gjson.String:
+		return "string"
+	case res.Type == gjson.Number:
+		if res.Num == math.Trunc(res.Num) && !math.IsInf(res.Num, 0) {
+			return "integer"
+		}
+		return "number"
+	case res.IsArray():
+		return "array"
+	default:
+		return "object"
+	}
+}
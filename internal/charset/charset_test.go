@@ -0,0 +1,33 @@
+package charset
+
+import "testing"
+
+func TestDecodeGB18030(t *testing.T) {
+	cases := map[string]string{
+		"\xd6\xd0\xce\xc4\xb2\xe2\xca\xd4": "中文测试",
+		"\x95\x32\x82\x36\xa8\xa6":         "𠀀é",
+		"\x81\x30\x84\x36":                 "¥",
+		"ok \x80":                          "ok €",
+	}
+	for in, expect := range cases {
+		got, ok := Decode("GBK", []byte(in))
+		if !ok || got != expect {
+			t.Fatalf("decode %q => %q, expect %q", in, got, expect)
+		}
+	}
+	if got, _ := Decode("gb18030", []byte("\xd6")); got != "�" {
+		t.Fatalf("truncated sequence should decode to replacement char, got %q", got)
+	}
+}
+
+func TestDecodeSingleByte(t *testing.T) {
+	if got, _ := Decode("ISO-8859-1", []byte("caf\xe9")); got != "café" {
+		t.Fatalf("latin-1 decode got %q", got)
+	}
+	if got, _ := Decode("windows-1252", []byte("\x93hi\x94")); got != "“hi”" {
+		t.Fatalf("windows-1252 decode got %q", got)
+	}
+	if _, ok := Decode("koi8-r", []byte("x")); ok {
+		t.Fatalf("expected unsupported charset")
+	}
+}
@@ -1,8 +1,14 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +21,51 @@ type Plan struct {
 	BaseURL string                 `yaml:"base_url" json:"base_url"`
 	Vars    map[string]interface{} `yaml:"vars" json:"vars"`
 	Steps   []Step                 `yaml:"steps" json:"steps"`
+	// MaxResponseSize is the default in-memory body limit for steps that set none.
+	MaxResponseSize ByteSize `yaml:"max_response_size" json:"max_response_size"`
+	// Auth applies to every step whose request has no auth block of its own.
+	Auth *Auth `yaml:"auth" json:"auth"`
+	// Sign applies to every step whose request has no sign block of its own.
+	Sign *Sign `yaml:"sign" json:"sign"`
+	// RateLimit throttles every request of the run, shared across steps.
+	RateLimit *RateLimit `yaml:"rate_limit" json:"rate_limit"`
+	// Retry applies to every step whose request has no retry block of its own.
+	Retry *Retry `yaml:"retry" json:"retry"`
+	// LatencyBudget fails any step whose response takes longer, unless the
+	// step sets its own budget.
+	LatencyBudget Duration `yaml:"latency_budget" json:"latency_budget"`
+	// OpenAPI is a spec file that every HTTP step is validated against.
+	OpenAPI string `yaml:"openapi" json:"openapi"`
+	// Soft evaluates every assertion of every step instead of stopping at
+	// the first failure.
+	Soft bool `yaml:"soft" json:"soft"`
+	// ExpectStatus is checked on every HTTP step without a status assertion
+	// of its own: a code (200), a class ("2xx") or a list of either.
+	ExpectStatus interface{} `yaml:"expect_status" json:"expect_status"`
+	// Dir is the directory of the plan file, set by LoadPlan.
+	Dir string `yaml:"-" json:"-"`
+}
+
+// RateLimit is a token bucket: Rate requests per period with up to Burst sent
+// back to back. Services adds limits for individual hosts on top of the
+// run-wide one.
+type RateLimit struct {
+	Rate     Rate                 `yaml:"rate" json:"rate"`
+	Burst    int                  `yaml:"burst" json:"burst"`
+	Services map[string]RateLimit `yaml:"services" json:"services"`
+}
+
+// Retry re-sends requests answered with one of the On statuses (429 and 503
+// by default), waiting as long as Retry-After asks. A Retry-After longer than
+// MaxWaitMS is not honoured and the response is kept. BackoffMS is the first
+// delay when the server gives no hint; it doubles on every attempt.
+type Retry struct {
+	On         []int `yaml:"on" json:"on"`
+	MaxRetries int   `yaml:"max_retries" json:"max_retries"`
+	MaxWaitMS  int   `yaml:"max_wait_ms" json:"max_wait_ms"`
+	BackoffMS  int   `yaml:"backoff_ms" json:"backoff_ms"`
+	// Disabled turns off a plan-level retry for one request.
+	Disabled bool `yaml:"disabled" json:"disabled"`
 }
 
 // Step describes a single request/assert sequence.
@@ -23,16 +74,102 @@ type Step struct {
 	Request Request                      `yaml:"request" json:"request"`
 	Extract map[string]ExtractDefinition `yaml:"extract" json:"extract"`
 	Assert  []Assertion                  `yaml:"assert" json:"assert"`
+	// WebSocket turns the step into a websocket session; Request is then unused
+	// and Assert/Extract apply to the handshake response.
+	WebSocket *WebSocket `yaml:"websocket" json:"websocket"`
+	// SSE reads the response of Request as a server-sent event stream; Assert
+	// and Extract then see the collected events as a JSON document.
+	SSE *SSE `yaml:"sse" json:"sse"`
+	// LatencyBudget overrides the plan-level budget for this step.
+	LatencyBudget Duration `yaml:"latency_budget" json:"latency_budget"`
+	// Soft evaluates all of Assert even after a failure.
+	Soft bool `yaml:"soft" json:"soft"`
+}
+
+// SSE controls when event collection stops: after MaxEvents, after TimeoutMS,
+// when an event satisfies every Until assertion, or when the server closes
+// the stream.
+type SSE struct {
+	MaxEvents int `yaml:"max_events" json:"max_events"`
+	// TimeoutMS is the collection window; it defaults to the request timeout.
+	TimeoutMS int `yaml:"timeout_ms" json:"timeout_ms"`
+	// Until is evaluated against each event as {"event", "id", "data", "json"}.
+	Until []Assertion `yaml:"until" json:"until"`
+}
+
+// WebSocket connects to URL and runs Script in order.
+type WebSocket struct {
+	URL          string   `yaml:"url" json:"url"`
+	Headers      Params   `yaml:"headers" json:"headers"`
+	Subprotocols []string `yaml:"subprotocols" json:"subprotocols"`
+	// TimeoutMS bounds the handshake and is the default wait for expect actions.
+	TimeoutMS int               `yaml:"timeout_ms" json:"timeout_ms"`
+	Script    []WebSocketAction `yaml:"script" json:"script"`
+}
+
+// WebSocketAction is one script entry; exactly one of Send, Expect, SleepMS or
+// Close is set.
+type WebSocketAction struct {
+	// Send is a templated text message; mappings and lists are sent as JSON.
+	Send    interface{}      `yaml:"send" json:"send"`
+	Expect  *WebSocketExpect `yaml:"expect" json:"expect"`
+	SleepMS int              `yaml:"sleep_ms" json:"sleep_ms"`
+	Close   bool             `yaml:"close" json:"close"`
+}
+
+// WebSocketExpect waits for a message and checks it like a response body.
+type WebSocketExpect struct {
+	TimeoutMS int                          `yaml:"timeout_ms" json:"timeout_ms"`
+	Assert    []Assertion                  `yaml:"assert" json:"assert"`
+	Extract   map[string]ExtractDefinition `yaml:"extract" json:"extract"`
+	// SkipUnmatched discards messages failing the assertions until one passes
+	// or the timeout expires.
+	SkipUnmatched bool `yaml:"skip_unmatched" json:"skip_unmatched"`
 }
 
 // Request describes HTTP request properties.
 type Request struct {
-	Method    string                 `yaml:"method" json:"method"`
-	URL       string                 `yaml:"url" json:"url"`
-	Headers   map[string]string      `yaml:"headers" json:"headers"`
-	Query     map[string]interface{} `yaml:"query" json:"query"`
-	Body      *RequestBody           `yaml:"body" json:"body"`
-	TimeoutMS int                    `yaml:"timeout_ms" json:"timeout_ms"`
+	Method    string       `yaml:"method" json:"method"`
+	URL       string       `yaml:"url" json:"url"`
+	Headers   Params       `yaml:"headers" json:"headers"`
+	Query     Params       `yaml:"query" json:"query"`
+	Body      *RequestBody `yaml:"body" json:"body"`
+	TimeoutMS int          `yaml:"timeout_ms" json:"timeout_ms"`
+	// ArrayFormat encodes multi-valued query parameters: repeat (ids=1&ids=2,
+	// the default), comma (ids=1,2) or brackets (ids[]=1&ids[]=2).
+	ArrayFormat string `yaml:"array_format" json:"array_format"`
+	// MaxResponseSize limits how much of the body is kept in memory for assertions.
+	MaxResponseSize ByteSize `yaml:"max_response_size" json:"max_response_size"`
+	// SaveTo streams the response body to this file instead of keeping it in memory.
+	SaveTo string `yaml:"save_to" json:"save_to"`
+	// ResponseCharset overrides the charset announced in the response Content-Type.
+	ResponseCharset string `yaml:"response_charset" json:"response_charset"`
+	// DisableDecompression keeps gzip/deflate response bodies as received.
+	DisableDecompression bool  `yaml:"disable_decompression" json:"disable_decompression"`
+	Auth                 *Auth `yaml:"auth" json:"auth"`
+	Sign                 *Sign `yaml:"sign" json:"sign"`
+	// Retry enables automatic retries on throttling responses.
+	Retry *Retry `yaml:"retry" json:"retry"`
+}
+
+// Auth describes built-in authentication. Type is one of basic, bearer, digest,
+// api_key, oauth2_client_credentials, oauth2_password or none. All string
+// fields accept templates.
+type Auth struct {
+	Type     string `yaml:"type" json:"type"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	Token    string `yaml:"token" json:"token"`
+	// api_key: Name is the header or query parameter, In is "header" (default) or "query".
+	Name  string `yaml:"name" json:"name"`
+	In    string `yaml:"in" json:"in"`
+	Value string `yaml:"value" json:"value"`
+	// oauth2_*: ClientAuth is "basic" (default) or "body" for sending client credentials.
+	TokenURL     string `yaml:"token_url" json:"token_url"`
+	ClientID     string `yaml:"client_id" json:"client_id"`
+	ClientSecret string `yaml:"client_secret" json:"client_secret"`
+	Scope        string `yaml:"scope" json:"scope"`
+	ClientAuth   string `yaml:"client_auth" json:"client_auth"`
 }
 
 // RequestBody holds mutually exclusive body encodings.
@@ -40,6 +177,12 @@ type RequestBody struct {
 	Raw  string                 `yaml:"raw" json:"raw"`
 	JSON interface{}            `yaml:"json" json:"json"`
 	Form map[string]interface{} `yaml:"form" json:"form"`
+	// XML is either a templated XML string or a mapping rendered as XML.
+	XML *XMLValue `yaml:"xml" json:"xml"`
+	// SOAP wraps header and body content in a SOAP envelope.
+	SOAP *SOAPEnvelope `yaml:"soap" json:"soap"`
+	// Compress encodes the payload with gzip or deflate and sets Content-Encoding.
+	Compress string `yaml:"compress" json:"compress"`
 }
 
 // ExtractDefinition describes how to extract variables from response.
@@ -47,6 +190,8 @@ type ExtractDefinition struct {
 	From  string `yaml:"from" json:"from"`
 	Path  string `yaml:"path" json:"path"`
 	Group int    `yaml:"group" json:"group"`
+	// Namespaces maps XPath prefixes to namespace URIs for from: xpath.
+	Namespaces map[string]string `yaml:"namespaces" json:"namespaces"`
 }
 
 // Assertion describes supported assertion types.
@@ -56,6 +201,410 @@ type Assertion struct {
 	Expect interface{} `yaml:"expect" json:"expect"`
 	Name   string      `yaml:"name" json:"name"`
 	Path   string      `yaml:"path" json:"path"`
+	// Namespaces maps XPath prefixes to namespace URIs for xml assertions.
+	Namespaces map[string]string `yaml:"namespaces" json:"namespaces"`
+	// Schema is an inline JSON Schema for schema assertions; SchemaFile loads
+	// one from a JSON or YAML file instead.
+	Schema     interface{} `yaml:"schema" json:"schema"`
+	SchemaFile string      `yaml:"schema_file" json:"schema_file"`
+	// Ignore lists json paths masked before comparing snapshots; "#" matches
+	// every array element, e.g. data.rows.#.id.
+	Ignore []string `yaml:"ignore" json:"ignore"`
+	// Assert holds the sub-assertions of the every, any and none array ops;
+	// their paths are relative to each element.
+	Assert []Assertion `yaml:"assert" json:"assert"`
+	// Not, AnyOf and AllOf make the assertion a wrapper around nested ones:
+	// it passes when Not fails, when any of AnyOf passes or when all of AllOf
+	// pass. Type is unused then.
+	Not   *Assertion  `yaml:"not" json:"not"`
+	AnyOf []Assertion `yaml:"any_of" json:"any_of"`
+	AllOf []Assertion `yaml:"all_of" json:"all_of"`
+	// By is the element field compared by unique_by and sorted_by; empty
+	// means the element itself.
+	By string `yaml:"by" json:"by"`
+	// Coerce converts the json value before comparing; "number" parses
+	// numeric strings such as "42".
+	Coerce string `yaml:"coerce" json:"coerce"`
+	// Tolerance bounds the approx op: a number is absolute, "0.5%" is
+	// relative to expect.
+	Tolerance interface{} `yaml:"tolerance" json:"tolerance"`
+	// Layout parses the value for time ops: rfc3339 (the default also tries
+	// "2006-01-02 15:04:05" and epoch numbers), epoch, epoch_ms or a Go
+	// layout. Of is the reference time of within, "now" by default.
+	Layout string `yaml:"layout" json:"layout"`
+	Of     string `yaml:"of" json:"of"`
+	// Timezone places times whose layout has no zone: UTC by default, an
+	// offset such as +08:00, an IANA name or Local.
+	Timezone string `yaml:"timezone" json:"timezone"`
+	// BaseDir is the directory of the plan file, set by LoadPlan; relative
+	// schema files and $refs resolve against it.
+	BaseDir string `yaml:"-" json:"-"`
+	// SnapshotFile is the golden file path without extension and
+	// UpdateSnapshot rewrites it; both are set by the runner.
+	SnapshotFile   string `yaml:"-" json:"-"`
+	UpdateSnapshot bool   `yaml:"-" json:"-"`
+}
+
+// SOAPEnvelope describes a SOAP 1.1 or 1.2 request.
+type SOAPEnvelope struct {
+	// Version is 1.1 (default, text/xml with a SOAPAction header) or 1.2;
+	// it accepts both the YAML number and a quoted string.
+	Version json.Number `yaml:"version" json:"version"`
+	Action  string      `yaml:"action" json:"action"`
+	Header  *XMLValue   `yaml:"header" json:"header"`
+	Body    *XMLValue   `yaml:"body" json:"body"`
+}
+
+// XMLValue is an XML body written in YAML. A scalar is a templated XML
+// fragment; a mapping becomes elements in declaration order, where "@name"
+// keys are attributes, "#text" is the element text and list values repeat
+// the element.
+type XMLValue struct {
+	Text   string
+	Fields []XMLField
+	List   []XMLValue
+}
+
+// XMLField is one key of an XMLValue mapping.
+type XMLField struct {
+	Name  string
+	Value XMLValue
+}
+
+// IsMapping reports whether the value was written as a mapping.
+func (v XMLValue) IsMapping() bool {
+	return v.Fields != nil
+}
+
+// UnmarshalJSON decodes the value keeping mapping keys in order.
+func (v *XMLValue) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeXMLValue(dec, v)
+}
+
+func decodeXMLValue(dec *json.Decoder, v *XMLValue) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			v.Fields = []XMLField{}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				field := XMLField{Name: keyTok.(string)}
+				if err := decodeXMLValue(dec, &field.Value); err != nil {
+					return err
+				}
+				v.Fields = append(v.Fields, field)
+			}
+		case '[':
+			v.List = []XMLValue{}
+			for dec.More() {
+				var item XMLValue
+				if err := decodeXMLValue(dec, &item); err != nil {
+					return err
+				}
+				v.List = append(v.List, item)
+			}
+		}
+		_, err = dec.Token()
+		return err
+	case nil:
+		return nil
+	default:
+		v.Text = paramString(t)
+		return nil
+	}
+}
+
+// Param is a named request parameter or header that may carry several values.
+type Param struct {
+	Name   string
+	Values []string
+}
+
+// Params is an ordered parameter list decoded from a YAML mapping; list values
+// become repeated keys and declaration order is preserved.
+type Params []Param
+
+// Get returns the first value of the named parameter.
+func (p Params) Get(name string) string {
+	for _, item := range p {
+		if item.Name == name && len(item.Values) > 0 {
+			return item.Values[0]
+		}
+	}
+	return ""
+}
+
+// Set replaces the values of the named parameter in place, appending it when
+// it is not present yet.
+func (p *Params) Set(name string, values ...string) {
+	for i := range *p {
+		if (*p)[i].Name == name {
+			(*p)[i].Values = values
+			return
+		}
+	}
+	*p = append(*p, Param{Name: name, Values: values})
+}
+
+// UnmarshalJSON decodes a mapping in key order, keeping numbers as written.
+func (p *Params) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*p = nil
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("params must be a mapping")
+	}
+	var out Params
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var raw interface{}
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		param := Param{Name: keyTok.(string)}
+		switch v := raw.(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range v {
+				param.Values = append(param.Values, paramString(item))
+			}
+		default:
+			param.Values = []string{paramString(v)}
+		}
+		out = append(out, param)
+	}
+	*p = out
+	return nil
+}
+
+// MarshalJSON encodes params as a mapping, using lists for repeated values.
+func (p Params) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, item := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(item.Name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		var val []byte
+		if len(item.Values) == 1 {
+			val, _ = json.Marshal(item.Values[0])
+		} else {
+			val, _ = json.Marshal(item.Values)
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func paramString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(val)
+		return string(b)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// ByteSize is a byte count that accepts plain numbers or strings like "512KB" or "50MB".
+type ByteSize int64
+
+// UnmarshalJSON parses numeric or human readable sizes.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case nil:
+		*b = 0
+	case float64:
+		*b = ByteSize(v)
+	case string:
+		n, err := ParseByteSize(v)
+		if err != nil {
+			return err
+		}
+		*b = ByteSize(n)
+	default:
+		return fmt.Errorf("invalid size %v", raw)
+	}
+	return nil
+}
+
+// ParseByteSize parses sizes such as "1024", "64KB", "50MB" or "1GiB"; units are powers of 1024.
+func ParseByteSize(s string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	mult := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(text, u.suffix) {
+			mult = u.mult
+			text = strings.TrimSpace(strings.TrimSuffix(text, u.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// Duration accepts Go duration strings such as "500ms" or plain numbers of milliseconds.
+type Duration time.Duration
+
+// UnmarshalJSON parses duration strings or millisecond numbers.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case nil:
+		*d = 0
+	case float64:
+		*d = Duration(v * float64(time.Millisecond))
+	case string:
+		parsed, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %v", raw)
+	}
+	return nil
+}
+
+// Rate is a request rate in requests per second, written as "10/s", "600/m",
+// "3600/h" or "1/500ms".
+type Rate float64
+
+// UnmarshalJSON parses rates given as strings or plain per-second numbers.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case nil:
+		*r = 0
+	case float64:
+		*r = Rate(v)
+	case string:
+		n, err := ParseRate(v)
+		if err != nil {
+			return err
+		}
+		*r = Rate(n)
+	default:
+		return fmt.Errorf("invalid rate %v", raw)
+	}
+	return nil
+}
+
+// ParseRate parses "n/s", "n/m", "n/h" or "n/<duration>" into requests per second.
+func ParseRate(s string) (float64, error) {
+	count, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid rate %q, expect n/s, n/m, n/h or n/<duration>", s)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	var window time.Duration
+	switch strings.TrimSpace(per) {
+	case "s", "sec", "second":
+		window = time.Second
+	case "m", "min", "minute":
+		window = time.Minute
+	case "h", "hour":
+		window = time.Hour
+	default:
+		window, err = time.ParseDuration(strings.TrimSpace(per))
+		if err != nil || window <= 0 {
+			return 0, fmt.Errorf("invalid rate %q", s)
+		}
+	}
+	return n / window.Seconds(), nil
+}
+
+// Sign describes request signing, applied after templates are resolved.
+// Scheme is hmac, sigv4, custom or none.
+type Sign struct {
+	Scheme string `yaml:"scheme" json:"scheme"`
+	KeyID  string `yaml:"key_id" json:"key_id"`
+	Secret string `yaml:"secret" json:"secret"`
+	// Algorithm is hmac-sha256 (default), hmac-sha1, hmac-sha512, hmac-md5, sha256, sha1 or md5.
+	Algorithm string `yaml:"algorithm" json:"algorithm"`
+	// Encoding of the signature: hex (default), hex_upper or base64.
+	Encoding string `yaml:"encoding" json:"encoding"`
+	// Components build the hmac canonical string: method, path, query, timestamp,
+	// nonce, key_id, body, body_sha256, body_md5 or header:<Name>.
+	Components      []string `yaml:"components" json:"components"`
+	Separator       *string  `yaml:"separator" json:"separator"`
+	TimestampFormat string   `yaml:"timestamp_format" json:"timestamp_format"`
+	// StringToSign is the template used by the custom scheme.
+	StringToSign string `yaml:"string_to_sign" json:"string_to_sign"`
+	// Headers and Query are templates injected into the request; they can use
+	// {{signature}}, {{timestamp}}, {{nonce}} and {{key_id}}.
+	Headers map[string]string `yaml:"headers" json:"headers"`
+	Query   map[string]string `yaml:"query" json:"query"`
+	// sigv4 settings.
+	Region       string `yaml:"region" json:"region"`
+	Service      string `yaml:"service" json:"service"`
+	SessionToken string `yaml:"session_token" json:"session_token"`
+}
+
+func setBaseDir(assertions []Assertion, dir string) {
+	for i := range assertions {
+		assertions[i].BaseDir = dir
+		setBaseDir(assertions[i].Assert, dir)
+		setBaseDir(assertions[i].AnyOf, dir)
+		setBaseDir(assertions[i].AllOf, dir)
+		if assertions[i].Not != nil {
+			not := []Assertion{*assertions[i].Not}
+			setBaseDir(not, dir)
+			assertions[i].Not = &not[0]
+		}
+	}
 }
 
 // LoadPlan loads a YAML plan from file path.
@@ -75,6 +624,32 @@ func LoadPlan(path string) (*Plan, error) {
 		if p.Steps[i].Request.TimeoutMS == 0 {
 			p.Steps[i].Request.TimeoutMS = DefaultTimeoutMS
 		}
+		if ws := p.Steps[i].WebSocket; ws != nil && ws.TimeoutMS == 0 {
+			ws.TimeoutMS = DefaultTimeoutMS
+		}
+	}
+	dir := filepath.Dir(path)
+	p.Dir = dir
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		setBaseDir(step.Assert, dir)
+		if step.SSE != nil {
+			setBaseDir(step.SSE.Until, dir)
+		}
+		if step.WebSocket != nil {
+			for _, action := range step.WebSocket.Script {
+				if action.Expect != nil {
+					setBaseDir(action.Expect.Assert, dir)
+				}
+			}
+		}
+	}
+	if p.RateLimit != nil {
+		for host, svc := range p.RateLimit.Services {
+			if svc.Rate <= 0 {
+				return nil, fmt.Errorf("rate_limit service %s: rate is required", host)
+			}
+		}
 	}
 	return &p, nil
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -19,3 +20,209 @@ func TestLoadPlanBaseURL(t *testing.T) {
 		t.Fatalf("unexpected base url %s", p.BaseURL)
 	}
 }
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{
+		"1024":  1024,
+		"64KB":  64 << 10,
+		"50 MB": 50 << 20,
+		"1GiB":  1 << 30,
+		"1.5k":  1536,
+	}
+	for in, expect := range cases {
+		got, err := ParseByteSize(in)
+		if err != nil || got != expect {
+			t.Fatalf("parse %q => %d (%v), expect %d", in, got, err, expect)
+		}
+	}
+	if _, err := ParseByteSize("lots"); err == nil {
+		t.Fatalf("expected error for invalid size")
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]float64{
+		"10/s":    10,
+		"600/m":   10,
+		"3600/h":  1,
+		"1/500ms": 2,
+	}
+	for in, expect := range cases {
+		got, err := ParseRate(in)
+		if err != nil || got != expect {
+			t.Fatalf("parse %q => %v (%v), expect %v", in, got, err, expect)
+		}
+	}
+	for _, in := range []string{"10", "0/s", "5/fortnight"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+}
+
+func TestLoadPlanParamsKeepOrder(t *testing.T) {
+	data := []byte(`name: test
+steps:
+  - name: s1
+    request:
+      url: /items
+      query:
+        sort: desc
+        ids: [1, 2, 3]
+        tags:
+          - "a b"
+          - c
+        limit: 13021788888
+      headers:
+        X-Trace: "{{trace}}"
+        Accept:
+          - application/json
+          - text/plain
+`)
+	path := t.TempDir() + "/plan.yaml"
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	p, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	q := p.Steps[0].Request.Query
+	if len(q) != 4 || q[0].Name != "sort" || q[1].Name != "ids" || q[2].Name != "tags" || q[3].Name != "limit" {
+		t.Fatalf("query order not preserved: %+v", q)
+	}
+	if len(q[1].Values) != 3 || q[1].Values[2] != "3" || q[2].Values[0] != "a b" || q[3].Values[0] != "13021788888" {
+		t.Fatalf("unexpected query values: %+v", q)
+	}
+	h := p.Steps[0].Request.Headers
+	if h.Get("X-Trace") != "{{trace}}" || len(h[1].Values) != 2 {
+		t.Fatalf("unexpected headers: %+v", h)
+	}
+}
+
+func TestLoadPlanXMLBodies(t *testing.T) {
+	data := []byte(`name: test
+steps:
+  - name: raw
+    request:
+      url: /a
+      body:
+        xml: |
+          <order id="{{id}}">
+            <item>a</item>
+          </order>
+  - name: soap
+    request:
+      url: /b
+      body:
+        soap:
+          version: 1.2
+          action: urn:Get
+          body:
+            m:Get:
+              "@xmlns:m": urn:shop
+              m:Id: 7
+              m:Note:
+                "@lang": en
+                "#text": hi
+`)
+	path := t.TempDir() + "/plan.yaml"
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	p, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	raw := p.Steps[0].Request.Body.XML
+	if raw == nil || raw.IsMapping() || raw.Text != "<order id=\"{{id}}\">\n  <item>a</item>\n</order>\n" {
+		t.Fatalf("block scalar xml: %#v", raw)
+	}
+	soap := p.Steps[1].Request.Body.SOAP
+	if soap == nil || soap.Version != "1.2" || soap.Action != "urn:Get" {
+		t.Fatalf("soap: %#v", soap)
+	}
+	get := soap.Body.Fields[0]
+	if get.Name != "m:Get" || len(get.Value.Fields) != 3 || get.Value.Fields[0].Name != "@xmlns:m" ||
+		get.Value.Fields[1].Value.Text != "7" || get.Value.Fields[2].Value.Fields[1].Name != "#text" {
+		t.Fatalf("soap body fields: %#v", get)
+	}
+}
+
+func TestLoadPlanSchemaAssertion(t *testing.T) {
+	data := []byte(`name: test
+steps:
+  - name: user
+    request:
+      url: /user
+    assert:
+      - type: schema
+        path: data
+        schema:
+          type: object
+          required: [id]
+          properties:
+            id:
+              $ref: "#/$defs/id"
+          $defs:
+            id:
+              type: integer
+      - type: schema
+        schema_file: schemas/user.json
+`)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plan.yaml")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	p, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	a := p.Steps[0].Assert
+	inline, ok := a[0].Schema.(map[string]interface{})
+	if !ok || inline["$defs"] == nil || a[0].BaseDir != dir {
+		t.Fatalf("unexpected inline schema %#v (dir %q)", a[0].Schema, a[0].BaseDir)
+	}
+	if a[1].SchemaFile != "schemas/user.json" || a[1].BaseDir != dir {
+		t.Fatalf("unexpected schema file %+v", a[1])
+	}
+}
+
+func TestLoadPlanFlowTextInStringFields(t *testing.T) {
+	data := []byte(`name: test
+steps:
+  - name: s1
+    request:
+      method: POST
+      url: /items
+      body:
+        raw: {"a": 1}
+      sign:
+        type: hmac
+        headers:
+          X-Meta: [v1, v2]
+    assert:
+      - type: status
+        op: in
+        expect: [200, 201]
+`)
+	path := t.TempDir() + "/plan.yaml"
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	p, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	req := p.Steps[0].Request
+	if req.Body == nil || req.Body.Raw != `{"a": 1}` {
+		t.Fatalf("raw body should keep the flow text, got %+v", req.Body)
+	}
+	if req.Sign.Headers["X-Meta"] != "[v1, v2]" {
+		t.Fatalf("string header should keep the flow text, got %q", req.Sign.Headers["X-Meta"])
+	}
+	if list, ok := p.Steps[0].Assert[0].Expect.([]interface{}); !ok || len(list) != 2 {
+		t.Fatalf("untyped expect should still be a list, got %#v", p.Steps[0].Assert[0].Expect)
+	}
+}
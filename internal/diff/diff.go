@@ -0,0 +1,185 @@
+// Package diff renders line-based unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type edit struct {
+	kind opKind
+	a, b int // line indexes in a and b
+}
+
+// Unified returns a unified diff of a and b with context lines around each
+// change, or "" when they have the same lines.
+func Unified(aName, bName, a, b string, context int) string {
+	al, bl := splitLines(a), splitLines(b)
+	edits := editScript(al, bl)
+	changed := false
+	for _, e := range edits {
+		if e.kind != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(edits); {
+		// find the next change
+		for start < len(edits) && edits[start].kind == opEqual {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		from := max(start-context, 0)
+		end := start
+		// extend the hunk while changes are closer than 2*context apart
+		for i := start; i < len(edits); i++ {
+			if edits[i].kind != opEqual {
+				end = i
+				continue
+			}
+			if i-end > 2*context {
+				break
+			}
+		}
+		to := min(end+context+1, len(edits))
+		writeHunk(&sb, al, bl, edits[from:to])
+		start = to
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, a, b []string, edits []edit) {
+	aStart, bStart := -1, -1
+	aCount, bCount := 0, 0
+	for _, e := range edits {
+		if e.kind != opInsert {
+			if aStart < 0 {
+				aStart = e.a
+			}
+			aCount++
+		}
+		if e.kind != opDelete {
+			if bStart < 0 {
+				bStart = e.b
+			}
+			bCount++
+		}
+	}
+	// an empty range is reported at the line before it
+	if aStart < 0 {
+		aStart = edits[0].a - 1
+	}
+	if bStart < 0 {
+		bStart = edits[0].b - 1
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, e := range edits {
+		switch e.kind {
+		case opEqual:
+			sb.WriteString(" " + a[e.a] + "\n")
+		case opDelete:
+			sb.WriteString("-" + a[e.a] + "\n")
+		case opInsert:
+			sb.WriteString("+" + b[e.b] + "\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// editScript computes a shortest edit script with Myers' algorithm. Each
+// edit carries the current line index in both inputs so hunks can report
+// positions even for runs of insertions or deletions.
+func editScript(a, b []string) []edit {
+	n, m := len(a), len(b)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m, offset)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, n, m, offset int) []edit {
+	var out []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			out = append(out, edit{kind: opEqual, a: x, b: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				out = append(out, edit{kind: opInsert, a: x, b: y})
+			} else {
+				x--
+				out = append(out, edit{kind: opDelete, a: x, b: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	a := "{\n  \"id\": 1,\n  \"name\": \"bob\",\n  \"role\": \"user\",\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3,\n  \"d\": 4,\n  \"e\": 5,\n  \"f\": 6,\n  \"g\": 7,\n  \"tail\": true\n}\n"
+	b := "{\n  \"id\": 1,\n  \"name\": \"alice\",\n  \"role\": \"user\",\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3,\n  \"d\": 4,\n  \"e\": 5,\n  \"f\": 6,\n  \"g\": 7,\n  \"extra\": null,\n  \"tail\": true\n}\n"
+	want := `--- snapshot
++++ response
+@@ -1,5 +1,5 @@
+ {
+   "id": 1,
+-  "name": "bob",
++  "name": "alice",
+   "role": "user",
+   "a": 1,
+@@ -10,4 +10,5 @@
+   "f": 6,
+   "g": 7,
++  "extra": null,
+   "tail": true
+ }
+`
+	if got := Unified("snapshot", "response", a, b, 2); got != want {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+	if Unified("a", "b", "x\n", "x", 3) != "" {
+		t.Fatalf("expected no diff for equal input ignoring trailing newline")
+	}
+}
+
+func TestUnifiedEdges(t *testing.T) {
+	if got := Unified("a", "b", "", "one\ntwo", 3); got != "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+one\n+two\n" {
+		t.Fatalf("unexpected insert-only diff:\n%s", got)
+	}
+	if got := Unified("a", "b", "one\ntwo\nthree", "one\nthree", 0); got != "--- a\n+++ b\n@@ -2 +1,0 @@\n-two\n" {
+		t.Fatalf("unexpected delete diff:\n%s", got)
+	}
+}
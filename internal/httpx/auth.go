@@ -0,0 +1,427 @@
+package httpx
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"apitest/internal/config"
+	"apitest/internal/templ"
+)
+
+const (
+	maskedValue = "[masked]"
+	// tokenRefreshSkew refreshes OAuth2 tokens this long before they expire.
+	tokenRefreshSkew = 30 * time.Second
+)
+
+// now is replaceable in tests that exercise token expiry.
+var now = time.Now
+
+type oauthToken struct {
+	access    string
+	tokenType string
+	refresh   string
+	expiry    time.Time
+}
+
+func (t *oauthToken) valid() bool {
+	if t.expiry.IsZero() {
+		return true
+	}
+	return now().Add(tokenRefreshSkew).Before(t.expiry)
+}
+
+// TokenCache keeps OAuth2 tokens so a run fetches each token once and
+// refreshes it only when it is about to expire. Share one per run.
+type TokenCache struct {
+	mu      sync.Mutex
+	entries map[string]*tokenEntry
+}
+
+// tokenEntry serialises fetches for one credential set without blocking
+// steps that use other credentials.
+type tokenEntry struct {
+	mu  sync.Mutex
+	tok *oauthToken
+}
+
+// NewTokenCache returns an empty token cache.
+func NewTokenCache() *TokenCache {
+	return &TokenCache{entries: map[string]*tokenEntry{}}
+}
+
+func (c *TokenCache) entry(key string) *tokenEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[key]
+	if e == nil {
+		e = &tokenEntry{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// tokenKey identifies a credential set. Secrets are hashed into the key so a
+// step probing bad credentials never reuses a token issued for good ones.
+func tokenKey(a config.Auth) string {
+	secret := sha256.Sum256([]byte(a.Password + "\x00" + a.ClientSecret))
+	return strings.Join([]string{a.Type, a.TokenURL, a.ClientID, a.ClientAuth, a.Username, a.Scope, hex.EncodeToString(secret[:])}, "|")
+}
+
+// digestAuth answers a Digest challenge on the second round trip.
+type digestAuth struct {
+	username string
+	password string
+}
+
+// applyAuth resolves the auth block and decorates the outgoing request, masking
+// credentials in ri. A non-nil digestAuth means the request must be retried once
+// the server has sent its challenge.
+func applyAuth(ctx context.Context, client *http.Client, auth *config.Auth, req *http.Request, vars map[string]string, ri *RequestInfo) (*digestAuth, error) {
+	if auth == nil {
+		return nil, nil
+	}
+	a, err := resolveAuth(*auth, vars)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(a.Type) {
+	case "", "none":
+		return nil, nil
+	case "basic":
+		req.SetBasicAuth(a.Username, a.Password)
+		ri.Headers.Set("Authorization", "Basic "+maskedValue)
+		ri.hide(strings.TrimPrefix(req.Header.Get("Authorization"), "Basic "))
+		ri.hide(a.Password)
+	case "bearer":
+		if a.Token == "" {
+			return nil, errors.New("bearer auth requires token")
+		}
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+		ri.Headers.Set("Authorization", "Bearer "+maskedValue)
+		ri.hide(a.Token)
+	case "digest":
+		return &digestAuth{username: a.Username, password: a.Password}, nil
+	case "api_key":
+		if a.Name == "" {
+			return nil, errors.New("api_key auth requires name")
+		}
+		switch strings.ToLower(a.In) {
+		case "", "header":
+			req.Header.Set(a.Name, a.Value)
+			ri.Headers.Set(a.Name, maskedValue)
+			ri.hide(a.Value)
+		case "query":
+			shown := *req.URL
+			req.URL.RawQuery = appendQuery(req.URL.RawQuery, a.Name, a.Value)
+			shown.RawQuery = appendQuery(shown.RawQuery, a.Name, maskedValue)
+			ri.URL = shown.String()
+			ri.Query.Set(a.Name, maskedValue)
+			ri.hide(a.Value)
+		default:
+			return nil, fmt.Errorf("api_key auth: unknown location %s", a.In)
+		}
+	case "oauth2_client_credentials", "oauth2_password":
+		tok, err := oauth2Token(ctx, client, a)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", tok.tokenType+" "+tok.access)
+		ri.Headers.Set("Authorization", tok.tokenType+" "+maskedValue)
+		ri.hide(tok.access)
+	default:
+		return nil, fmt.Errorf("unknown auth type %s", a.Type)
+	}
+	return nil, nil
+}
+
+// hide records a credential value so displayed request text can mask it.
+func (ri *RequestInfo) hide(v string) {
+	if v != "" {
+		ri.secrets = append(ri.secrets, v)
+	}
+}
+
+// appendQuery adds a parameter without re-encoding (and reordering) the existing query.
+func appendQuery(raw, key, value string) string {
+	pair := url.QueryEscape(key) + "=" + url.QueryEscape(value)
+	if raw == "" {
+		return pair
+	}
+	return raw + "&" + pair
+}
+
+func resolveAuth(a config.Auth, vars map[string]string) (config.Auth, error) {
+	fields := []*string{&a.Username, &a.Password, &a.Token, &a.Name, &a.Value, &a.TokenURL, &a.ClientID, &a.ClientSecret, &a.Scope}
+	for _, f := range fields {
+		repl, err := templ.ApplyString(*f, vars)
+		if err != nil {
+			return a, err
+		}
+		*f = repl
+	}
+	return a, nil
+}
+
+// oauth2Token returns a cached token, refreshing or fetching a new one when needed.
+func oauth2Token(ctx context.Context, client *http.Client, a config.Auth) (*oauthToken, error) {
+	if a.TokenURL == "" {
+		return nil, fmt.Errorf("%s auth requires token_url", a.Type)
+	}
+	cache := clientTokens(client)
+	if cache == nil {
+		cache = NewTokenCache()
+	}
+	e := cache.entry(tokenKey(a))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cached := e.tok
+	if cached != nil && cached.valid() {
+		return cached, nil
+	}
+
+	var tok *oauthToken
+	var err error
+	if cached != nil && cached.refresh != "" {
+		tok, err = requestToken(ctx, client, a, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {cached.refresh},
+		})
+	}
+	if tok == nil || err != nil {
+		form := url.Values{}
+		if strings.ToLower(a.Type) == "oauth2_password" {
+			form.Set("grant_type", "password")
+			form.Set("username", a.Username)
+			form.Set("password", a.Password)
+		} else {
+			form.Set("grant_type", "client_credentials")
+		}
+		if a.Scope != "" {
+			form.Set("scope", a.Scope)
+		}
+		tok, err = requestToken(ctx, client, a, form)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tok.refresh == "" && cached != nil {
+		tok.refresh = cached.refresh
+	}
+	e.tok = tok
+	return tok, nil
+}
+
+func requestToken(ctx context.Context, client *http.Client, a config.Auth, form url.Values) (*oauthToken, error) {
+	basic := strings.ToLower(a.ClientAuth) != "body"
+	if !basic {
+		form.Set("client_id", a.ClientID)
+		if a.ClientSecret != "" {
+			form.Set("client_secret", a.ClientSecret)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basic && a.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(a.ClientID), url.QueryEscape(a.ClientSecret))
+	}
+	if _, err := throttle(ctx, client, req.URL.Hostname()); err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("token response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var payload struct {
+		AccessToken  string      `json:"access_token"`
+		TokenType    string      `json:"token_type"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("token response: %w", err)
+	}
+	if payload.AccessToken == "" {
+		return nil, errors.New("token response has no access_token")
+	}
+	tok := &oauthToken{access: payload.AccessToken, tokenType: "Bearer", refresh: payload.RefreshToken}
+	if payload.TokenType != "" && !strings.EqualFold(payload.TokenType, "bearer") {
+		tok.tokenType = payload.TokenType
+	}
+	if secs, err := payload.ExpiresIn.Float64(); err == nil && secs > 0 {
+		tok.expiry = now().Add(time.Duration(secs * float64(time.Second)))
+	}
+	return tok, nil
+}
+
+// retry answers the Digest challenge in resp by re-sending req with credentials.
+// Responses without a Digest challenge are returned unchanged. The returned
+// duration is the time spent waiting for the rate limiter before re-sending.
+func (d *digestAuth) retry(client *http.Client, req *http.Request, resp *http.Response, ri *RequestInfo) (*http.Response, time.Duration, error) {
+	var challenge map[string]string
+	for _, h := range resp.Header.Values("WWW-Authenticate") {
+		if len(h) > 7 && strings.EqualFold(h[:7], "digest ") {
+			challenge = parseAuthParams(h[7:])
+			break
+		}
+	}
+	if challenge == nil {
+		return resp, 0, nil
+	}
+	var body []byte
+	next := req.Clone(req.Context())
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return resp, 0, err
+		}
+		body, _ = io.ReadAll(rc)
+		rc.Close()
+		rc, _ = req.GetBody()
+		next.Body = rc
+	}
+	header, err := d.authorization(challenge, req.Method, req.URL.RequestURI(), body)
+	if err != nil {
+		return resp, 0, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	next.Header.Set("Authorization", header)
+	ri.Headers.Set("Authorization", "Digest "+maskedValue)
+	wait, err := throttle(next.Context(), client, next.URL.Hostname())
+	if err != nil {
+		return nil, wait, err
+	}
+	resp, err = client.Do(next)
+	return resp, wait, err
+}
+
+func (d *digestAuth) authorization(ch map[string]string, method, uri string, body []byte) (string, error) {
+	algorithm := ch["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+	h := func(s string) string {
+		hh := newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	qop := ""
+	for _, q := range strings.Split(ch["qop"], ",") {
+		q = strings.TrimSpace(q)
+		if q == "auth" || (q == "auth-int" && qop == "") {
+			qop = q
+		}
+	}
+	cnonceRaw := make([]byte, 8)
+	if _, err := rand.Read(cnonceRaw); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceRaw)
+	nc := "00000001"
+
+	ha1 := h(d.username + ":" + ch["realm"] + ":" + d.password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + ch["nonce"] + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	if qop == "auth-int" {
+		ha2 = h(method + ":" + uri + ":" + h(string(body)))
+	}
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + ch["nonce"] + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, ch["nonce"], nc, cnonce, qop, ha2}, ":"))
+	}
+
+	parts := []string{
+		fmt.Sprintf(`username="%s"`, d.username),
+		fmt.Sprintf(`realm="%s"`, ch["realm"]),
+		fmt.Sprintf(`nonce="%s"`, ch["nonce"]),
+		fmt.Sprintf(`uri="%s"`, uri),
+		"algorithm=" + algorithm,
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if qop != "" {
+		parts = append(parts, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if opaque, ok := ch["opaque"]; ok {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+	return "Digest " + strings.Join(parts, ", "), nil
+}
+
+// parseAuthParams splits `k=v, k="quoted, value"` challenge parameters.
+func parseAuthParams(s string) map[string]string {
+	out := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			var sb strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			val = sb.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		out[key] = val
+	}
+	return out
+}
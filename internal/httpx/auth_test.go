@@ -0,0 +1,180 @@
+package httpx
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"apitest/internal/config"
+)
+
+func TestBasicAndAPIKeyAuthMasked(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if r.URL.Query().Get("key") == "k-123" || (ok && user == "alice" && pass == "pw") {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	client := BuildClient(time.Second, false)
+
+	req := config.Request{URL: "/", Auth: &config.Auth{Type: "basic", Username: "alice", Password: "{{pw}}"}}
+	ri, resp, err := DoRequest(context.Background(), client, srv.URL, req, map[string]string{"pw": "pw"})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("basic auth failed: %v %d", err, resp.StatusCode)
+	}
+	if ri.Headers.Get("Authorization") != "Basic [masked]" {
+		t.Fatalf("basic credentials not masked: %q", ri.Headers.Get("Authorization"))
+	}
+
+	req = config.Request{URL: "/?a=1", Auth: &config.Auth{Type: "api_key", In: "query", Name: "key", Value: "k-123"}}
+	ri, resp, err = DoRequest(context.Background(), client, srv.URL, req, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("api key auth failed: %v %d", err, resp.StatusCode)
+	}
+	if strings.Contains(ri.URL, "k-123") || !strings.HasSuffix(ri.URL, "?a=1&key=%5Bmasked%5D") {
+		t.Fatalf("api key not masked in url: %s", ri.URL)
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	const realm, nonce = "test", "abc123"
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Digest ") {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", nonce="%s", qop="auth", opaque="xyz"`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := parseAuthParams(auth[7:])
+		ha1 := h("alice:" + realm + ":secret")
+		ha2 := h(r.Method + ":" + p["uri"])
+		expect := h(strings.Join([]string{ha1, nonce, p["nc"], p["cnonce"], p["qop"], ha2}, ":"))
+		if p["response"] != expect || p["opaque"] != "xyz" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	req := config.Request{Method: "POST", URL: "/digest?x=1", Body: &config.RequestBody{Raw: "payload"}, Auth: &config.Auth{Type: "digest", Username: "alice", Password: "secret"}}
+	ri, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Body != "ok" {
+		t.Fatalf("digest auth failed: %d %s", resp.StatusCode, resp.Body)
+	}
+	if ri.Headers.Get("Authorization") != "Digest [masked]" {
+		t.Fatalf("digest header not masked: %q", ri.Headers.Get("Authorization"))
+	}
+}
+
+func TestOAuth2ClientCredentialsCachesAndRefreshes(t *testing.T) {
+	var issued int32
+	var refreshed int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			_ = r.ParseForm()
+			id, secret, _ := r.BasicAuth()
+			if id != "cid" || secret != "csecret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var n int32
+			if r.PostForm.Get("grant_type") == "refresh_token" {
+				n = atomic.AddInt32(&refreshed, 1) + 100
+			} else {
+				n = atomic.AddInt32(&issued, 1)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token": "tok-%d", "token_type": "bearer", "expires_in": 3600, "refresh_token": "r"}`, n)
+		default:
+			_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+		}
+	}))
+	defer srv.Close()
+
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	client := BuildClient(time.Second, false)
+	req := config.Request{URL: "/api", Auth: &config.Auth{Type: "oauth2_client_credentials", TokenURL: srv.URL + "/token", ClientID: "cid", ClientSecret: "csecret"}}
+	for i := 0; i < 2; i++ {
+		ri, resp, err := DoRequest(context.Background(), client, srv.URL, req, nil)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		if resp.Body != "Bearer tok-1" || ri.Headers.Get("Authorization") != "Bearer [masked]" {
+			t.Fatalf("unexpected auth %q / %q", resp.Body, ri.Headers.Get("Authorization"))
+		}
+	}
+	if issued != 1 {
+		t.Fatalf("token should be cached, issued %d", issued)
+	}
+
+	current = current.Add(time.Hour - 10*time.Second)
+	_, resp, err := DoRequest(context.Background(), client, srv.URL, req, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.Body != "Bearer tok-101" || refreshed != 1 {
+		t.Fatalf("token should be refreshed before expiry, got %q", resp.Body)
+	}
+}
+
+func TestOAuth2CacheKeyedBySecretAndScopedToClient(t *testing.T) {
+	var issued int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" {
+			_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+			return
+		}
+		_ = r.ParseForm()
+		if r.PostForm.Get("username") != "alice" || r.PostForm.Get("password") != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token": "tok-%d", "expires_in": 3600}`, atomic.AddInt32(&issued, 1))
+	}))
+	defer srv.Close()
+
+	tokens := NewTokenCache()
+	client, _ := NewClient(time.Second, ClientOptions{Tokens: tokens})
+	auth := config.Auth{Type: "oauth2_password", TokenURL: srv.URL + "/token", ClientID: "cid", Username: "alice", Password: "good"}
+	req := config.Request{URL: "/api", Auth: &auth}
+	if _, resp, err := DoRequest(context.Background(), client, srv.URL, req, nil); err != nil || resp.Body != "Bearer tok-1" {
+		t.Fatalf("good credentials: %v %q", err, resp.Body)
+	}
+
+	bad := auth
+	bad.Password = "wrong"
+	if _, _, err := DoRequest(context.Background(), client, srv.URL, config.Request{URL: "/api", Auth: &bad}, nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("bad password must not reuse the cached token, got %v", err)
+	}
+
+	shared, _ := NewClient(time.Second, ClientOptions{Tokens: tokens})
+	if _, resp, err := DoRequest(context.Background(), shared, srv.URL, req, nil); err != nil || resp.Body != "Bearer tok-1" {
+		t.Fatalf("clients sharing a cache should reuse the token: %v %q", err, resp.Body)
+	}
+	other := BuildClient(time.Second, false)
+	if _, resp, err := DoRequest(context.Background(), other, srv.URL, req, nil); err != nil || resp.Body != "Bearer tok-2" {
+		t.Fatalf("separate runs should not share tokens: %v %q", err, resp.Body)
+	}
+}
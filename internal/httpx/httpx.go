@@ -1,75 +1,172 @@
 package httpx
 
 import (
+	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
-	"crypto/tls"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"apitest/internal/charset"
 	"apitest/internal/config"
 	"apitest/internal/templ"
 )
 
 const (
-	// MaxResponseBodySize defines the maximum bytes to read from response body.
+	// MaxResponseBodySize is the default number of body bytes kept in memory; steps
+	// and runs can override it with max_response_size.
 	MaxResponseBodySize = 2 * 1024 * 1024
 )
 
 // RequestInfo represents prepared request used in reporting.
 type RequestInfo struct {
-	Method  string
-	URL     string
-	Headers map[string]string
-	Query   map[string]string
+	Method string
+	URL    string
+	// Headers and Query keep the order they were set in, with repeated values
+	// listed separately.
+	Headers config.Params
+	Query   config.Params
 	Body    string
+	// CanonicalString is the text that was signed, kept for debugging signatures.
+	// Credential values in it are replaced with the mask shown in Headers and Query.
+	CanonicalString string
+
+	// secrets holds the raw credential values masked in Headers and Query.
+	secrets []string
 }
 
 // ResponseInfo represents HTTP response data used in assertions and reporting.
 type ResponseInfo struct {
-	StatusCode    int
-	Headers       http.Header
+	StatusCode int
+	Headers    http.Header
+	// Body is the response decoded to UTF-8; RawBody keeps the bytes as received.
 	Body          string
+	RawBody       []byte
+	Charset       string
 	BodyTruncated bool
-	Duration      time.Duration
+	// BodySize is the full body length even when Body is truncated or saved to disk.
+	BodySize   int64
+	BodySHA256 string
+	// ContentEncoding is the encoding announced by the server; EncodedSize is the
+	// body length on the wire before decompression.
+	ContentEncoding string
+	EncodedSize     int64
+	Decompressed    bool
+	// SavedTo is the file the body was streamed to, if any.
+	SavedTo  string
+	Duration time.Duration
+	Timing   Timing
+	// Waits lists the rate limit and Retry-After pauses taken before the
+	// final response; Duration covers only the last attempt.
+	Waits []Wait
 }
 
 // BuildClient returns http.Client configured with insecure flag and timeout.
 func BuildClient(timeout time.Duration, insecure bool) *http.Client {
-	transport := &http.Transport{}
-	if insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
-	}
-	return &http.Client{
-		Timeout:   timeout,
-		Transport: transport,
-	}
+	// without socket or resolve options NewClient cannot fail
+	client, _ := NewClient(timeout, ClientOptions{Insecure: insecure})
+	return client
 }
 
 // DoRequest builds and executes HTTP request based on config.
 func DoRequest(ctx context.Context, client *http.Client, baseURL string, req config.Request, vars map[string]string) (RequestInfo, ResponseInfo, error) {
+	ctx, waits := withWaitRecorder(ctx)
+	reqObj, auth, ri, err := prepareRequest(ctx, client, baseURL, req, vars)
+	if err != nil {
+		return ri, ResponseInfo{Waits: waits.list()}, err
+	}
+	resp, tt, duration, err := send(client, reqObj, auth, &ri, req.Retry)
+	if err != nil {
+		return ri, ResponseInfo{Duration: duration, Timing: tt.timing(time.Now()), Waits: waits.list()}, fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+	info, err := readResponse(resp, req, vars, duration, tt)
+	info.Waits = waits.list()
+	return ri, info, err
+}
+
+// readResponse reads and decodes the full body of resp.
+func readResponse(resp *http.Response, req config.Request, vars map[string]string, duration time.Duration, tt *timingTrace) (ResponseInfo, error) {
+	var err error
+	limit := int64(req.MaxResponseSize)
+	if limit <= 0 {
+		limit = MaxResponseBodySize
+	}
+	saveTo := ""
+	if req.SaveTo != "" {
+		saveTo, err = templ.ApplyString(req.SaveTo, vars)
+		if err != nil {
+			return ResponseInfo{Duration: duration, Timing: tt.timing(time.Now())}, fmt.Errorf("save_to template: %w", err)
+		}
+	}
+	contentEncoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	decodeAs := contentEncoding
+	if req.DisableDecompression {
+		decodeAs = ""
+	}
+	rb, err := readBody(resp.Body, decodeAs, limit, saveTo)
+	timing := tt.timing(time.Now())
+	if err != nil {
+		return ResponseInfo{StatusCode: resp.StatusCode, Headers: resp.Header.Clone(), Duration: duration, Timing: timing}, fmt.Errorf("read body: %w", err)
+	}
+	cs := req.ResponseCharset
+	if cs == "" {
+		cs = contentTypeCharset(resp.Header.Get("Content-Type"))
+	}
+	bodyStr, _ := charset.Decode(cs, rb.data)
+
+	return ResponseInfo{
+		StatusCode:      resp.StatusCode,
+		Headers:         resp.Header.Clone(),
+		Body:            bodyStr,
+		RawBody:         rb.data,
+		Charset:         charset.Normalize(cs),
+		BodyTruncated:   rb.truncated,
+		BodySize:        rb.size,
+		EncodedSize:     rb.encodedSize,
+		Decompressed:    rb.decompressed,
+		ContentEncoding: contentEncoding,
+		BodySHA256:      rb.sum,
+		SavedTo:         saveTo,
+		Duration:        duration,
+		Timing:          timing,
+	}, nil
+}
+
+// prepareRequest resolves templates, encodes the body and applies auth and
+// signing, returning a request ready to send.
+func prepareRequest(ctx context.Context, client *http.Client, baseURL string, req config.Request, vars map[string]string) (*http.Request, *attemptAuth, RequestInfo, error) {
 	method := req.Method
 	if method == "" {
 		method = http.MethodGet
 	}
 	ri := RequestInfo{
-		Method:  method,
-		URL:     req.URL,
-		Headers: map[string]string{},
-		Query:   map[string]string{},
+		Method: method,
+		URL:    req.URL,
+	}
+	if req.ResponseCharset != "" && !charset.Supported(req.ResponseCharset) {
+		return nil, nil, ri, fmt.Errorf("response_charset %q is not supported, use gbk, gb2312, gb18030, iso-8859-1, windows-1252 or utf-8", req.ResponseCharset)
 	}
 
 	// url
 	resolvedURL, err := templ.ApplyString(req.URL, vars)
 	if err != nil {
-		return ri, ResponseInfo{}, fmt.Errorf("url template: %w", err)
+		return nil, nil, ri, fmt.Errorf("url template: %w", err)
 	}
 	if baseURL != "" {
 		if strings.HasPrefix(resolvedURL, "http://") || strings.HasPrefix(resolvedURL, "https://") {
@@ -80,36 +177,42 @@ func DoRequest(ctx context.Context, client *http.Client, baseURL string, req con
 	}
 	ri.URL = resolvedURL
 	// query
-	query := url.Values{}
-	for k, v := range req.Query {
-		strVal := fmt.Sprint(v)
-		ri.Query[k] = strVal
-		repl, err := templ.ApplyString(strVal, vars)
+	var pairs []string
+	for _, param := range req.Query {
+		ri.Query.Set(param.Name, param.Values...)
+		values, err := applyValues(param.Values, vars)
+		if err != nil {
+			return nil, nil, ri, fmt.Errorf("query %s: %w", param.Name, err)
+		}
+		ri.Query.Set(param.Name, values...)
+		encoded, err := encodeQueryParam(param.Name, values, req.ArrayFormat)
 		if err != nil {
-			return ri, ResponseInfo{}, fmt.Errorf("query %s: %w", k, err)
+			return nil, nil, ri, err
 		}
-		query.Set(k, repl)
-		ri.Query[k] = repl
+		pairs = append(pairs, encoded...)
 	}
-	if len(query) > 0 {
+	if len(pairs) > 0 {
 		if strings.Contains(resolvedURL, "?") {
-			resolvedURL += "&" + query.Encode()
+			resolvedURL += "&" + strings.Join(pairs, "&")
 		} else {
-			resolvedURL += "?" + query.Encode()
+			resolvedURL += "?" + strings.Join(pairs, "&")
 		}
 	}
 	ri.URL = resolvedURL
 
 	// headers
 	hdr := http.Header{}
-	for k, v := range req.Headers {
-		ri.Headers[k] = v
-		repl, err := templ.ApplyString(v, vars)
+	for _, param := range req.Headers {
+		ri.Headers.Set(param.Name, param.Values...)
+		values, err := applyValues(param.Values, vars)
 		if err != nil {
-			return ri, ResponseInfo{}, fmt.Errorf("header %s: %w", k, err)
+			return nil, nil, ri, fmt.Errorf("header %s: %w", param.Name, err)
+		}
+		hdr.Del(param.Name)
+		for _, v := range values {
+			hdr.Add(param.Name, v)
 		}
-		hdr.Set(k, repl)
-		ri.Headers[k] = repl
+		ri.Headers.Set(param.Name, values...)
 	}
 
 	var body io.Reader
@@ -125,8 +228,14 @@ func DoRequest(ctx context.Context, client *http.Client, baseURL string, req con
 		if req.Body.Form != nil {
 			used++
 		}
+		if req.Body.XML != nil {
+			used++
+		}
+		if req.Body.SOAP != nil {
+			used++
+		}
 		if used > 1 {
-			return ri, ResponseInfo{}, errors.New("only one of raw/json/form is allowed in body")
+			return nil, nil, ri, errors.New("only one of raw/json/form/xml/soap is allowed in body")
 		}
 		switch {
 		case req.Body.Raw != "":
@@ -134,7 +243,7 @@ func DoRequest(ctx context.Context, client *http.Client, baseURL string, req con
 			bodyText, err = templ.ApplyString(req.Body.Raw, vars)
 			ri.Body = bodyText
 			if err != nil {
-				return ri, ResponseInfo{}, fmt.Errorf("body raw: %w", err)
+				return nil, nil, ri, fmt.Errorf("body raw: %w", err)
 			}
 			body = strings.NewReader(bodyText)
 		case req.Body.JSON != nil:
@@ -142,16 +251,17 @@ func DoRequest(ctx context.Context, client *http.Client, baseURL string, req con
 
 			replaced, err := templ.ApplyInterface(req.Body.JSON, vars)
 			if err != nil {
-				return ri, ResponseInfo{}, fmt.Errorf("body json: %w", err)
+				return nil, nil, ri, fmt.Errorf("body json: %w", err)
 			}
 			data, err := json.Marshal(replaced)
 			if err != nil {
-				return ri, ResponseInfo{}, fmt.Errorf("body json marshal: %w", err)
+				return nil, nil, ri, fmt.Errorf("body json marshal: %w", err)
 			}
 			bodyText = string(data)
 			body = bytes.NewReader(data)
 			if hdr.Get("Content-Type") == "" {
 				hdr.Set("Content-Type", "application/json")
+				ri.Headers.Set("Content-Type", "application/json")
 			}
 			ri.Body = bodyText
 		case req.Body.Form != nil:
@@ -164,7 +274,7 @@ func DoRequest(ctx context.Context, client *http.Client, baseURL string, req con
 				if err != nil {
 					vals.Set(k, repl)
 					ri.Body = vals.Encode()
-					return ri, ResponseInfo{}, fmt.Errorf("body form %s: %w", k, err)
+					return nil, nil, ri, fmt.Errorf("body form %s: %w", k, err)
 				}
 				vals.Set(k, repl)
 			}
@@ -173,59 +283,351 @@ func DoRequest(ctx context.Context, client *http.Client, baseURL string, req con
 			body = strings.NewReader(encoded)
 			if hdr.Get("Content-Type") == "" {
 				hdr.Set("Content-Type", "application/x-www-form-urlencoded")
+				ri.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			ri.Body = bodyText
+		case req.Body.XML != nil:
+			var err error
+			bodyText, err = renderXMLBody(*req.Body.XML, vars)
+			ri.Body = bodyText
+			if err != nil {
+				return nil, nil, ri, fmt.Errorf("body xml: %w", err)
 			}
+			body = strings.NewReader(bodyText)
+			if hdr.Get("Content-Type") == "" {
+				hdr.Set("Content-Type", "application/xml; charset=utf-8")
+				ri.Headers.Set("Content-Type", "application/xml; charset=utf-8")
+			}
+		case req.Body.SOAP != nil:
+			var err error
+			var contentType string
+			bodyText, contentType, err = renderSOAP(*req.Body.SOAP, vars)
 			ri.Body = bodyText
+			if err != nil {
+				return nil, nil, ri, fmt.Errorf("body soap: %w", err)
+			}
+			body = strings.NewReader(bodyText)
+			if hdr.Get("Content-Type") == "" {
+				hdr.Set("Content-Type", contentType)
+				ri.Headers.Set("Content-Type", contentType)
+			}
+			if v := req.Body.SOAP.Version.String(); (v == "" || v == "1.1") && hdr.Get("SOAPAction") == "" {
+				action, _ := templ.ApplyString(req.Body.SOAP.Action, vars)
+				hdr.Set("SOAPAction", `"`+action+`"`)
+				ri.Headers.Set("SOAPAction", `"`+action+`"`)
+			}
 		}
 	}
 
+	if req.Body != nil && req.Body.Compress != "" && body != nil {
+		encoding := strings.ToLower(req.Body.Compress)
+		compressed, err := compressBody(encoding, []byte(bodyText))
+		if err != nil {
+			return nil, nil, ri, fmt.Errorf("body compress: %w", err)
+		}
+		body = bytes.NewReader(compressed)
+		hdr.Set("Content-Encoding", encoding)
+		ri.Headers.Set("Content-Encoding", encoding)
+	}
+	// Negotiate compression ourselves so the server's Content-Encoding and the
+	// on-the-wire size stay visible to assertions.
+	if hdr.Get("Accept-Encoding") == "" {
+		hdr.Set("Accept-Encoding", "gzip, deflate")
+	}
+
 	reqObj, err := http.NewRequestWithContext(ctx, method, resolvedURL, body)
 	if err != nil {
-		return ri, ResponseInfo{}, fmt.Errorf("build request: %w", err)
+		return nil, nil, ri, fmt.Errorf("build request: %w", err)
 	}
 	reqObj.Header = hdr
 	ri.Method = method
 	ri.URL = reqObj.URL.String()
 	ri.Body = bodyText
 
-	start := time.Now()
-	resp, err := client.Do(reqObj)
-	duration := time.Since(start)
+	digest, err := applyAuth(ctx, client, req.Auth, reqObj, vars, &ri)
 	if err != nil {
-		return ri, ResponseInfo{Duration: duration}, fmt.Errorf("request: %w", err)
+		return nil, nil, ri, fmt.Errorf("auth: %w", err)
 	}
-	defer resp.Body.Close()
+	auth := &attemptAuth{digest: digest}
+	if req.Sign != nil {
+		auth.unsigned, auth.shownURL, auth.sign, auth.vars = reqObj.Clone(ctx), ri.URL, req.Sign, vars
+	}
+	canonical, err := signRequest(reqObj, req.Sign, vars, &ri)
+	if err != nil {
+		return nil, nil, ri, fmt.Errorf("sign: %w", err)
+	}
+	ri.CanonicalString = maskSecrets(canonical, ri.secrets)
+
+	return reqObj, auth, ri, nil
+}
+
+// attemptAuth holds what send needs to authenticate attempts after the first:
+// the digest handler that answers a challenge and, for signed requests, the
+// request as it was before signing so each retry is signed with a fresh
+// timestamp and nonce.
+type attemptAuth struct {
+	digest   *digestAuth
+	unsigned *http.Request
+	shownURL string
+	sign     *config.Sign
+	vars     map[string]string
+}
 
-	bodyLimit := MaxResponseBodySize + 1
-	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(bodyLimit)))
+// resign signs a fresh copy of the unsigned request for the next attempt and
+// records the new signature in ri.
+func (a *attemptAuth) resign(ri *RequestInfo) (*http.Request, error) {
+	next := a.unsigned.Clone(a.unsigned.Context())
+	if a.unsigned.GetBody != nil {
+		body, err := a.unsigned.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	ri.URL = a.shownURL
+	canonical, err := signRequest(next, a.sign, a.vars, ri)
 	if err != nil {
-		return ri, ResponseInfo{Duration: duration}, fmt.Errorf("read body: %w", err)
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	ri.CanonicalString = maskSecrets(canonical, ri.secrets)
+	return next, nil
+}
+
+// send executes the prepared request with the timing trace attached, retrying
+// once with digest credentials when challenged. Signed requests are signed
+// again before every retry. Rate limit waits happen before the clock starts,
+// so the returned duration is network time only.
+func send(client *http.Client, reqObj *http.Request, auth *attemptAuth, ri *RequestInfo, policy *config.Retry) (*http.Response, *timingTrace, time.Duration, error) {
+	if policy != nil && policy.Disabled {
+		policy = nil
+	}
+	for attempt := 1; ; attempt++ {
+		tt := newTimingTrace()
+		traced := reqObj.WithContext(httptrace.WithClientTrace(reqObj.Context(), tt.clientTrace()))
+		if _, err := throttle(reqObj.Context(), client, reqObj.URL.Hostname()); err != nil {
+			tt.start = time.Now()
+			return nil, tt, 0, err
+		}
+
+		start := time.Now()
+		tt.start = start
+		var paused time.Duration
+		resp, err := client.Do(traced)
+		if err == nil && auth.digest != nil && resp.StatusCode == http.StatusUnauthorized {
+			resp, paused, err = auth.digest.retry(client, traced, resp, ri)
+		}
+		duration := time.Since(start) - paused
+		if err != nil {
+			return resp, tt, duration, err
+		}
+		delay, ok := retryDelay(policy, resp, attempt)
+		if !ok || (reqObj.Body != nil && reqObj.Body != http.NoBody && reqObj.GetBody == nil) {
+			return resp, tt, duration, nil
+		}
+		next := reqObj.Clone(reqObj.Context())
+		if reqObj.GetBody != nil {
+			body, err := reqObj.GetBody()
+			if err != nil {
+				return resp, tt, duration, nil
+			}
+			next.Body = body
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		recordWait(reqObj.Context(), Wait{Reason: "retry", Duration: delay, Status: resp.StatusCode, Attempt: attempt, Host: reqObj.URL.Hostname()})
+		if err := sleepContext(reqObj.Context(), delay); err != nil {
+			return nil, tt, duration, err
+		}
+		if auth.unsigned != nil {
+			if next, err = auth.resign(ri); err != nil {
+				return nil, tt, duration, err
+			}
+		}
+		reqObj = next
+	}
+}
+
+// contentTypeCharset returns the charset parameter of a Content-Type header, if any.
+func contentTypeCharset(ct string) string {
+	if ct == "" {
+		return ""
 	}
-	truncated := len(data) > MaxResponseBodySize
-	if truncated {
-		data = data[:MaxResponseBodySize]
+	_, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ""
 	}
-	bodyStr := string(data)
+	return params["charset"]
+}
 
-	// For gzip or content enc specify? net/http handles decoding automatically unless disabled.
-	// Ensure charset decoding? keep raw bytes to string.
+type bodyRead struct {
+	data         []byte
+	truncated    bool
+	size         int64
+	encodedSize  int64
+	decompressed bool
+	sum          string
+}
+
+// readBody consumes the response body, decompressing it when encoding is gzip or
+// deflate. Up to limit bytes are kept in memory and the rest is still read, so
+// size and hash always describe the full body. With saveTo set the body is
+// streamed into that file and nothing is buffered.
+func readBody(r io.Reader, encoding string, limit int64, saveTo string) (bodyRead, error) {
+	var out bodyRead
+	wire := &countingReader{r: r}
+	src, decoded, err := decompressReader(encoding, wire)
+	if err != nil {
+		return out, fmt.Errorf("decompress %s: %w", encoding, err)
+	}
+	out.decompressed = decoded
+	hasher := sha256.New()
+	counter := &countingWriter{}
+	sink := io.MultiWriter(hasher, counter)
 
-	// Try to honor charset in Content-Type (basic)
-	if ct := resp.Header.Get("Content-Type"); ct != "" {
-		if mediatype, params, err := mime.ParseMediaType(ct); err == nil {
-			_ = mediatype
-			if cs := strings.ToLower(params["charset"]); cs == "utf-8" || cs == "" {
-				// nothing
+	if saveTo != "" {
+		if dir := filepath.Dir(saveTo); dir != "" && dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return out, err
+			}
+		}
+		f, err := os.Create(saveTo)
+		if err != nil {
+			return out, err
+		}
+		_, err = io.Copy(io.MultiWriter(f, sink), src)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return out, err
+		}
+	} else {
+		data, err := io.ReadAll(io.LimitReader(io.TeeReader(src, sink), limit+1))
+		if err != nil {
+			return out, err
+		}
+		if int64(len(data)) > limit {
+			out.truncated = true
+			data = data[:limit]
+			if _, err := io.Copy(sink, src); err != nil {
+				return out, err
 			}
 		}
+		out.data = data
 	}
+	out.size = counter.n
+	out.encodedSize = wire.n
+	out.sum = hex.EncodeToString(hasher.Sum(nil))
+	return out, nil
+}
 
-	return ri, ResponseInfo{
-		StatusCode:    resp.StatusCode,
-		Headers:       resp.Header.Clone(),
-		Body:          bodyStr,
-		BodyTruncated: truncated,
-		Duration:      duration,
-	}, nil
+// decompressReader wraps r for gzip or deflate bodies; decoded reports whether
+// it did so. Empty bodies (HEAD, 204) are passed through untouched.
+func decompressReader(encoding string, r io.Reader) (out io.Reader, decoded bool, err error) {
+	if encoding != "gzip" && encoding != "x-gzip" && encoding != "deflate" {
+		return r, false, nil
+	}
+	br := bufio.NewReader(r)
+	head, err := br.Peek(2)
+	if len(head) == 0 && err == io.EOF {
+		return br, false, nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	switch {
+	case encoding != "deflate":
+		out, err = gzip.NewReader(br)
+	case len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0:
+		// "deflate" should be zlib-wrapped, but some servers send a raw stream.
+		out, err = zlib.NewReader(br)
+	default:
+		out = flate.NewReader(br)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// compressBody encodes a request payload for the given Content-Encoding.
+func compressBody(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported compression %s", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct{ n int64 }
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+func applyValues(values []string, vars map[string]string) ([]string, error) {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		repl, err := templ.ApplyString(v, vars)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, repl)
+	}
+	return out, nil
+}
+
+// encodeQueryParam renders one parameter as encoded key=value pairs, in value
+// order, using the requested array format for multiple values.
+func encodeQueryParam(name string, values []string, format string) ([]string, error) {
+	key := url.QueryEscape(name)
+	if len(values) == 0 {
+		return []string{key + "="}, nil
+	}
+	switch strings.ToLower(format) {
+	case "", "repeat":
+	case "comma":
+		escaped := make([]string, len(values))
+		for i, v := range values {
+			escaped[i] = url.QueryEscape(v)
+		}
+		return []string{key + "=" + strings.Join(escaped, ",")}, nil
+	case "brackets":
+		if len(values) > 1 {
+			key = url.QueryEscape(name + "[]")
+		}
+	default:
+		return nil, fmt.Errorf("unknown array_format %s", format)
+	}
+	pairs := make([]string, 0, len(values))
+	for _, v := range values {
+		pairs = append(pairs, key+"="+url.QueryEscape(v))
+	}
+	return pairs, nil
 }
 
 // marshalPreview renders a JSON body with best-effort template substitution
@@ -1,7 +1,17 @@
 package httpx
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -13,8 +23,8 @@ func TestDoRequestRecordsInfoOnTemplateError(t *testing.T) {
 	req := config.Request{
 		Method: "POST",
 		URL:    "/api/login",
-		Headers: map[string]string{
-			"Content-Type": "application/json",
+		Headers: config.Params{
+			{Name: "Content-Type", Values: []string{"application/json"}},
 		},
 		Body: &config.RequestBody{
 			JSON: map[string]interface{}{
@@ -47,7 +57,233 @@ func TestDoRequestRecordsInfoOnTemplateError(t *testing.T) {
 	if !strings.Contains(ri.Body, "alice") || !strings.Contains(ri.Body, "123") {
 		t.Fatalf("body should include applied values, got: %s", ri.Body)
 	}
-	if ri.Headers["Content-Type"] == "" {
+	if ri.Headers.Get("Content-Type") == "" {
 		t.Fatalf("headers not recorded")
 	}
 }
+
+func TestDoRequestRecordsTiming(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	_, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, config.Request{URL: "/"}, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	tm := resp.Timing
+	if tm.Connect <= 0 {
+		t.Fatalf("connect not traced: %+v", tm)
+	}
+	if tm.TTFB < 5*time.Millisecond || tm.Wait < 5*time.Millisecond {
+		t.Fatalf("ttfb/wait should include server delay: %+v", tm)
+	}
+	if tm.Total < tm.TTFB {
+		t.Fatalf("total %s shorter than ttfb %s", tm.Total, tm.TTFB)
+	}
+	if len(tm.Phases) == 0 || tm.Phases[0].Name != "connect" {
+		t.Fatalf("unexpected phases %+v", tm.Phases)
+	}
+}
+
+func TestDoRequestBodyLimitAndSaveTo(t *testing.T) {
+	payload := strings.Repeat("a,b,c\n", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+	sum := sha256.Sum256([]byte(payload))
+	wantSum := hex.EncodeToString(sum[:])
+
+	_, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, config.Request{URL: "/", MaxResponseSize: 100}, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if !resp.BodyTruncated || len(resp.Body) != 100 {
+		t.Fatalf("expected body truncated to 100 bytes, got %d (truncated=%v)", len(resp.Body), resp.BodyTruncated)
+	}
+	if resp.BodySize != int64(len(payload)) || resp.BodySHA256 != wantSum {
+		t.Fatalf("size/hash should cover the full body: %d %s", resp.BodySize, resp.BodySHA256)
+	}
+
+	out := filepath.Join(t.TempDir(), "out", "{{name}}.csv")
+	_, resp, err = DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, config.Request{URL: "/", SaveTo: out}, map[string]string{"name": "export"})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.Body != "" || resp.BodySHA256 != wantSum {
+		t.Fatalf("saved body should not be buffered: %q %s", resp.Body, resp.BodySHA256)
+	}
+	data, err := os.ReadFile(resp.SavedTo)
+	if err != nil || string(data) != payload {
+		t.Fatalf("saved file mismatch (%s): %v", resp.SavedTo, err)
+	}
+}
+
+func TestDoRequestDecodesCharset(t *testing.T) {
+	gbk := []byte("{\"name\": \"\xd6\xd0\xce\xc4\"}")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/wrong" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/json; charset=GBK")
+		}
+		_, _ = w.Write(gbk)
+	}))
+	defer srv.Close()
+
+	_, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, config.Request{URL: "/"}, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.Body != `{"name": "中文"}` || resp.Charset != "gbk" {
+		t.Fatalf("body not decoded: %q (%s)", resp.Body, resp.Charset)
+	}
+	if string(resp.RawBody) != string(gbk) {
+		t.Fatalf("raw bytes not kept")
+	}
+
+	_, resp, err = DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, config.Request{URL: "/wrong", ResponseCharset: "gb18030"}, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.Body != `{"name": "中文"}` {
+		t.Fatalf("override not applied: %q", resp.Body)
+	}
+
+	_, _, err = DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, config.Request{URL: "/wrong", ResponseCharset: "gbk2"}, nil)
+	if err == nil || !strings.Contains(err.Error(), `response_charset "gbk2" is not supported`) {
+		t.Fatalf("expected unsupported override to fail, got %v", err)
+	}
+}
+
+func TestDoRequestCompression(t *testing.T) {
+	list := strings.Repeat(`{"id": 1, "name": "item"},`, 200)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reqBody, _ := io.ReadAll(zr)
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write([]byte(`{"echo": ` + string(reqBody) + `, "rows": [` + list + `{}]}`))
+		_ = zw.Close()
+	}))
+	defer srv.Close()
+
+	req := config.Request{Method: "POST", URL: "/", Body: &config.RequestBody{JSON: map[string]interface{}{"q": "x"}, Compress: "gzip"}}
+	ri, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || ri.Body != `{"q":"x"}` {
+		t.Fatalf("compressed request rejected: %d %s", resp.StatusCode, ri.Body)
+	}
+	if resp.ContentEncoding != "gzip" || !resp.Decompressed || !strings.HasPrefix(resp.Body, `{"echo": {"q":"x"}`) {
+		t.Fatalf("response not decompressed: %s %q", resp.ContentEncoding, resp.Body[:20])
+	}
+	if resp.EncodedSize <= 0 || resp.EncodedSize >= resp.BodySize {
+		t.Fatalf("unexpected sizes encoded=%d decoded=%d", resp.EncodedSize, resp.BodySize)
+	}
+
+	req.DisableDecompression = true
+	_, resp, err = DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.Decompressed || !strings.HasPrefix(resp.Body, "\x1f\x8b") || resp.BodySize != resp.EncodedSize {
+		t.Fatalf("raw encoded body expected, got %q", resp.Body[:4])
+	}
+}
+
+func TestDoRequestMultiValuedParams(t *testing.T) {
+	var gotQuery string
+	var gotHeaders []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		gotHeaders = r.Header.Values("X-Tag")
+	}))
+	defer srv.Close()
+
+	var req config.Request
+	plan := []byte(`{"url": "/list?page=1", "query": {"z": "last?", "ids": [1, 2, "{{id}}"], "a": 10000000000},
+		"headers": {"X-Tag": ["a", "b"]}}`)
+	if err := json.Unmarshal(plan, &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	cases := map[string]string{
+		"":         "page=1&z=last%3F&ids=1&ids=2&ids=3&a=10000000000",
+		"comma":    "page=1&z=last%3F&ids=1,2,3&a=10000000000",
+		"brackets": "page=1&z=last%3F&ids%5B%5D=1&ids%5B%5D=2&ids%5B%5D=3&a=10000000000",
+	}
+	for format, expect := range cases {
+		req.ArrayFormat = format
+		ri, _, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, map[string]string{"id": "3"})
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		if gotQuery != expect {
+			t.Fatalf("%s: query %q, expect %q", format, gotQuery, expect)
+		}
+		if fmt.Sprint(ri.Query) != "[{z [last?]} {ids [1 2 3]} {a [10000000000]}]" || fmt.Sprint(ri.Headers) != "[{X-Tag [a b]}]" {
+			t.Fatalf("request info values %v / %v", ri.Query, ri.Headers)
+		}
+		if len(gotHeaders) != 2 || gotHeaders[1] != "b" {
+			t.Fatalf("repeated header not sent: %v", gotHeaders)
+		}
+	}
+}
+
+func TestDoRequestXMLAndSOAPBodies(t *testing.T) {
+	var gotBody, gotType, gotAction string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		gotBody, gotType, gotAction = string(data), r.Header.Get("Content-Type"), r.Header.Get("SOAPAction")
+	}))
+	defer srv.Close()
+
+	var req config.Request
+	plan := []byte(`{"method": "POST", "body": {"xml": {"order": {"@id": "{{id}}", "item": ["a&b", "c"], "note": {"@lang": "en", "#text": "hi"}}}}}`)
+	if err := json.Unmarshal(plan, &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	vars := map[string]string{"id": "7"}
+	if _, _, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, vars); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	expect := xmlDeclaration + `<order id="7"><item>a&amp;b</item><item>c</item><note lang="en">hi</note></order>`
+	if gotBody != expect || !strings.HasPrefix(gotType, "application/xml") {
+		t.Fatalf("xml body %q (%s)", gotBody, gotType)
+	}
+
+	plan = []byte(`{"method": "POST", "body": {"soap": {"action": "urn:GetOrder", "body": {"m:GetOrder": {"@xmlns:m": "urn:shop", "m:Id": "{{id}}"}}}}}`)
+	req = config.Request{}
+	if err := json.Unmarshal(plan, &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if _, _, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, vars); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if !strings.Contains(gotBody, `<soap:Envelope xmlns:soap="`+soap11NS+`"><soap:Body><m:GetOrder xmlns:m="urn:shop"><m:Id>7</m:Id></m:GetOrder></soap:Body></soap:Envelope>`) {
+		t.Fatalf("soap envelope %q", gotBody)
+	}
+	if gotType != "text/xml; charset=utf-8" || gotAction != `"urn:GetOrder"` {
+		t.Fatalf("soap 1.1 headers %q / %q", gotType, gotAction)
+	}
+
+	req.Body.SOAP.Version = "1.2"
+	if _, _, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, vars); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if gotType != `application/soap+xml; charset=utf-8; action="urn:GetOrder"` || gotAction != "" || !strings.Contains(gotBody, soap12NS) {
+		t.Fatalf("soap 1.2 headers %q / %q", gotType, gotAction)
+	}
+}
@@ -0,0 +1,365 @@
+package httpx
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"apitest/internal/config"
+	"apitest/internal/templ"
+)
+
+// signRequest signs the fully resolved request and injects the result into its
+// headers or query. It returns the canonical string that was signed.
+func signRequest(req *http.Request, sign *config.Sign, vars map[string]string, ri *RequestInfo) (string, error) {
+	if sign == nil {
+		return "", nil
+	}
+	s, err := resolveSign(*sign, vars)
+	if err != nil {
+		return "", err
+	}
+	var body []byte
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		body, err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch strings.ToLower(s.Scheme) {
+	case "", "none":
+		return "", nil
+	case "sigv4":
+		return signSigV4(req, s, body, ri)
+	case "hmac", "custom":
+	default:
+		return "", fmt.Errorf("unknown sign scheme %s", s.Scheme)
+	}
+
+	signVars := map[string]string{
+		"method":      req.Method,
+		"path":        req.URL.EscapedPath(),
+		"query":       canonicalQuery(req.URL.Query()),
+		"timestamp":   signTimestamp(s.TimestampFormat),
+		"nonce":       newNonce(),
+		"key_id":      s.KeyID,
+		"body":        string(body),
+		"body_sha256": hashHex(sha256.New, body),
+		"body_md5":    hashHex(md5.New, body),
+	}
+	ctx := templ.MergeContexts(vars, signVars)
+
+	var canonical string
+	if strings.ToLower(s.Scheme) == "custom" {
+		if s.StringToSign == "" {
+			return "", fmt.Errorf("custom sign scheme requires string_to_sign")
+		}
+		canonical, err = templ.ApplyString(s.StringToSign, ctx)
+		if err != nil {
+			return "", fmt.Errorf("string_to_sign: %w", err)
+		}
+	} else {
+		components := s.Components
+		if len(components) == 0 {
+			components = []string{"method", "path", "query", "timestamp", "nonce", "body_sha256"}
+		}
+		sep := "\n"
+		if s.Separator != nil {
+			sep = *s.Separator
+		}
+		parts := make([]string, 0, len(components))
+		for _, c := range components {
+			if name, ok := cutPrefixFold(c, "header:"); ok {
+				parts = append(parts, req.Header.Get(name))
+				continue
+			}
+			v, ok := signVars[strings.ToLower(c)]
+			if !ok {
+				return "", fmt.Errorf("unknown sign component %s", c)
+			}
+			parts = append(parts, v)
+		}
+		canonical = strings.Join(parts, sep)
+	}
+
+	ri.hide(s.Secret)
+	signature, err := computeSignature(s.Algorithm, s.Encoding, s.Secret, canonical)
+	if err != nil {
+		return "", err
+	}
+	ctx["signature"] = signature
+
+	headers, query := s.Headers, s.Query
+	if len(headers) == 0 && len(query) == 0 {
+		headers = map[string]string{
+			"X-Timestamp": "{{timestamp}}",
+			"X-Nonce":     "{{nonce}}",
+			"X-Signature": "{{signature}}",
+		}
+		if s.KeyID != "" {
+			headers["X-Key-Id"] = "{{key_id}}"
+		}
+	}
+	for _, k := range sortedKeys(headers) {
+		repl, err := templ.ApplyString(headers[k], ctx)
+		if err != nil {
+			return canonical, fmt.Errorf("sign header %s: %w", k, err)
+		}
+		req.Header.Set(k, repl)
+		ri.Headers.Set(k, repl)
+	}
+	for _, k := range sortedKeys(query) {
+		repl, err := templ.ApplyString(query[k], ctx)
+		if err != nil {
+			return canonical, fmt.Errorf("sign query %s: %w", k, err)
+		}
+		req.URL.RawQuery = appendQuery(req.URL.RawQuery, k, repl)
+		ri.URL = appendURLQuery(ri.URL, k, repl)
+		ri.Query.Set(k, repl)
+	}
+	return canonical, nil
+}
+
+func resolveSign(s config.Sign, vars map[string]string) (config.Sign, error) {
+	fields := []*string{&s.KeyID, &s.Secret, &s.Region, &s.Service, &s.SessionToken}
+	for _, f := range fields {
+		repl, err := templ.ApplyString(*f, vars)
+		if err != nil {
+			return s, err
+		}
+		*f = repl
+	}
+	return s, nil
+}
+
+// computeSignature hashes or HMACs the canonical string and encodes the digest.
+func computeSignature(algorithm, encoding, secret, canonical string) (string, error) {
+	alg := strings.ToLower(algorithm)
+	if alg == "" {
+		alg = "hmac-sha256"
+	}
+	useHMAC := strings.HasPrefix(alg, "hmac-")
+	var newHash func() hash.Hash
+	switch strings.TrimPrefix(alg, "hmac-") {
+	case "sha256":
+		newHash = sha256.New
+	case "sha1":
+		newHash = sha1.New
+	case "sha512":
+		newHash = sha512.New
+	case "md5":
+		newHash = md5.New
+	default:
+		return "", fmt.Errorf("unknown sign algorithm %s", algorithm)
+	}
+	var sum []byte
+	if useHMAC {
+		mac := hmac.New(newHash, []byte(secret))
+		mac.Write([]byte(canonical))
+		sum = mac.Sum(nil)
+	} else {
+		h := newHash()
+		h.Write([]byte(canonical))
+		sum = h.Sum(nil)
+	}
+	switch strings.ToLower(encoding) {
+	case "", "hex":
+		return hex.EncodeToString(sum), nil
+	case "hex_upper":
+		return strings.ToUpper(hex.EncodeToString(sum)), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(sum), nil
+	default:
+		return "", fmt.Errorf("unknown sign encoding %s", encoding)
+	}
+}
+
+// signSigV4 implements AWS Signature Version 4 with the Authorization header.
+func signSigV4(req *http.Request, s config.Sign, body []byte, ri *RequestInfo) (string, error) {
+	if s.KeyID == "" || s.Secret == "" || s.Region == "" || s.Service == "" {
+		return "", fmt.Errorf("sigv4 requires key_id, secret, region and service")
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	payloadHash := hashHex(sha256.New, body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	ri.Headers.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		ri.Headers.Set("X-Amz-Security-Token", maskedValue)
+		ri.hide(s.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		ri.Headers.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signed := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" {
+			trimmed := make([]string, len(v))
+			for i := range v {
+				trimmed[i] = strings.Join(strings.Fields(v[i]), " ")
+			}
+			signed[lk] = strings.Join(trimmed, ",")
+		}
+	}
+	names := sortedKeys(signed)
+	var canonHeaders strings.Builder
+	for _, n := range names {
+		canonHeaders.WriteString(n + ":" + signed[n] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if s.Service != "s3" {
+		segments := strings.Split(path, "/")
+		for i, seg := range segments {
+			segments[i] = awsEscape(seg)
+		}
+		path = strings.Join(segments, "/")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{day, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex(sha256.New, []byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + s.Secret)
+	for _, part := range []string{day, s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	auth := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.KeyID, scope, signedHeaders, signature)
+	req.Header.Set("Authorization", auth)
+	ri.Headers.Set("Authorization", auth)
+	return canonicalRequest + "\n\n" + stringToSign, nil
+}
+
+// canonicalQuery sorts parameters by key and value and percent-encodes them per RFC 3986.
+func canonicalQuery(q url.Values) string {
+	pairs := make([]string, 0, len(q))
+	for _, k := range sortedKeys(q) {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// maskSecrets replaces every credential value, raw or percent-encoded, with the
+// mask so a signed string can be shown without leaking what it covers.
+func maskSecrets(s string, secrets []string) string {
+	sorted := append([]string(nil), secrets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, v := range sorted {
+		for _, form := range []string{v, awsEscape(v), url.QueryEscape(v)} {
+			s = strings.ReplaceAll(s, form, maskedValue)
+		}
+	}
+	return s
+}
+
+// awsEscape percent-encodes everything except RFC 3986 unreserved characters.
+func awsEscape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
+
+func signTimestamp(format string) string {
+	t := now()
+	switch strings.ToLower(format) {
+	case "", "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unix_ms":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case "rfc3339":
+		return t.UTC().Format("2006-01-02T15:04:05Z07:00")
+	default:
+		return t.Format(format)
+	}
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashHex(newHash func() hash.Hash, data []byte) string {
+	h := newHash()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func appendURLQuery(u, key, value string) string {
+	pair := url.QueryEscape(key) + "=" + url.QueryEscape(value)
+	if strings.Contains(u, "?") {
+		return u + "&" + pair
+	}
+	return u + "?" + pair
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return "", false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
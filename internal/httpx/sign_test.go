@@ -0,0 +1,150 @@
+package httpx
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"apitest/internal/config"
+)
+
+func TestSigV4MatchesReferenceVector(t *testing.T) {
+	// "get-vanilla" from the AWS Signature Version 4 test suite.
+	fixed := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	ri := RequestInfo{}
+	sign := &config.Sign{Scheme: "sigv4", KeyID: "AKIDEXAMPLE", Secret: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1", Service: "service"}
+	canonical, err := signRequest(req, sign, nil, &ri)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	expect := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expect {
+		t.Fatalf("unexpected authorization\n got: %s\nwant: %s\ncanonical:\n%s", got, expect, canonical)
+	}
+}
+
+func TestHMACSignature(t *testing.T) {
+	const secret = "app-secret"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, r.ContentLength)
+		_, _ = r.Body.Read(body)
+		bodyHash := sha256.Sum256(body)
+		canonical := strings.Join([]string{r.Method, r.URL.Path, "a=1&b=2", r.Header.Get("X-Ca-Timestamp"), r.Header.Get("X-Ca-Nonce"), hex.EncodeToString(bodyHash[:])}, "\n")
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(canonical))
+		if r.Header.Get("X-Ca-Signature") != hex.EncodeToString(mac.Sum(nil)) || r.Header.Get("X-Ca-Key") != "app-1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req := config.Request{
+		Method: "POST",
+		URL:    "/open/api?b=2&a=1",
+		Body:   &config.RequestBody{JSON: map[string]interface{}{"id": "{{id}}"}},
+		Sign: &config.Sign{
+			Scheme: "hmac",
+			KeyID:  "app-1",
+			Secret: "{{secret}}",
+			Headers: map[string]string{
+				"X-Ca-Key":       "{{key_id}}",
+				"X-Ca-Timestamp": "{{timestamp}}",
+				"X-Ca-Nonce":     "{{nonce}}",
+				"X-Ca-Signature": "{{signature}}",
+			},
+		},
+	}
+	ri, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, map[string]string{"id": "7", "secret": secret})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("signature rejected; canonical string:\n%s", ri.CanonicalString)
+	}
+	if !strings.HasPrefix(ri.CanonicalString, "POST\n/open/api\na=1&b=2\n") {
+		t.Fatalf("unexpected canonical string %q", ri.CanonicalString)
+	}
+}
+
+func TestCustomSignatureInQuery(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/api?x=1", nil)
+	ri := RequestInfo{URL: req.URL.String()}
+	sign := &config.Sign{
+		Scheme:       "custom",
+		Secret:       "s",
+		Algorithm:    "md5",
+		Encoding:     "hex_upper",
+		StringToSign: "{{secret_prefix}}{{query}}",
+		Query:        map[string]string{"sign": "{{signature}}"},
+	}
+	canonical, err := signRequest(req, sign, map[string]string{"secret_prefix": "s"}, &ri)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if canonical != "sx=1" {
+		t.Fatalf("unexpected canonical %q", canonical)
+	}
+	if req.URL.Query().Get("sign") != "43C38CD221196212D2210857955778A9" {
+		t.Fatalf("signature not injected: %s", req.URL.RawQuery)
+	}
+	if !strings.Contains(ri.URL, "&sign=") {
+		t.Fatalf("signature missing from request info url %s", ri.URL)
+	}
+}
+
+func TestRetrySignsEachAttempt(t *testing.T) {
+	const secret = "app-secret"
+	var nonces []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(r.Method + "\n" + r.URL.Path + "\n" + q.Get("nonce")))
+		if len(q["sig"]) != 1 || q.Get("sig") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		nonces = append(nonces, q.Get("nonce"))
+		if len(nonces) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req := config.Request{
+		Method: "POST",
+		URL:    "/orders",
+		Body:   &config.RequestBody{Raw: "payload"},
+		Retry:  &config.Retry{},
+		Sign: &config.Sign{
+			Scheme:     "hmac",
+			Secret:     secret,
+			Components: []string{"method", "path", "nonce"},
+			Query:      map[string]string{"nonce": "{{nonce}}", "sig": "{{signature}}"},
+		},
+	}
+	ri, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(nonces) != 2 || nonces[0] == nonces[1] {
+		t.Fatalf("status %d, nonces %v", resp.StatusCode, nonces)
+	}
+	if !strings.HasSuffix(ri.CanonicalString, nonces[1]) || strings.Count(ri.URL, "sig=") != 1 {
+		t.Fatalf("request info not updated: %q %s", ri.CanonicalString, ri.URL)
+	}
+}
@@ -0,0 +1,233 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"apitest/internal/config"
+)
+
+// SSEEvent is one dispatched server-sent event.
+type SSEEvent struct {
+	Event string
+	ID    string
+	Data  string
+	Retry int
+	// At is the time since the request was sent.
+	At time.Duration
+}
+
+// SSEStream is the outcome of reading an event stream.
+type SSEStream struct {
+	Events []SSEEvent
+	// Stop is why collection ended: max_events, until, timeout or eof.
+	Stop string
+}
+
+// SSE stop reasons reported in the collected document.
+const (
+	SSEStopMaxEvents = "max_events"
+	SSEStopUntil     = "until"
+	SSEStopTimeout   = "timeout"
+	SSEStopEOF       = "eof"
+)
+
+// SSEOptions bounds how long a stream is read.
+type SSEOptions struct {
+	MaxEvents int
+	// Window is how long events are collected; reaching it is not an error.
+	Window time.Duration
+	// Until receives each event as a JSON document (see EventDocument) and
+	// stops collection when it returns true.
+	Until func(doc string) bool
+}
+
+// DoSSE sends req and collects server-sent events instead of reading the body
+// to completion. The response Body is a JSON document
+// {"events": [...], "count": n, "stop": reason} for assertions and extraction;
+// RawBody keeps the stream as received, up to the body limit.
+func DoSSE(ctx context.Context, client *http.Client, baseURL string, req config.Request, vars map[string]string, opts SSEOptions) (RequestInfo, ResponseInfo, SSEStream, error) {
+	req.Headers = append(config.Params{}, req.Headers...)
+	if req.Headers.Get("Accept") == "" {
+		req.Headers = append(req.Headers, config.Param{Name: "Accept", Values: []string{"text/event-stream"}})
+	}
+	if req.Headers.Get("Accept-Encoding") == "" {
+		// compressing proxies tend to buffer streams
+		req.Headers = append(req.Headers, config.Param{Name: "Accept-Encoding", Values: []string{"identity"}})
+	}
+	if opts.Window > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Window)
+		defer cancel()
+	}
+	ctx, waits := withWaitRecorder(ctx)
+	reqObj, auth, ri, err := prepareRequest(ctx, client, baseURL, req, vars)
+	if err != nil {
+		return ri, ResponseInfo{Waits: waits.list()}, SSEStream{}, err
+	}
+	resp, tt, duration, err := send(client, reqObj, auth, &ri, req.Retry)
+	if err != nil {
+		return ri, ResponseInfo{Duration: duration, Timing: tt.timing(time.Now()), Waits: waits.list()}, SSEStream{}, fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		// not a stream (typically an error response): keep the body for assertions
+		info, err := readResponse(resp, req, vars, duration, tt)
+		info.Waits = waits.list()
+		return ri, info, SSEStream{}, err
+	}
+
+	limit := int64(req.MaxResponseSize)
+	if limit <= 0 {
+		limit = MaxResponseBodySize
+	}
+	raw := &limitedBuffer{limit: limit}
+	hash := sha256.New()
+	events, stop, readErr := readEvents(io.TeeReader(resp.Body, io.MultiWriter(raw, hash)), tt.start, opts)
+	if readErr != nil && ctx.Err() != nil {
+		// the collection window elapsed
+		stop, readErr = SSEStopTimeout, nil
+	}
+	info := ResponseInfo{
+		StatusCode:    resp.StatusCode,
+		Headers:       resp.Header.Clone(),
+		Body:          EventDocument(events, stop),
+		RawBody:       raw.buf.Bytes(),
+		Charset:       "utf-8",
+		BodyTruncated: raw.truncated,
+		BodySize:      raw.size,
+		EncodedSize:   raw.size,
+		BodySHA256:    hex.EncodeToString(hash.Sum(nil)),
+		Duration:      duration,
+		Timing:        tt.timing(time.Now()),
+		Waits:         waits.list(),
+	}
+	stream := SSEStream{Events: events, Stop: stop}
+	if readErr != nil {
+		return ri, info, stream, fmt.Errorf("read event stream: %w", readErr)
+	}
+	return ri, info, stream, nil
+}
+
+// readEvents parses the text/event-stream format until a stop condition is met.
+func readEvents(r io.Reader, start time.Time, opts SSEOptions) ([]SSEEvent, string, error) {
+	br := bufio.NewReader(r)
+	var events []SSEEvent
+	var cur SSEEvent
+	var data []string
+	hasData := false
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			if errors.Is(err, io.EOF) {
+				return events, SSEStopEOF, nil
+			}
+			return events, "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if !hasData {
+				cur = SSEEvent{}
+				continue
+			}
+			cur.Data = strings.Join(data, "\n")
+			if cur.Event == "" {
+				cur.Event = "message"
+			}
+			cur.At = time.Since(start)
+			events = append(events, cur)
+			lastID := cur.ID
+			if opts.Until != nil && opts.Until(eventJSON(cur)) {
+				return events, SSEStopUntil, nil
+			}
+			if opts.MaxEvents > 0 && len(events) >= opts.MaxEvents {
+				return events, SSEStopMaxEvents, nil
+			}
+			// the last event id carries over to later events
+			cur, data, hasData = SSEEvent{ID: lastID}, nil, false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			cur.Event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				cur.ID = value
+			}
+		case "retry":
+			if n, err := strconv.Atoi(value); err == nil {
+				cur.Retry = n
+			}
+		}
+	}
+}
+
+// EventDocument renders collected events as the JSON document used for
+// assertions: each event has event, id, data, json (data parsed as JSON, or
+// null) and at_ms.
+func EventDocument(events []SSEEvent, stop string) string {
+	parts := make([]string, len(events))
+	for i, e := range events {
+		parts[i] = eventJSON(e)
+	}
+	stopJSON, _ := json.Marshal(stop)
+	return fmt.Sprintf(`{"count":%d,"stop":%s,"events":[%s]}`, len(events), stopJSON, strings.Join(parts, ","))
+}
+
+func eventJSON(e SSEEvent) string {
+	doc := struct {
+		Event string          `json:"event"`
+		ID    string          `json:"id"`
+		Data  string          `json:"data"`
+		JSON  json.RawMessage `json:"json"`
+		Retry int             `json:"retry,omitempty"`
+		AtMS  int64           `json:"at_ms"`
+	}{Event: e.Event, ID: e.ID, Data: e.Data, JSON: json.RawMessage("null"), Retry: e.Retry, AtMS: e.At.Milliseconds()}
+	if trimmed := bytes.TrimSpace([]byte(e.Data)); json.Valid(trimmed) && len(trimmed) > 0 {
+		doc.JSON = trimmed
+	}
+	out, _ := json.Marshal(doc)
+	return string(out)
+}
+
+// limitedBuffer keeps the first limit bytes written and counts the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int64
+	size      int64
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	room := b.limit - int64(b.buf.Len())
+	if room < int64(len(p)) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
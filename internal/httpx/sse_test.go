@@ -0,0 +1,83 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"apitest/internal/config"
+)
+
+// newStreamServer writes a few events and then keeps the stream open, like a
+// job-progress endpoint that never finishes on its own.
+func newStreamServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			http.Error(w, `{"error":"bad accept"}`, http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "event: progress\nid: 1\ndata: {\"pct\": 50}\n\n")
+		fmt.Fprint(w, "data: line one\ndata: line two\n\n")
+		fmt.Fprint(w, "event: done\nid: 3\ndata: {\"pct\": 100}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDoSSEStopConditions(t *testing.T) {
+	srv := newStreamServer(t)
+	client := BuildClient(0, false)
+	req := config.Request{URL: "/events"}
+
+	_, resp, stream, err := DoSSE(context.Background(), client, srv.URL, req, nil, SSEOptions{MaxEvents: 2, Window: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("max events: %v", err)
+	}
+	if stream.Stop != SSEStopMaxEvents || len(stream.Events) != 2 {
+		t.Fatalf("stop %s with %d events", stream.Stop, len(stream.Events))
+	}
+	second := stream.Events[1]
+	if second.Event != "message" || second.ID != "1" || second.Data != "line one\nline two" {
+		t.Fatalf("unexpected second event %+v", second)
+	}
+	if gjson.Get(resp.Body, "events[0].json.pct").Num != 50 || gjson.Get(resp.Body, "count").Num != 2 {
+		t.Fatalf("unexpected document %s", resp.Body)
+	}
+
+	until := func(doc string) bool { return gjson.Get(doc, "event").String() == "done" }
+	_, resp, stream, err = DoSSE(context.Background(), client, srv.URL, req, nil, SSEOptions{Until: until, Window: 2 * time.Second})
+	if err != nil || stream.Stop != SSEStopUntil || gjson.Get(resp.Body, "count").Num != 3 {
+		t.Fatalf("until: %v %s %s", err, stream.Stop, resp.Body)
+	}
+
+	start := time.Now()
+	_, resp, stream, err = DoSSE(context.Background(), client, srv.URL, req, nil, SSEOptions{Window: 100 * time.Millisecond})
+	if err != nil || stream.Stop != SSEStopTimeout || len(stream.Events) != 3 {
+		t.Fatalf("timeout: %v %s %d", err, stream.Stop, len(stream.Events))
+	}
+	if time.Since(start) > time.Second || resp.StatusCode != http.StatusOK {
+		t.Fatalf("window not honoured: %s", time.Since(start))
+	}
+}
+
+func TestDoSSEErrorResponseKeepsBody(t *testing.T) {
+	srv := newStreamServer(t)
+	req := config.Request{URL: "/events", Headers: config.Params{{Name: "Accept", Values: []string{"application/json"}}}}
+	_, resp, stream, err := DoSSE(context.Background(), BuildClient(0, false), srv.URL, req, nil, SSEOptions{Window: time.Second})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotAcceptable || stream.Stop != "" || gjson.Get(resp.Body, "error").String() != "bad accept" {
+		t.Fatalf("unexpected error response %d %q", resp.StatusCode, resp.Body)
+	}
+}
@@ -0,0 +1,229 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"apitest/internal/config"
+)
+
+// Wait records time spent throttled before a response was received.
+type Wait struct {
+	// Reason is rate_limit for client-side limiting or retry for a server
+	// 429/503 that was retried.
+	Reason   string
+	Duration time.Duration
+	// Status and Attempt describe the response that triggered a retry.
+	Status  int
+	Attempt int
+	// Host is the request host the wait applied to.
+	Host string
+}
+
+// Retry defaults.
+const (
+	DefaultRetryMax     = 3
+	DefaultRetryMaxWait = 30 * time.Second
+	DefaultRetryBackoff = time.Second
+)
+
+// RateLimiter is a token bucket shared by all steps of a run, with optional
+// per-host buckets on top of the run-wide one.
+type RateLimiter struct {
+	global *bucket
+	hosts  map[string]*bucket
+}
+
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter builds a limiter from plan settings; it returns nil when no
+// rate is configured.
+func NewRateLimiter(rl *config.RateLimit) *RateLimiter {
+	if rl == nil {
+		return nil
+	}
+	l := &RateLimiter{global: newBucket(rl.Rate, rl.Burst), hosts: map[string]*bucket{}}
+	for host, svc := range rl.Services {
+		if b := newBucket(svc.Rate, svc.Burst); b != nil {
+			l.hosts[strings.ToLower(host)] = b
+		}
+	}
+	if l.global == nil && len(l.hosts) == 0 {
+		return nil
+	}
+	return l
+}
+
+func newBucket(rate config.Rate, burst int) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst)}
+}
+
+// reserve takes a token and returns how long the caller must wait for it.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Wait blocks until a request to host may be sent.
+func (l *RateLimiter) Wait(ctx context.Context, host string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, b := range []*bucket{l.global, l.hosts[strings.ToLower(host)]} {
+		if b == nil {
+			continue
+		}
+		if d := b.reserve(now); d > wait {
+			wait = d
+		}
+	}
+	if wait <= 0 {
+		return 0, nil
+	}
+	return wait, sleepContext(ctx, wait)
+}
+
+// throttle blocks until the client's rate limiter lets a request to host
+// through and records the pause. Callers run it before client.Do so the wait
+// counts neither against the client timeout nor towards the response time.
+func throttle(ctx context.Context, client *http.Client, host string) (time.Duration, error) {
+	limiter := clientLimiter(client)
+	if limiter == nil {
+		return 0, nil
+	}
+	wait, err := limiter.Wait(ctx, host)
+	if wait > 0 {
+		recordWait(ctx, Wait{Reason: "rate_limit", Duration: wait, Host: host})
+	}
+	return wait, err
+}
+
+type waitRecorderKey struct{}
+
+type waitRecorder struct {
+	mu    sync.Mutex
+	waits []Wait
+}
+
+func withWaitRecorder(ctx context.Context) (context.Context, *waitRecorder) {
+	rec := &waitRecorder{}
+	return context.WithValue(ctx, waitRecorderKey{}, rec), rec
+}
+
+func recordWait(ctx context.Context, w Wait) {
+	if rec, ok := ctx.Value(waitRecorderKey{}).(*waitRecorder); ok {
+		rec.mu.Lock()
+		rec.waits = append(rec.waits, w)
+		rec.mu.Unlock()
+	}
+}
+
+func (r *waitRecorder) list() []Wait {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Wait(nil), r.waits...)
+}
+
+// retryDelay decides whether resp should be retried and after how long. A
+// Retry-After above the maximum wait is not honoured and the response is kept.
+func retryDelay(policy *config.Retry, resp *http.Response, attempt int) (time.Duration, bool) {
+	if policy == nil {
+		return 0, false
+	}
+	on := policy.On
+	if len(on) == 0 {
+		on = []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}
+	}
+	matched := false
+	for _, code := range on {
+		if resp.StatusCode == code {
+			matched = true
+			break
+		}
+	}
+	maxRetries := policy.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultRetryMax
+	}
+	if !matched || attempt > maxRetries {
+		return 0, false
+	}
+	maxWait := time.Duration(policy.MaxWaitMS) * time.Millisecond
+	if maxWait <= 0 {
+		maxWait = DefaultRetryMaxWait
+	}
+	delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		backoff := time.Duration(policy.BackoffMS) * time.Millisecond
+		if backoff <= 0 {
+			backoff = DefaultRetryBackoff
+		}
+		// exponential backoff without a server hint
+		delay = backoff << (attempt - 1)
+	}
+	if delay > maxWait {
+		return 0, false
+	}
+	return delay, true
+}
+
+// parseRetryAfter accepts delay-seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
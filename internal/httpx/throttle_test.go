@@ -0,0 +1,148 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"apitest/internal/config"
+)
+
+func TestRateLimiterSharedAcrossClients(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	limiter := NewRateLimiter(&config.RateLimit{Rate: 20, Burst: 2})
+	start := time.Now()
+	var waits []Wait
+	for i := 0; i < 4; i++ {
+		// a new client per request, as the runner builds one per step
+		client, err := NewClient(time.Second, ClientOptions{Limiter: limiter})
+		if err != nil {
+			t.Fatalf("client: %v", err)
+		}
+		_, resp, err := DoRequest(context.Background(), client, srv.URL, config.Request{URL: "/"}, nil)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		waits = append(waits, resp.Waits...)
+	}
+	// burst of two, then 50ms per request
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("limiter did not throttle: %s", elapsed)
+	}
+	if len(waits) != 2 || waits[0].Reason != "rate_limit" || hits != 4 {
+		t.Fatalf("unexpected waits %+v (hits %d)", waits, hits)
+	}
+}
+
+func TestRateLimitWaitOutsideTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// one request per 300ms with a 100ms client timeout
+	limiter := NewRateLimiter(&config.RateLimit{Rate: config.Rate(1.0 / 0.3)})
+	for i := 0; i < 2; i++ {
+		client, _ := NewClient(100*time.Millisecond, ClientOptions{Limiter: limiter})
+		_, resp, err := DoRequest(context.Background(), client, srv.URL, config.Request{URL: "/"}, nil)
+		if err != nil {
+			t.Fatalf("request %d: rate limit wait should not count against the timeout: %v", i, err)
+		}
+		if i == 1 {
+			if len(resp.Waits) != 1 || resp.Waits[0].Duration < 200*time.Millisecond {
+				t.Fatalf("expected a rate limit wait, got %+v", resp.Waits)
+			}
+			if resp.Duration >= resp.Waits[0].Duration || resp.Timing.Total >= resp.Waits[0].Duration {
+				t.Fatalf("duration %s / timing %s should exclude the %s wait", resp.Duration, resp.Timing.Total, resp.Waits[0].Duration)
+			}
+		}
+	}
+}
+
+func TestRateLimiterPerService(t *testing.T) {
+	limiter := NewRateLimiter(&config.RateLimit{Services: map[string]config.RateLimit{"Slow.example": {Rate: 10}}})
+	if wait, _ := limiter.Wait(context.Background(), "fast.example"); wait != 0 {
+		t.Fatalf("unlimited host waited %s", wait)
+	}
+	if wait, _ := limiter.Wait(context.Background(), "slow.example"); wait != 0 {
+		t.Fatalf("first request should use the burst, waited %s", wait)
+	}
+	if wait, _ := limiter.Wait(context.Background(), "slow.example"); wait < 50*time.Millisecond {
+		t.Fatalf("second request waited only %s", wait)
+	}
+	if NewRateLimiter(&config.RateLimit{}) != nil {
+		t.Fatalf("expected nil limiter without rates")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	req := config.Request{
+		Method: http.MethodPost,
+		URL:    "/",
+		Body:   &config.RequestBody{Raw: "payload"},
+		Retry:  &config.Retry{},
+	}
+	_, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Body != "payload" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Body)
+	}
+	if len(resp.Waits) != 2 || resp.Waits[1].Attempt != 2 || resp.Waits[1].Status != http.StatusTooManyRequests {
+		t.Fatalf("unexpected waits %+v", resp.Waits)
+	}
+}
+
+func TestRetryAfterBeyondMaxWait(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	req := config.Request{URL: "/", Retry: &config.Retry{MaxWaitMS: 1000}}
+	_, resp, err := DoRequest(context.Background(), BuildClient(time.Second, false), srv.URL, req, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || calls != 1 || len(resp.Waits) != 0 {
+		t.Fatalf("expected no retry, got status %d after %d calls", resp.StatusCode, calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if d, ok := parseRetryAfter("7", now); !ok || d != 7*time.Second {
+		t.Fatalf("seconds: %s %v", d, ok)
+	}
+	date := now.Add(90 * time.Second).Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(date, now); !ok || d != 90*time.Second {
+		t.Fatalf("http date: %s %v", d, ok)
+	}
+	if _, ok := parseRetryAfter(strings.Repeat("x", 3), now); ok {
+		t.Fatalf("expected invalid value to be ignored")
+	}
+}
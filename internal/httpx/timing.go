@@ -0,0 +1,126 @@
+package httpx
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timing breaks a request down into the phases reported by net/http/httptrace.
+// Phases that did not happen (e.g. DNS for an IP literal, TLS for plain HTTP or
+// everything but the wait on a reused connection) stay zero.
+type Timing struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// Wait is the time between writing the request and the first response byte.
+	Wait time.Duration
+	// TTFB is measured from the start of the request to the first response byte.
+	TTFB     time.Duration
+	Transfer time.Duration
+	Total    time.Duration
+	Reused   bool
+	Phases   []Phase
+}
+
+// Phase is a single span of the timing waterfall, relative to the request start.
+type Phase struct {
+	Name     string
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// Metric returns the named timing value; names are matched case-insensitively.
+func (t Timing) Metric(name string) (time.Duration, bool) {
+	switch strings.ToLower(name) {
+	case "dns":
+		return t.DNS, true
+	case "connect", "tcp":
+		return t.Connect, true
+	case "tls":
+		return t.TLS, true
+	case "wait", "server":
+		return t.Wait, true
+	case "ttfb":
+		return t.TTFB, true
+	case "transfer", "download":
+		return t.Transfer, true
+	case "total":
+		return t.Total, true
+	default:
+		return 0, false
+	}
+}
+
+// timingTrace collects httptrace callbacks; callbacks may fire from dialer goroutines.
+type timingTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func newTimingTrace() *timingTrace {
+	return &timingTrace{}
+}
+
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	set := func(dst *time.Time, overwrite bool) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if overwrite || dst.IsZero() {
+			*dst = time.Now()
+		}
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { set(&t.dnsStart, false) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { set(&t.dnsDone, false) },
+		ConnectStart:      func(string, string) { set(&t.connectStart, false) },
+		ConnectDone:       func(string, string, error) { set(&t.connectDone, false) },
+		TLSHandshakeStart: func() { set(&t.tlsStart, false) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { set(&t.tlsDone, false) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		// redirects write several requests; the last one belongs to the final response
+		WroteRequest:         func(httptrace.WroteRequestInfo) { set(&t.wroteRequest, true) },
+		GotFirstResponseByte: func() { set(&t.firstByte, true) },
+	}
+}
+
+// timing converts the collected timestamps into durations, with end marking the
+// moment the body was fully read.
+func (t *timingTrace) timing(end time.Time) Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := Timing{Total: end.Sub(t.start), Reused: t.reused}
+	span := func(name string, from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		d := to.Sub(from)
+		out.Phases = append(out.Phases, Phase{Name: name, Start: from.Sub(t.start), Duration: d})
+		return d
+	}
+	out.DNS = span("dns", t.dnsStart, t.dnsDone)
+	out.Connect = span("connect", t.connectStart, t.connectDone)
+	out.TLS = span("tls", t.tlsStart, t.tlsDone)
+	out.Wait = span("wait", t.wroteRequest, t.firstByte)
+	out.Transfer = span("transfer", t.firstByte, end)
+	if !t.firstByte.IsZero() {
+		out.TTFB = t.firstByte.Sub(t.start)
+	}
+	return out
+}
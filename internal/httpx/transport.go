@@ -0,0 +1,124 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ClientOptions configures the transport built by NewClient.
+type ClientOptions struct {
+	Insecure bool
+	// UnixSocket sends every request over this Unix domain socket.
+	UnixSocket string
+	// Resolve holds curl-style host:port:addr overrides applied when dialing.
+	Resolve []string
+	// Limiter, when set, throttles every request; share one per run.
+	Limiter *RateLimiter
+	// Tokens caches OAuth2 tokens; share one per run. NewClient creates a
+	// private cache when it is nil.
+	Tokens *TokenCache
+}
+
+// NewClient builds an HTTP client whose dialer honours the Unix socket and
+// DNS override options. TLS still verifies against the requested host name.
+func NewClient(timeout time.Duration, opts ClientOptions) (*http.Client, error) {
+	dial, err := opts.DialContext()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{DialContext: dial}
+	if opts.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	}
+	tokens := opts.Tokens
+	if tokens == nil {
+		tokens = NewTokenCache()
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &clientTransport{base: transport, limiter: opts.Limiter, tokens: tokens},
+	}, nil
+}
+
+// clientTransport carries per-run state that request helpers look up from
+// the client; it adds nothing to the round trip itself.
+type clientTransport struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+	tokens  *TokenCache
+}
+
+func (t *clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req)
+}
+
+// clientLimiter returns the rate limiter of a client built by NewClient.
+func clientLimiter(client *http.Client) *RateLimiter {
+	if t, ok := client.Transport.(*clientTransport); ok {
+		return t.limiter
+	}
+	return nil
+}
+
+// clientTokens returns the token cache of a client built by NewClient.
+func clientTokens(client *http.Client) *TokenCache {
+	if t, ok := client.Transport.(*clientTransport); ok {
+		return t.tokens
+	}
+	return nil
+}
+
+// DialContext returns the dial function used by NewClient, for protocols that
+// manage their own connections (websocket steps).
+func (o ClientOptions) DialContext() (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	overrides, err := ParseResolve(o.Resolve)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if o.UnixSocket != "" {
+			return dialer.DialContext(ctx, "unix", o.UnixSocket)
+		}
+		if target, ok := overrides[strings.ToLower(addr)]; ok {
+			addr = target
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}, nil
+}
+
+// ParseResolve parses host:port:addr entries into a map from host:port to the
+// address to dial. IPv6 addresses may be written with or without brackets.
+func ParseResolve(entries []string) (map[string]string, error) {
+	out := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid resolve %q, expect host:port:addr", entry)
+		}
+		host, port := parts[0], parts[1]
+		addr := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+		if net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("invalid resolve %q: %s is not an IP address", entry, addr)
+		}
+		out[strings.ToLower(net.JoinHostPort(host, port))] = net.JoinHostPort(addr, port)
+	}
+	return out, nil
+}
+
+// SplitUnixBaseURL recognises base URLs of the form unix:///path/app.sock,
+// optionally followed by ":/prefix" for a base path. It returns the socket path
+// and the HTTP base URL to build request URLs against.
+func SplitUnixBaseURL(baseURL string) (socket, httpBase string, ok bool) {
+	rest, found := strings.CutPrefix(baseURL, "unix://")
+	if !found {
+		return "", baseURL, false
+	}
+	socket, prefix, _ := strings.Cut(rest, ":")
+	return socket, "http://localhost" + strings.TrimRight(prefix, "/"), true
+}
@@ -0,0 +1,74 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"apitest/internal/config"
+)
+
+func TestNewClientUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	socket, base, ok := SplitUnixBaseURL("unix://" + sock + ":/v1/")
+	if !ok || socket != sock || base != "http://localhost/v1" {
+		t.Fatalf("split unix base url: %q %q %v", socket, base, ok)
+	}
+	client, err := NewClient(time.Second, ClientOptions{UnixSocket: socket})
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	_, resp, err := DoRequest(context.Background(), client, base, config.Request{URL: "/containers/json"}, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if resp.Body != "/v1/containers/json" {
+		t.Fatalf("unexpected body %q", resp.Body)
+	}
+}
+
+func TestNewClientResolve(t *testing.T) {
+	var gotHost string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+
+	client, err := NewClient(time.Second, ClientOptions{Resolve: []string{"api.example.test:" + port + ":127.0.0.1"}})
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	if _, _, err := DoRequest(context.Background(), client, "http://api.example.test:"+port, config.Request{URL: "/"}, nil); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if gotHost != "api.example.test:"+port {
+		t.Fatalf("host header %q", gotHost)
+	}
+
+	for _, bad := range []string{"api.example.test:443", "api.example.test:443:backend"} {
+		if _, err := ParseResolve([]string{bad}); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+	m, err := ParseResolve([]string{"API.example.test:443:[::1]"})
+	if err != nil || m["api.example.test:443"] != "[::1]:443" {
+		t.Fatalf("ipv6 resolve %v %v", m, err)
+	}
+}
@@ -0,0 +1,134 @@
+package httpx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"apitest/internal/config"
+	"apitest/internal/templ"
+)
+
+const (
+	xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>`
+	soap11NS       = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12NS       = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// renderXMLBody renders a body.xml value. Strings are templated as-is;
+// mappings are rendered as elements behind an XML declaration.
+func renderXMLBody(v config.XMLValue, vars map[string]string) (string, error) {
+	if !v.IsMapping() {
+		return templ.ApplyString(v.Text, vars)
+	}
+	var sb strings.Builder
+	sb.WriteString(xmlDeclaration)
+	if err := writeXMLFields(&sb, v.Fields, vars); err != nil {
+		return sb.String(), err
+	}
+	return sb.String(), nil
+}
+
+// renderSOAP wraps the rendered header and body in a SOAP envelope and returns
+// the content type matching the SOAP version.
+func renderSOAP(env config.SOAPEnvelope, vars map[string]string) (string, string, error) {
+	action, err := templ.ApplyString(env.Action, vars)
+	if err != nil {
+		return "", "", fmt.Errorf("action: %w", err)
+	}
+	ns, contentType := soap11NS, "text/xml; charset=utf-8"
+	switch env.Version.String() {
+	case "", "1.1":
+	case "1.2":
+		ns, contentType = soap12NS, "application/soap+xml; charset=utf-8"
+		if action != "" {
+			contentType += fmt.Sprintf(`; action="%s"`, action)
+		}
+	default:
+		return "", "", fmt.Errorf("unsupported soap version %s", env.Version)
+	}
+	var sb strings.Builder
+	sb.WriteString(xmlDeclaration)
+	fmt.Fprintf(&sb, `<soap:Envelope xmlns:soap="%s">`, ns)
+	for _, part := range []struct {
+		name  string
+		value *config.XMLValue
+	}{{"Header", env.Header}, {"Body", env.Body}} {
+		if part.value == nil {
+			if part.name == "Body" {
+				sb.WriteString("<soap:Body/>")
+			}
+			continue
+		}
+		fmt.Fprintf(&sb, "<soap:%s>", part.name)
+		if err := writeXMLContent(&sb, *part.value, vars, true); err != nil {
+			return sb.String(), contentType, fmt.Errorf("%s: %w", strings.ToLower(part.name), err)
+		}
+		fmt.Fprintf(&sb, "</soap:%s>", part.name)
+	}
+	sb.WriteString("</soap:Envelope>")
+	return sb.String(), contentType, nil
+}
+
+func writeXMLFields(sb *strings.Builder, fields []config.XMLField, vars map[string]string) error {
+	for _, f := range fields {
+		if strings.HasPrefix(f.Name, "@") {
+			continue
+		}
+		if f.Name == "#text" {
+			if err := writeXMLContent(sb, f.Value, vars, false); err != nil {
+				return err
+			}
+			continue
+		}
+		items := []config.XMLValue{f.Value}
+		if f.Value.List != nil {
+			items = f.Value.List
+		}
+		for _, item := range items {
+			if err := writeXMLElement(sb, f.Name, item, vars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeXMLElement(sb *strings.Builder, name string, v config.XMLValue, vars map[string]string) error {
+	sb.WriteString("<" + name)
+	for _, f := range v.Fields {
+		if !strings.HasPrefix(f.Name, "@") {
+			continue
+		}
+		val, err := templ.ApplyString(f.Value.Text, vars)
+		if err != nil {
+			return fmt.Errorf("%s%s: %w", name, f.Name, err)
+		}
+		sb.WriteString(" " + f.Name[1:] + `="`)
+		xml.EscapeText(sb, []byte(val))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString(">")
+	if err := writeXMLContent(sb, v, vars, false); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	sb.WriteString("</" + name + ">")
+	return nil
+}
+
+// writeXMLContent writes the children of an element. Scalars are escaped text,
+// unless raw is set (SOAP header/body given as an XML string).
+func writeXMLContent(sb *strings.Builder, v config.XMLValue, vars map[string]string, raw bool) error {
+	if v.IsMapping() {
+		return writeXMLFields(sb, v.Fields, vars)
+	}
+	text, err := templ.ApplyString(v.Text, vars)
+	if err != nil {
+		return err
+	}
+	if raw {
+		sb.WriteString(text)
+		return nil
+	}
+	return xml.EscapeText(sb, []byte(text))
+}
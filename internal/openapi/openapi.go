@@ -0,0 +1,311 @@
+// Package openapi matches requests against the operations of an OpenAPI 3
+// document and validates bodies, statuses and headers against its schemas.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"apitest/internal/schema"
+)
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Spec is a loaded OpenAPI document.
+type Spec struct {
+	doc   *schema.Schema
+	bases []string
+	ops   []*Operation
+}
+
+// Operation is one method of a path item.
+type Operation struct {
+	Method string
+	// Path is the path template as written in the spec, e.g. /users/{id}.
+	Path     string
+	ID       string
+	node     map[string]interface{}
+	pattern  *regexp.Regexp
+	literals int
+}
+
+// String returns "METHOD /path/{template}".
+func (o *Operation) String() string {
+	return o.Method + " " + o.Path
+}
+
+// Load reads an OpenAPI 3 document in JSON or YAML.
+func Load(path string) (*Spec, error) {
+	doc, err := schema.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := doc.Document().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("openapi: %s is not an object", path)
+	}
+	if _, ok := root["openapi"]; !ok {
+		return nil, fmt.Errorf("openapi: %s has no openapi version field", path)
+	}
+	s := &Spec{doc: doc}
+	if servers, ok := root["servers"].([]interface{}); ok {
+		for _, srv := range servers {
+			m, _ := srv.(map[string]interface{})
+			raw, _ := m["url"].(string)
+			if u, err := url.Parse(raw); err == nil {
+				if base := strings.TrimRight(u.Path, "/"); base != "" {
+					s.bases = append(s.bases, base)
+				}
+			}
+		}
+	}
+	// longest base path first so /api/v2 wins over /api
+	sort.Slice(s.bases, func(i, j int) bool { return len(s.bases[i]) > len(s.bases[j]) })
+
+	paths, _ := root["paths"].(map[string]interface{})
+	for template, item := range paths {
+		itemNode, ok := s.deref(item).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pattern, literals := compileTemplate(template)
+		for _, m := range methods {
+			opNode, ok := itemNode[m].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := opNode["operationId"].(string)
+			s.ops = append(s.ops, &Operation{
+				Method:   strings.ToUpper(m),
+				Path:     template,
+				ID:       id,
+				node:     opNode,
+				pattern:  pattern,
+				literals: literals,
+			})
+		}
+	}
+	return s, nil
+}
+
+// compileTemplate turns /users/{id}/avatar.{ext} into a regexp and counts the
+// literal characters used to prefer concrete paths over templated ones.
+func compileTemplate(template string) (*regexp.Regexp, int) {
+	var sb strings.Builder
+	literals := 0
+	sb.WriteString("^")
+	rest := template
+	for {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(rest[open:], "}")
+		if end < 0 {
+			break
+		}
+		sb.WriteString(regexp.QuoteMeta(rest[:open]))
+		literals += open
+		sb.WriteString("[^/]+")
+		rest = rest[open+end+1:]
+	}
+	sb.WriteString(regexp.QuoteMeta(rest))
+	literals += len(rest)
+	sb.WriteString("/?$")
+	return regexp.MustCompile(sb.String()), literals
+}
+
+// Match finds the operation for a method and request URL. The path of any
+// declared server URL is stripped first.
+func (s *Spec) Match(method, rawURL string) (*Operation, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	candidates := []string{}
+	for _, base := range s.bases {
+		if rest, ok := strings.CutPrefix(path, base); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+			if rest == "" {
+				rest = "/"
+			}
+			candidates = append(candidates, rest)
+		}
+	}
+	candidates = append(candidates, path)
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	for _, p := range candidates {
+		var best *Operation
+		for _, op := range s.ops {
+			if op.Method != method || !op.pattern.MatchString(p) {
+				continue
+			}
+			if best == nil || op.literals > best.literals {
+				best = op
+			}
+		}
+		if best != nil {
+			return best, true
+		}
+	}
+	return nil, false
+}
+
+// ValidateRequest checks the request body against the operation's requestBody.
+func (s *Spec) ValidateRequest(op *Operation, contentType, body string) []string {
+	rb, ok := s.deref(op.node["requestBody"]).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if body == "" {
+		if required, _ := rb["required"].(bool); required {
+			return []string{"request body is required"}
+		}
+		return nil
+	}
+	return s.validateContent("request body", rb, contentType, body)
+}
+
+// ValidateResponse checks the status, the documented headers and the body.
+func (s *Spec) ValidateResponse(op *Operation, status int, headers http.Header, body string) []string {
+	responses, _ := op.node["responses"].(map[string]interface{})
+	resp, key := s.response(responses, status)
+	if resp == nil {
+		declared := make([]string, 0, len(responses))
+		for k := range responses {
+			declared = append(declared, k)
+		}
+		sort.Strings(declared)
+		return []string{fmt.Sprintf("status %d is not documented (declared: %s)", status, strings.Join(declared, ", "))}
+	}
+	var out []string
+	hdrs, _ := resp["headers"].(map[string]interface{})
+	names := make([]string, 0, len(hdrs))
+	for name := range hdrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		h, _ := s.deref(hdrs[name]).(map[string]interface{})
+		value := headers.Get(name)
+		if value == "" {
+			if required, _ := h["required"].(bool); required {
+				out = append(out, fmt.Sprintf("header %s is required by response %s", name, key))
+			}
+			continue
+		}
+		if sch, ok := h["schema"]; ok {
+			for _, v := range s.doc.At(sch).Validate(headerValue(s.deref(sch), value)) {
+				out = append(out, fmt.Sprintf("header %s: %s", name, v.Message))
+			}
+		}
+	}
+	if body == "" {
+		return out
+	}
+	return append(out, s.validateContent("response body", resp, headers.Get("Content-Type"), body)...)
+}
+
+// response picks the response object for status: exact code, then a range
+// such as 2XX, then default.
+func (s *Spec) response(responses map[string]interface{}, status int) (map[string]interface{}, string) {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if r, ok := s.deref(responses[key]).(map[string]interface{}); ok {
+			return r, key
+		}
+	}
+	return nil, ""
+}
+
+func (s *Spec) validateContent(what string, holder map[string]interface{}, contentType, body string) []string {
+	content, _ := holder["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" {
+		mediaType = "application/json"
+	}
+	media, ok := content[mediaType]
+	if !ok {
+		media, ok = content[strings.SplitN(mediaType, "/", 2)[0]+"/*"]
+	}
+	if !ok {
+		media, ok = content["*/*"]
+	}
+	if !ok {
+		declared := make([]string, 0, len(content))
+		for k := range content {
+			declared = append(declared, k)
+		}
+		sort.Strings(declared)
+		return []string{fmt.Sprintf("%s content type %s is not documented (declared: %s)", what, mediaType, strings.Join(declared, ", "))}
+	}
+	m, _ := media.(map[string]interface{})
+	sch, ok := m["schema"]
+	if !ok || !strings.Contains(mediaType, "json") {
+		return nil
+	}
+	doc, err := schema.DecodeJSON(body)
+	if err != nil {
+		return []string{fmt.Sprintf("%s is not valid JSON: %v", what, err)}
+	}
+	var out []string
+	for _, v := range s.doc.At(sch).Validate(doc) {
+		out = append(out, fmt.Sprintf("%s %s", what, v.String()))
+	}
+	return out
+}
+
+// deref follows $ref chains to components inside the document.
+func (s *Spec) deref(node interface{}) interface{} {
+	for i := 0; i < 16; i++ {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return node
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return node
+		}
+		next, err := s.doc.Resolve(ref)
+		if err != nil {
+			return nil
+		}
+		node = next
+	}
+	return nil
+}
+
+// headerValue converts a header string to the JSON type its schema expects.
+func headerValue(sch interface{}, value string) interface{} {
+	m, _ := sch.(map[string]interface{})
+	switch m["type"] {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
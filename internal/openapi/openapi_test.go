@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const specYAML = `
+openapi: 3.0.3
+info:
+  title: users
+  version: "1"
+servers:
+  - url: https://staging.example.com/api
+paths:
+  /system/user/{id}:
+    get:
+      operationId: getUser
+      responses:
+        '200':
+          description: ok
+          headers:
+            X-Request-Id:
+              required: true
+              schema:
+                type: string
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+        4XX:
+          $ref: '#/components/responses/Error'
+  /system/user/me:
+    get:
+      responses:
+        '200':
+          description: ok
+  /system/user:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/User'
+      responses:
+        '201':
+          description: created
+components:
+  responses:
+    Error:
+      description: error
+      content:
+        application/json:
+          schema:
+            type: object
+            required: [msg]
+  schemas:
+    User:
+      type: object
+      required: [id, name]
+      properties:
+        id:
+          type: integer
+        name:
+          type: string
+        email:
+          type: string
+          format: email
+          nullable: true
+`
+
+func loadSpec(t *testing.T) *Spec {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(path, []byte(specYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return spec
+}
+
+func TestMatch(t *testing.T) {
+	spec := loadSpec(t)
+	cases := map[string]string{
+		"GET https://staging.example.com/api/system/user/42":   "GET /system/user/{id}",
+		"GET http://127.0.0.1:8080/system/user/42?verbose=1":   "GET /system/user/{id}",
+		"GET https://staging.example.com/api/system/user/me":   "GET /system/user/me",
+		"POST https://staging.example.com/api/system/user/":    "POST /system/user",
+		"DELETE https://staging.example.com/api/system/user/1": "",
+		"GET https://staging.example.com/api/system/user/1/x":  "",
+	}
+	for in, want := range cases {
+		method, url, _ := strings.Cut(in, " ")
+		op, ok := spec.Match(method, url)
+		got := ""
+		if ok {
+			got = op.String()
+		}
+		if got != want {
+			t.Fatalf("match %s => %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := loadSpec(t)
+	op, _ := spec.Match("GET", "/system/user/7")
+	hdr := http.Header{"Content-Type": {"application/json"}, "X-Request-Id": {"abc"}}
+	if v := spec.ValidateResponse(op, 200, hdr, `{"id": 7, "name": "bob", "email": null}`); len(v) != 0 {
+		t.Fatalf("expected valid response: %v", v)
+	}
+
+	got := spec.ValidateResponse(op, 200, http.Header{"Content-Type": {"application/json"}}, `{"id": "7", "email": "x"}`)
+	want := []string{
+		"header X-Request-Id is required by response 200",
+		`response body (root): missing required property "name"`,
+		`response body /email: "x" is not a valid email`,
+		"response body /id: expected integer, got string",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected violations:\n%s", strings.Join(got, "\n"))
+	}
+
+	if v := spec.ValidateResponse(op, 404, hdr, `{}`); len(v) != 1 || !strings.Contains(v[0], `"msg"`) {
+		t.Fatalf("expected 4XX response schema to apply: %v", v)
+	}
+	if v := spec.ValidateResponse(op, 500, hdr, `{}`); len(v) != 1 || !strings.Contains(v[0], "status 500 is not documented") {
+		t.Fatalf("expected undocumented status: %v", v)
+	}
+	if v := spec.ValidateResponse(op, 200, http.Header{"Content-Type": {"text/html"}, "X-Request-Id": {"a"}}, "<html>"); len(v) != 1 || !strings.Contains(v[0], "text/html is not documented") {
+		t.Fatalf("expected undocumented content type: %v", v)
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	spec := loadSpec(t)
+	op, _ := spec.Match("POST", "/system/user")
+	if v := spec.ValidateRequest(op, "", ""); len(v) != 1 || v[0] != "request body is required" {
+		t.Fatalf("expected required body: %v", v)
+	}
+	if v := spec.ValidateRequest(op, "application/json; charset=utf-8", `{"id": 1}`); len(v) != 1 || !strings.Contains(v[0], `"name"`) {
+		t.Fatalf("expected missing name: %v", v)
+	}
+	if v := spec.ValidateRequest(op, "application/json", `{"id": 1, "name": "a"}`); len(v) != 0 {
+		t.Fatalf("expected valid request: %v", v)
+	}
+}
@@ -3,18 +3,25 @@ package report
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
+	"apitest/internal/assert"
+	"apitest/internal/charset"
+	"apitest/internal/config"
+	"apitest/internal/httpx"
 	"apitest/internal/runner"
 )
 
 const (
 	maxReportBodyLength = 4000
+	waterfallWidth      = 40
 )
 
 // GenerateMarkdown builds a markdown report file for the run result.
@@ -55,6 +62,15 @@ func GenerateMarkdown(result runner.Result, outputPath string) error {
 	}
 	writeLine("")
 
+	if len(result.Unmatched) > 0 {
+		writeLine("## Steps Without OpenAPI Operation")
+		writeLine("")
+		for _, name := range result.Unmatched {
+			writeLine(fmt.Sprintf("- %s", name))
+		}
+		writeLine("")
+	}
+
 	for _, step := range result.Steps {
 		writeStep(writeLine, step)
 	}
@@ -70,6 +86,13 @@ func writeStep(writeLine func(string), step runner.StepResult) {
 	writeLine(fmt.Sprintf("## Step: %s (%s)", step.Name, status))
 	writeLine("")
 	writeLine(fmt.Sprintf("- Duration: %s", step.EndTime.Sub(step.StartTime)))
+	if len(step.Waits) > 0 {
+		var throttled time.Duration
+		for _, w := range step.Waits {
+			throttled += w.Duration
+		}
+		writeLine(fmt.Sprintf("- Throttled: %s in %d wait(s)", throttled.Round(time.Millisecond), len(step.Waits)))
+	}
 	if step.Error != "" {
 		writeLine(fmt.Sprintf("- Error: %s", step.Error))
 	}
@@ -79,17 +102,16 @@ func writeStep(writeLine func(string), step runner.StepResult) {
 	writeLine("")
 	writeLine(fmt.Sprintf("- Method: %s", step.Request.Method))
 	writeLine(fmt.Sprintf("- URL: %s", step.Request.URL))
+	if step.Operation != "" {
+		writeLine(fmt.Sprintf("- OpenAPI Operation: %s", step.Operation))
+	}
 	if len(step.Request.Query) > 0 {
 		writeLine("- Query:")
-		for k, v := range step.Request.Query {
-			writeLine(fmt.Sprintf("  - %s: %s", k, maskSensitive(k, v)))
-		}
+		writeParams(writeLine, step.Request.Query)
 	}
 	if len(step.Request.Headers) > 0 {
 		writeLine("- Headers:")
-		for k, v := range step.Request.Headers {
-			writeLine(fmt.Sprintf("  - %s: %s", k, maskSensitive(k, v)))
-		}
+		writeParams(writeLine, step.Request.Headers)
 	}
 	if step.Request.Body != "" {
 		writeLine("- Body:")
@@ -97,6 +119,12 @@ func writeStep(writeLine func(string), step runner.StepResult) {
 		writeLine(truncateBody(formatBody(maskBody(step.Request.Body))))
 		writeLine("```")
 	}
+	if step.Request.CanonicalString != "" {
+		writeLine("- Signed String:")
+		writeLine("```")
+		writeLine(truncateBody(step.Request.CanonicalString))
+		writeLine("```")
+	}
 
 	writeLine("")
 	writeLine("### Response")
@@ -108,10 +136,23 @@ func writeStep(writeLine func(string), step runner.StepResult) {
 			writeLine(fmt.Sprintf("  - %s: %s", k, maskSensitive(k, strings.Join(v, ","))))
 		}
 	}
+	if cs := step.Response.Charset; cs != "" && cs != "utf-8" {
+		if charset.Supported(cs) {
+			writeLine(fmt.Sprintf("- Charset: %s (decoded to UTF-8)", cs))
+		} else {
+			writeLine(fmt.Sprintf("- Charset: %s (not supported, body shown as received)", cs))
+		}
+	}
+	if step.StopReason != "" {
+		writeLine(fmt.Sprintf("- Events: %d (stopped by %s)", len(step.Events), step.StopReason))
+	}
+	if step.Response.SavedTo != "" {
+		writeLine(fmt.Sprintf("- Saved To: %s (%d bytes, sha256 %s)", step.Response.SavedTo, step.Response.BodySize, step.Response.BodySHA256))
+	}
 	if step.Response.Body != "" {
 		bodyText := truncateBody(formatBody(maskBody(step.Response.Body)))
 		if step.Response.BodyTruncated {
-			bodyText += "\n... (truncated)"
+			bodyText += fmt.Sprintf("\n... (truncated, %d bytes total)", step.Response.BodySize)
 		}
 		writeLine("- Body:")
 		writeLine("```")
@@ -119,6 +160,10 @@ func writeStep(writeLine func(string), step runner.StepResult) {
 		writeLine("```")
 	}
 
+	writeTiming(writeLine, step.Response.Timing)
+	writeWaits(writeLine, step.Waits)
+	writeTranscript(writeLine, step.Transcript)
+
 	if len(step.Extracted) > 0 {
 		writeLine("")
 		writeLine("### Extracted Vars")
@@ -130,18 +175,151 @@ func writeStep(writeLine func(string), step runner.StepResult) {
 	if len(step.Assertions) > 0 {
 		writeLine("")
 		writeLine("### Assertions")
+		writeLine("")
+		passed, skipped := 0, 0
+		for _, ar := range step.Assertions {
+			switch {
+			case ar.Pass:
+				passed++
+			case ar.Skipped:
+				skipped++
+			}
+		}
+		summary := fmt.Sprintf("%d passed, %d failed", passed, len(step.Assertions)-passed-skipped)
+		if skipped > 0 {
+			summary += fmt.Sprintf(", %d not evaluated", skipped)
+		}
+		writeLine(summary)
+		writeLine("")
 		for i, ar := range step.Assertions {
 			prefix := "FAIL"
-			if ar.Pass {
+			switch {
+			case ar.Pass:
 				prefix = "PASS"
+			case ar.Skipped:
+				writeLine(fmt.Sprintf("%d. **SKIP** %s (not evaluated)", i+1, ar.Message))
+				continue
 			}
 			writeLine(fmt.Sprintf("%d. **%s** %s", i+1, prefix, ar.Message))
+			for _, d := range ar.Details {
+				writeLine(fmt.Sprintf("   - %s", d))
+			}
+			writeAssertionTree(writeLine, ar.Children, "   ")
+			if ar.Diff != "" {
+				writeLine("")
+				writeLine("```diff")
+				writeLine(strings.TrimRight(ar.Diff, "\n"))
+				writeLine("```")
+			}
 		}
 	}
 
 	writeLine("")
 }
 
+// writeParams lists request parameters in the order they were set; a repeated
+// parameter gets one line per value.
+func writeParams(writeLine func(string), params config.Params) {
+	for _, p := range params {
+		for _, v := range p.Values {
+			writeLine(fmt.Sprintf("  - %s: %s", p.Name, maskSensitive(p.Name, v)))
+		}
+	}
+}
+
+// writeAssertionTree renders the nested results of not, any_of and all_of
+// as an indented list below their wrapper.
+func writeAssertionTree(writeLine func(string), children []assert.Result, indent string) {
+	for _, c := range children {
+		prefix := "FAIL"
+		if c.Pass {
+			prefix = "PASS"
+		}
+		writeLine(fmt.Sprintf("%s- **%s** %s", indent, prefix, c.Message))
+		for _, d := range c.Details {
+			writeLine(fmt.Sprintf("%s  - %s", indent, d))
+		}
+		writeAssertionTree(writeLine, c.Children, indent+"  ")
+	}
+}
+
+// writeTiming renders the httptrace phases as a table with a text waterfall so
+// slow network phases can be told apart from slow server processing.
+func writeTiming(writeLine func(string), timing httpx.Timing) {
+	if timing.Total <= 0 {
+		return
+	}
+	writeLine("")
+	writeLine("### Timing")
+	writeLine("")
+	summary := fmt.Sprintf("- TTFB: %s, Total: %s", timing.TTFB, timing.Total)
+	if timing.Reused {
+		summary += " (reused connection)"
+	}
+	writeLine(summary)
+	if len(timing.Phases) == 0 {
+		return
+	}
+	writeLine("")
+	writeLine("| Phase | Start | Duration | Waterfall |")
+	writeLine("| --- | --- | --- | --- |")
+	for _, p := range timing.Phases {
+		writeLine(fmt.Sprintf("| %s | %s | %s | `%s` |", p.Name, p.Start, p.Duration, waterfallBar(p.Start, p.Duration, timing.Total)))
+	}
+}
+
+// writeWaits explains where a step spent time before its final response:
+// client-side rate limiting or retries of throttled responses.
+func writeWaits(writeLine func(string), waits []httpx.Wait) {
+	if len(waits) == 0 {
+		return
+	}
+	writeLine("")
+	writeLine("### Throttling")
+	writeLine("")
+	for _, w := range waits {
+		switch w.Reason {
+		case "retry":
+			writeLine(fmt.Sprintf("- retry %d after status %d: waited %s (%s)", w.Attempt, w.Status, w.Duration.Round(time.Millisecond), w.Host))
+		default:
+			writeLine(fmt.Sprintf("- rate limit: waited %s (%s)", w.Duration.Round(time.Millisecond), w.Host))
+		}
+	}
+}
+
+// writeTranscript lists websocket messages in order with their offset from connect.
+func writeTranscript(writeLine func(string), transcript []runner.TranscriptEntry) {
+	if len(transcript) == 0 {
+		return
+	}
+	writeLine("")
+	writeLine("### WebSocket Transcript")
+	writeLine("")
+	writeLine("| At | Direction | Message |")
+	writeLine("| --- | --- | --- |")
+	arrows := map[string]string{"send": "→ send", "recv": "← recv", "close": "× close"}
+	for _, e := range transcript {
+		msg := strings.ReplaceAll(truncateBody(maskBody(e.Data)), "|", "\\|")
+		msg = strings.ReplaceAll(msg, "\n", " ")
+		writeLine(fmt.Sprintf("| +%s | %s | `%s` |", e.At.Round(time.Millisecond), arrows[e.Direction], msg))
+	}
+}
+
+func waterfallBar(start, dur, total time.Duration) string {
+	offset := int(float64(start) / float64(total) * waterfallWidth)
+	width := int(float64(dur) / float64(total) * waterfallWidth)
+	if width < 1 {
+		width = 1
+	}
+	if offset > waterfallWidth-1 {
+		offset = waterfallWidth - 1
+	}
+	if offset+width > waterfallWidth {
+		width = waterfallWidth - offset
+	}
+	return strings.Repeat("·", offset) + strings.Repeat("█", width) + strings.Repeat("·", waterfallWidth-offset-width)
+}
+
 func truncateBody(body string) string {
 	if len(body) <= maxReportBodyLength {
 		return body
@@ -167,14 +345,107 @@ func maskBody(body string) string {
 	return replacer
 }
 
-// formatBody prettifies JSON bodies for readability; falls back to original text on failure.
+// formatBody prettifies JSON and XML bodies for readability; falls back to original text on failure.
 func formatBody(body string) string {
 	if body == "" {
 		return body
 	}
+	if strings.HasPrefix(strings.TrimSpace(body), "<") {
+		if pretty, err := formatXML(body); err == nil {
+			return pretty
+		}
+		return body
+	}
 	var buf bytes.Buffer
 	if err := json.Indent(&buf, []byte(body), "", "  "); err != nil {
 		return body
 	}
 	return buf.String()
 }
+
+// formatXML re-indents an XML document, keeping prefixes as written and
+// elements that only hold text on a single line.
+func formatXML(body string) (string, error) {
+	dec := xml.NewDecoder(strings.NewReader(body))
+	dec.Strict = false
+	var toks []xml.Token
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if cd, ok := tok.(xml.CharData); ok && strings.TrimSpace(string(cd)) == "" {
+			continue
+		}
+		toks = append(toks, xml.CopyToken(tok))
+	}
+	var sb strings.Builder
+	depth := 0
+	newline := func() {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(strings.Repeat("  ", depth))
+	}
+	for i := 0; i < len(toks); i++ {
+		switch t := toks[i].(type) {
+		case xml.StartElement:
+			newline()
+			sb.WriteString("<" + rawName(t.Name))
+			for _, a := range t.Attr {
+				sb.WriteString(" " + rawName(a.Name) + `="`)
+				_ = xml.EscapeText(&sb, []byte(a.Value))
+				sb.WriteString(`"`)
+			}
+			if i+1 < len(toks) {
+				if _, ok := toks[i+1].(xml.EndElement); ok {
+					sb.WriteString("/>")
+					i++
+					continue
+				}
+			}
+			sb.WriteString(">")
+			if i+2 < len(toks) {
+				cd, isText := toks[i+1].(xml.CharData)
+				end, isEnd := toks[i+2].(xml.EndElement)
+				if isText && isEnd {
+					_ = xml.EscapeText(&sb, bytes.TrimSpace(cd))
+					sb.WriteString("</" + rawName(end.Name) + ">")
+					i += 2
+					continue
+				}
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			newline()
+			sb.WriteString("</" + rawName(t.Name) + ">")
+		case xml.CharData:
+			newline()
+			_ = xml.EscapeText(&sb, bytes.TrimSpace(t))
+		case xml.Comment:
+			newline()
+			sb.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			newline()
+			sb.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+		case xml.Directive:
+			newline()
+			sb.WriteString("<!" + string(t) + ">")
+		}
+	}
+	if depth != 0 {
+		return "", fmt.Errorf("unbalanced xml")
+	}
+	return sb.String(), nil
+}
+
+func rawName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
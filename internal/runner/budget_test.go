@@ -0,0 +1,58 @@
+package runner_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"apitest/internal/config"
+	"apitest/internal/runner"
+)
+
+func TestIntegrationLatencyBudget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(60 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	planPath := filepath.Join(t.TempDir(), "plan.yaml")
+	planContent := `name: "budget"
+base_url: "` + srv.URL + `"
+latency_budget: 40ms
+steps:
+  - name: "export"
+    latency_budget: 2s
+    request:
+      url: /slow
+  - name: "fast"
+    request:
+      url: /fast
+  - name: "slow"
+    request:
+      url: /slow
+`
+	if err := os.WriteFile(planPath, []byte(planContent), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	plan, err := config.LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+	if plan.LatencyBudget != config.Duration(40*time.Millisecond) {
+		t.Fatalf("unexpected budget %v", plan.LatencyBudget)
+	}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if res.Success || res.FailedStep != "slow" {
+		t.Fatalf("expected only the slow step to blow the budget, failed %q", res.FailedStep)
+	}
+	if !strings.Contains(res.Steps[2].Error, "not <= 40ms") {
+		t.Fatalf("unexpected error %q", res.Steps[2].Error)
+	}
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"apitest/internal/assert"
+	"apitest/internal/httpx"
+	"apitest/internal/openapi"
+)
+
+// checkContract validates a step's exchange against the matching OpenAPI
+// operation. ok is false when the spec has no operation for the request. With
+// skip set the operation is still matched but both checks are returned as
+// skipped, as after a failed assertion in fail-fast mode.
+func checkContract(spec *openapi.Spec, req httpx.RequestInfo, resp httpx.ResponseInfo, skip bool) ([]assert.Result, string, bool) {
+	op, ok := spec.Match(req.Method, req.URL)
+	if !ok {
+		return nil, "", false
+	}
+	if skip {
+		return []assert.Result{
+			{Skipped: true, Message: fmt.Sprintf("openapi: request matches %s", op)},
+			{Skipped: true, Message: fmt.Sprintf("openapi: response %d matches %s", resp.StatusCode, op)},
+		}, op.String(), true
+	}
+	contentType := ""
+	for _, p := range req.Headers {
+		if strings.EqualFold(p.Name, "Content-Type") && len(p.Values) > 0 {
+			contentType = p.Values[0]
+		}
+	}
+	results := []assert.Result{
+		contractResult(fmt.Sprintf("request matches %s", op), spec.ValidateRequest(op, contentType, req.Body)),
+		contractResult(fmt.Sprintf("response %d matches %s", resp.StatusCode, op), spec.ValidateResponse(op, resp.StatusCode, resp.Headers, resp.Body)),
+	}
+	return results, op.String(), true
+}
+
+func contractResult(what string, violations []string) assert.Result {
+	if len(violations) == 0 {
+		return assert.Result{Pass: true, Message: "openapi: " + what}
+	}
+	return assert.Result{
+		Pass:    false,
+		Message: fmt.Sprintf("openapi: %s: %s", strings.Replace(what, "matches", "does not match", 1), violations[0]),
+		Details: violations,
+	}
+}
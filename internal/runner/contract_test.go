@@ -0,0 +1,149 @@
+package runner_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"apitest/internal/config"
+	"apitest/internal/openapi"
+	"apitest/internal/runner"
+)
+
+func TestIntegrationOpenAPIContract(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/users/7":
+			_, _ = w.Write([]byte(`{"id": "7"}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	spec := `openapi: 3.1.0
+info:
+  title: users
+  version: "1"
+servers:
+  - url: /api
+paths:
+  /users/{id}:
+    get:
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [id, name]
+                properties:
+                  id:
+                    type: integer
+`
+	plan := `name: "contract"
+base_url: "` + srv.URL + `/api"
+openapi: spec.yaml
+steps:
+  - name: "health"
+    request:
+      url: /health
+  - name: "user"
+    request:
+      url: /users/7
+`
+	for name, content := range map[string]string{"spec.yaml": spec, "plan.yaml": plan} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	p, err := config.LoadPlan(filepath.Join(dir, "plan.yaml"))
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+	s, err := openapi.Load(filepath.Join(p.Dir, p.OpenAPI))
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	res := runner.Execute(p, runner.RunnerOptions{OpenAPI: s})
+	if res.Success || res.FailedStep != "user" {
+		t.Fatalf("expected contract failure on user step, got %+v", res)
+	}
+	if len(res.Unmatched) != 1 || !strings.HasPrefix(res.Unmatched[0], "health (GET ") {
+		t.Fatalf("unexpected unmatched steps %v", res.Unmatched)
+	}
+	step := res.Steps[1]
+	if step.Operation != "GET /users/{id}" {
+		t.Fatalf("unexpected operation %q", step.Operation)
+	}
+	if len(step.Assertions) != 2 || !step.Assertions[0].Pass || step.Assertions[1].Pass || len(step.Assertions[1].Details) != 2 {
+		t.Fatalf("unexpected contract results %+v", step.Assertions)
+	}
+	if !strings.Contains(step.Error, "response 200 does not match GET /users/{id}") {
+		t.Fatalf("unexpected error %q", step.Error)
+	}
+}
+
+func TestIntegrationOpenAPIContractUsesSentContentType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	spec := `openapi: 3.1.0
+info:
+  title: orders
+  version: "1"
+paths:
+  /orders:
+    post:
+      requestBody:
+        content:
+          application/soap+xml: {}
+      responses:
+        "204":
+          description: ok
+`
+	plan := `name: "contract"
+base_url: "` + srv.URL + `"
+steps:
+  - name: "soap 1.2"
+    request:
+      method: POST
+      url: /orders
+      body:
+        soap:
+          version: 1.2
+          action: urn:CreateOrder
+          body:
+            m:CreateOrder:
+              "@xmlns:m": urn:shop
+`
+	for name, content := range map[string]string{"spec.yaml": spec, "plan.yaml": plan} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	p, err := config.LoadPlan(filepath.Join(dir, "plan.yaml"))
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+	s, err := openapi.Load(filepath.Join(dir, "spec.yaml"))
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	res := runner.Execute(p, runner.RunnerOptions{OpenAPI: s})
+	if !res.Success {
+		t.Fatalf("soap 1.2 request should match the contract: %+v", res.Steps[0].Assertions)
+	}
+	if ct := res.Steps[0].Request.Headers.Get("Content-Type"); !strings.HasPrefix(ct, "application/soap+xml") {
+		t.Fatalf("sent content type not recorded: %q", ct)
+	}
+}
@@ -39,3 +39,38 @@ func TestRunExtractRegex(t *testing.T) {
 		t.Fatalf("unexpected regex %s", out["order"])
 	}
 }
+
+func TestRunExtractXPath(t *testing.T) {
+	resp := httpx.ResponseInfo{Body: `<s:Envelope xmlns:s="urn:s"><s:Body><Token exp="60">xyz</Token></s:Body></s:Envelope>`}
+	out := map[string]string{}
+	defs := map[string]config.ExtractDefinition{
+		"token": {From: "xpath", Path: "/e:Envelope/e:Body/Token", Namespaces: map[string]string{"e": "urn:s"}},
+		"exp":   {From: "xpath", Path: "//Token/@exp"},
+	}
+	if err := runExtract(defs, resp, out); err != nil {
+		t.Fatalf("extract xpath: %v", err)
+	}
+	if out["token"] != "xyz" || out["exp"] != "60" {
+		t.Fatalf("unexpected xpath extract %#v", out)
+	}
+}
+
+func TestRunExtractJSONPathSyntax(t *testing.T) {
+	resp := httpx.ResponseInfo{Body: `{"rows": [{"id": 1893745112083456001, "name": "a"}, {"id": 7, "name": "b"}], "gone": null}`}
+	out := map[string]string{}
+	defs := map[string]config.ExtractDefinition{
+		"big":   {From: "json", Path: "rows.0.id"},
+		"b":     {From: "json", Path: `rows.#(name=="b").id`},
+		"count": {From: "json", Path: "rows.#"},
+		"ids":   {From: "json", Path: "rows.#.name"},
+	}
+	if err := runExtract(defs, resp, out); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if out["big"] != "1893745112083456001" || out["b"] != "7" || out["count"] != "2" || out["ids"] != `["a","b"]` {
+		t.Fatalf("unexpected extract %#v", out)
+	}
+	if err := runExtract(map[string]config.ExtractDefinition{"g": {From: "json", Path: "gone"}}, resp, out); err == nil {
+		t.Fatalf("expected null extraction to fail")
+	}
+}
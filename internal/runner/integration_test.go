@@ -145,3 +145,98 @@ func newTestServer() *httptest.Server {
 	})
 	return httptest.NewServer(mux)
 }
+
+func TestIntegrationReportMasksSignedSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	const apiKey, sessionToken = "key/with+chars", "session-token-123"
+	plan := &config.Plan{Name: "sign", BaseURL: srv.URL, Steps: []config.Step{
+		{
+			Name: "hmac",
+			Request: config.Request{
+				Method: "GET",
+				URL:    "/items?a=1",
+				Auth:   &config.Auth{Type: "api_key", In: "query", Name: "api_key", Value: apiKey},
+				Sign: &config.Sign{
+					Scheme:     "hmac",
+					Secret:     "hmac-secret",
+					Components: []string{"method", "path", "query"},
+					Headers:    map[string]string{"X-Signature": "{{signature}}"},
+				},
+			},
+		},
+		{
+			Name: "sigv4",
+			Request: config.Request{
+				Method: "GET",
+				URL:    "/bucket",
+				Sign:   &config.Sign{Scheme: "sigv4", KeyID: "AKID", Secret: "sigv4-secret", Region: "us-east-1", Service: "s3", SessionToken: sessionToken},
+			},
+		},
+	}}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if !res.Success {
+		t.Fatalf("run failed: %+v", res.Steps)
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "report.md")
+	if err := report.GenerateMarkdown(res, reportPath); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	text := string(data)
+	if strings.Count(text, "- Signed String:") != 2 {
+		t.Fatalf("signed strings missing from report:\n%s", text)
+	}
+	for _, secret := range []string{apiKey, "key%2Fwith%2Bchars", sessionToken, "hmac-secret", "sigv4-secret"} {
+		if strings.Contains(text, secret) {
+			t.Fatalf("report leaks %q:\n%s", secret, text)
+		}
+	}
+	if !strings.Contains(res.Steps[0].Request.CanonicalString, "api_key=[masked]") {
+		t.Fatalf("unexpected canonical string %q", res.Steps[0].Request.CanonicalString)
+	}
+}
+
+func TestIntegrationReportKeepsParamOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	plan := &config.Plan{Name: "params", BaseURL: srv.URL, Steps: []config.Step{{
+		Name: "list",
+		Request: config.Request{
+			URL:     "/items",
+			Query:   config.Params{{Name: "z", Values: []string{"1"}}, {Name: "a", Values: []string{"2", "3"}}},
+			Headers: config.Params{{Name: "X-Tag", Values: []string{"b", "a"}}, {Name: "Accept", Values: []string{"*/*"}}},
+		},
+	}}}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if !res.Success {
+		t.Fatalf("run failed: %+v", res.Steps)
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "report.md")
+	if err := report.GenerateMarkdown(res, reportPath); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	for _, block := range []string{
+		"- Query:\n  - z: 1\n  - a: 2\n  - a: 3\n",
+		"- Headers:\n  - X-Tag: b\n  - X-Tag: a\n  - Accept: */*\n",
+	} {
+		if !strings.Contains(string(data), block) {
+			t.Fatalf("report missing %q:\n%s", block, data)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package runner_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"apitest/internal/config"
+	"apitest/internal/runner"
+)
+
+func TestIntegrationLogicalAssertions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"id": 1}}`))
+	}))
+	defer srv.Close()
+
+	planPath := filepath.Join(t.TempDir(), "plan.yaml")
+	planContent := `name: "logic"
+base_url: "` + srv.URL + `"
+expect_status: 200
+steps:
+  - name: "full"
+    request:
+      url: /full
+    assert:
+      - any_of:
+          - all_of:
+              - type: status
+                expect: 200
+              - type: json
+                path: data
+                op: exists
+          - type: status
+            expect: 204
+      - not:
+          type: body
+          op: regex
+          expect: "(?i)error"
+  - name: "empty"
+    request:
+      url: /empty
+    assert:
+      - any_of:
+          - all_of:
+              - type: status
+                expect: 200
+              - type: json
+                path: data
+                op: exists
+          - type: status
+            expect: 204
+`
+	if err := os.WriteFile(planPath, []byte(planContent), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	plan, err := config.LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+	if not := plan.Steps[0].Assert[1].Not; not == nil || not.Op != "regex" || not.BaseDir == "" {
+		t.Fatalf("unexpected not assertion %+v", plan.Steps[0].Assert[1])
+	}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if !res.Success {
+		t.Fatalf("expected success, failed %q: %s", res.FailedStep, res.Steps[len(res.Steps)-1].Error)
+	}
+	if got := res.Steps[1].Assertions; len(got) != 1 || len(got[0].Children) != 2 {
+		t.Fatalf("expected the nested status check to replace expect_status, got %+v", got)
+	}
+}
@@ -3,16 +3,21 @@ package runner
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/tidwall/gjson"
 
 	"apitest/internal/assert"
 	"apitest/internal/config"
 	"apitest/internal/httpx"
+	"apitest/internal/openapi"
 	"apitest/internal/templ"
+	"apitest/internal/xpath"
 )
 
 // RunnerOptions customize plan execution.
@@ -21,6 +26,18 @@ type RunnerOptions struct {
 	Vars     map[string]string
 	Insecure bool
 	Verbose  bool
+	// MaxResponseSize overrides the plan-level body limit for steps without their own.
+	MaxResponseSize int64
+	// UnixSocket sends all requests over a Unix domain socket; a unix:// base URL sets it too.
+	UnixSocket string
+	// Resolve holds curl-style host:port:addr DNS overrides.
+	Resolve []string
+	// OpenAPI, when set, validates every HTTP step against the matching operation.
+	OpenAPI *openapi.Spec
+	// UpdateSnapshots rewrites the golden files of snapshot assertions.
+	UpdateSnapshots bool
+	// Rate overrides the plan's run-wide rate limit, in requests per second.
+	Rate float64
 	// Progress, when provided, receives lifecycle notifications for each step.
 	Progress func(ProgressEvent)
 }
@@ -50,17 +67,29 @@ type Result struct {
 	Steps      []StepResult
 	Success    bool
 	FailedStep string
-	StartTime  time.Time
-	EndTime    time.Time
+	// Unmatched lists steps without a matching OpenAPI operation.
+	Unmatched []string
+	StartTime time.Time
+	EndTime   time.Time
 }
 
 // StepResult describes per-step outcome.
 type StepResult struct {
-	Name       string
-	Success    bool
-	Error      string
-	Request    httpx.RequestInfo
-	Response   httpx.ResponseInfo
+	Name     string
+	Success  bool
+	Error    string
+	Request  httpx.RequestInfo
+	Response httpx.ResponseInfo
+	// Operation is the OpenAPI operation the step was validated against.
+	Operation string
+	// Transcript lists the messages of a websocket step.
+	Transcript []TranscriptEntry
+	// Events holds the events collected by an sse step.
+	Events     []httpx.SSEEvent
+	StopReason string
+	// Waits lists the time the step spent throttled by the rate limiter or
+	// waiting out Retry-After before its final response.
+	Waits      []httpx.Wait
 	Assertions []assert.Result
 	Extracted  map[string]string
 	StartTime  time.Time
@@ -75,7 +104,30 @@ func Execute(plan *config.Plan, opts RunnerOptions) Result {
 	if opts.BaseURL != "" {
 		baseURL = opts.BaseURL
 	}
+	clientOpts := httpx.ClientOptions{Insecure: opts.Insecure, UnixSocket: opts.UnixSocket, Resolve: opts.Resolve}
+	if socket, httpBase, ok := httpx.SplitUnixBaseURL(baseURL); ok {
+		if clientOpts.UnixSocket == "" {
+			clientOpts.UnixSocket = socket
+		}
+		baseURL = httpBase
+	}
+	rateLimit := plan.RateLimit
+	if opts.Rate > 0 {
+		rl := config.RateLimit{}
+		if rateLimit != nil {
+			rl = *rateLimit
+		}
+		rl.Rate = config.Rate(opts.Rate)
+		rateLimit = &rl
+	}
+	// one limiter for the whole run so the budget is shared across steps
+	clientOpts.Limiter = httpx.NewRateLimiter(rateLimit)
+	clientOpts.Tokens = httpx.NewTokenCache()
 	stepTotal := len(plan.Steps)
+	maxResponseSize := plan.MaxResponseSize
+	if opts.MaxResponseSize > 0 {
+		maxResponseSize = config.ByteSize(opts.MaxResponseSize)
+	}
 
 	ctx := make(map[string]string)
 	for k, v := range plan.Vars {
@@ -95,10 +147,36 @@ func Execute(plan *config.Plan, opts RunnerOptions) Result {
 		if opts.Verbose {
 			fmt.Printf("==> Step: %s\n", step.Name)
 		}
-		client := httpx.BuildClient(time.Duration(step.Request.TimeoutMS)*time.Millisecond, opts.Insecure)
-		reqInfo, respInfo, err := httpx.DoRequest(context.Background(), client, baseURL, step.Request, ctx)
+		req := step.Request
+		if req.MaxResponseSize == 0 {
+			req.MaxResponseSize = maxResponseSize
+		}
+		if req.Auth == nil {
+			req.Auth = plan.Auth
+		}
+		if req.Sign == nil {
+			req.Sign = plan.Sign
+		}
+		if req.Retry == nil {
+			req.Retry = plan.Retry
+		}
+		var reqInfo httpx.RequestInfo
+		var respInfo httpx.ResponseInfo
+		var err error
+		if step.WebSocket != nil {
+			reqInfo, respInfo, err = runWebSocket(*step.WebSocket, baseURL, clientOpts, ctx, &sr)
+		} else if step.SSE != nil {
+			reqInfo, respInfo, err = runSSE(*step.SSE, req, baseURL, clientOpts, ctx, &sr)
+		} else {
+			var client *http.Client
+			client, err = httpx.NewClient(time.Duration(req.TimeoutMS)*time.Millisecond, clientOpts)
+			if err == nil {
+				reqInfo, respInfo, err = httpx.DoRequest(context.Background(), client, baseURL, req, ctx)
+			}
+		}
 		sr.Request = reqInfo
 		sr.Response = respInfo
+		sr.Waits = respInfo.Waits
 		if err != nil {
 			sr.Success = false
 			sr.Error = err.Error()
@@ -119,12 +197,44 @@ func Execute(plan *config.Plan, opts RunnerOptions) Result {
 		}
 
 		// assertions
-		sr.Assertions = assert.Evaluate(step.Assert, respInfo.Body, respInfo.Headers, respInfo.StatusCode, ctx)
-		for _, ares := range sr.Assertions {
-			if !ares.Pass {
-				sr.Success = false
-				sr.Error = ares.Message
-				break
+		soft := plan.Soft || step.Soft
+		evaluate := assert.EvaluateResponse
+		if soft {
+			evaluate = assert.EvaluateSoft
+		}
+		assertions := snapshotAssertions(plan, step, opts.UpdateSnapshots)
+		if a, ok := defaultStatusAssertion(plan, step); ok {
+			assertions = append([]config.Assertion{a}, assertions...)
+		}
+		sr.Assertions = append(sr.Assertions, evaluate(assertions, respInfo, ctx)...)
+		// in fail-fast mode the budget and contract checks are skipped too
+		stopped := !soft && len(assert.Failures(sr.Assertions)) > 0
+		budget := step.LatencyBudget
+		if budget == 0 {
+			budget = plan.LatencyBudget
+		}
+		if budget > 0 {
+			latency := config.Assertion{Type: "latency", Op: "<=", Expect: time.Duration(budget).String()}
+			if stopped {
+				sr.Assertions = append(sr.Assertions, assert.Result{Skipped: true, Message: assert.Describe(latency)})
+			} else {
+				sr.Assertions = append(sr.Assertions, assert.EvaluateResponse([]config.Assertion{latency}, respInfo, ctx)...)
+			}
+		}
+		if opts.OpenAPI != nil && step.WebSocket == nil && step.SSE == nil {
+			results, op, ok := checkContract(opts.OpenAPI, reqInfo, respInfo, stopped)
+			if ok {
+				sr.Operation = op
+				sr.Assertions = append(sr.Assertions, results...)
+			} else {
+				res.Unmatched = append(res.Unmatched, fmt.Sprintf("%s (%s %s)", step.Name, reqInfo.Method, reqInfo.URL))
+			}
+		}
+		if failed := assert.Failures(sr.Assertions); len(failed) > 0 {
+			sr.Success = false
+			sr.Error = failed[0].Message
+			if len(failed) > 1 {
+				sr.Error = fmt.Sprintf("%s (and %d more failed assertions)", failed[0].Message, len(failed)-1)
 			}
 		}
 
@@ -173,6 +283,73 @@ func Execute(plan *config.Plan, opts RunnerOptions) Result {
 	return res
 }
 
+// defaultStatusAssertion applies the plan's expect_status to an HTTP step
+// that does not check the status itself. Websocket handshakes answer 101 and
+// are left alone.
+func defaultStatusAssertion(plan *config.Plan, step config.Step) (config.Assertion, bool) {
+	if plan.ExpectStatus == nil || step.WebSocket != nil {
+		return config.Assertion{}, false
+	}
+	if checksStatus(step.Assert) {
+		return config.Assertion{}, false
+	}
+	op := "=="
+	if _, ok := plan.ExpectStatus.([]interface{}); ok {
+		op = "in"
+	}
+	return config.Assertion{Type: "status", Op: op, Expect: plan.ExpectStatus}, true
+}
+
+// checksStatus reports whether any assertion, including nested not, any_of
+// and all_of ones, looks at the status code.
+func checksStatus(assertions []config.Assertion) bool {
+	for _, a := range assertions {
+		if strings.EqualFold(a.Type, "status") || checksStatus(a.AnyOf) || checksStatus(a.AllOf) {
+			return true
+		}
+		if a.Not != nil && checksStatus([]config.Assertion{*a.Not}) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotAssertions points snapshot assertions at their golden files under
+// __snapshots__/<plan>/<step> next to the plan file.
+func snapshotAssertions(plan *config.Plan, step config.Step, update bool) []config.Assertion {
+	out := append([]config.Assertion(nil), step.Assert...)
+	for i, a := range out {
+		if !strings.EqualFold(a.Type, "snapshot") {
+			continue
+		}
+		planName := plan.Name
+		if planName == "" {
+			planName = "plan"
+		}
+		name := snapshotName(step.Name)
+		if a.Name != "" {
+			name += "." + snapshotName(a.Name)
+		}
+		out[i].SnapshotFile = filepath.Join(plan.Dir, "__snapshots__", snapshotName(planName), name)
+		out[i].UpdateSnapshot = update
+	}
+	return out
+}
+
+// snapshotName keeps letters (including CJK), digits, dots and dashes.
+func snapshotName(s string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(s))
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
 func normalizeVarKey(key string) string {
 	key = strings.TrimSpace(key)
 	if len(key) >= 2 {
@@ -208,6 +385,18 @@ func extractValue(def config.ExtractDefinition, resp httpx.ResponseInfo) (string
 		if !val.Exists() {
 			return "", fmt.Errorf("json path %s not found", def.Path)
 		}
+		if val.Type == gjson.Null {
+			return "", fmt.Errorf("json path %s is null", def.Path)
+		}
+		return val.String(), nil
+	case "xpath", "xml":
+		val, err := xpath.Query(resp.Body, def.Path, def.Namespaces)
+		if err != nil {
+			return "", err
+		}
+		if !val.Exists() {
+			return "", fmt.Errorf("xpath %s not found", def.Path)
+		}
 		return val.String(), nil
 	case "header":
 		vals := resp.Headers.Values(def.Path)
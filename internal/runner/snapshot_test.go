@@ -0,0 +1,89 @@
+package runner_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"apitest/internal/config"
+	"apitest/internal/runner"
+)
+
+func TestIntegrationSnapshot(t *testing.T) {
+	name := "bob"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"rows": [{"id": 91, "name": "` + name + `"}], "createTime": "2024-05-01T10:00:00Z"}, "code": 0}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	planPath := filepath.Join(dir, "plan.yaml")
+	planContent := `name: "user api"
+base_url: "` + srv.URL + `"
+steps:
+  - name: "list users"
+    request:
+      url: /users
+    assert:
+      - type: snapshot
+        ignore: [data.createTime, data.rows.#.id]
+`
+	if err := os.WriteFile(planPath, []byte(planContent), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	plan, err := config.LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+
+	golden := filepath.Join(dir, "__snapshots__", "user_api", "list_users.json")
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if !res.Success || !strings.HasSuffix(res.Steps[0].Assertions[0].Message, "created") {
+		t.Fatalf("expected snapshot to be created: %+v", res.Steps[0].Assertions)
+	}
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	want := `{
+  "code": 0,
+  "data": {
+    "createTime": "<ignored>",
+    "rows": [
+      {
+        "id": "<ignored>",
+        "name": "bob"
+      }
+    ]
+  }
+}
+`
+	if string(data) != want {
+		t.Fatalf("unexpected golden file:\n%s", data)
+	}
+
+	if res := runner.Execute(plan, runner.RunnerOptions{}); !res.Success {
+		t.Fatalf("expected snapshot match: %s", res.Steps[0].Error)
+	}
+
+	name = "alice"
+	res = runner.Execute(plan, runner.RunnerOptions{})
+	if res.Success {
+		t.Fatalf("expected snapshot mismatch")
+	}
+	d := res.Steps[0].Assertions[0].Diff
+	if !strings.Contains(d, `-        "name": "bob"`) || !strings.Contains(d, `+        "name": "alice"`) {
+		t.Fatalf("unexpected diff:\n%s", d)
+	}
+
+	if res := runner.Execute(plan, runner.RunnerOptions{UpdateSnapshots: true}); !res.Success {
+		t.Fatalf("expected update to pass: %s", res.Steps[0].Error)
+	}
+	if data, _ := os.ReadFile(golden); !strings.Contains(string(data), "alice") {
+		t.Fatalf("golden file not updated:\n%s", data)
+	}
+}
@@ -0,0 +1,137 @@
+package runner_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"apitest/internal/config"
+	"apitest/internal/openapi"
+	"apitest/internal/runner"
+)
+
+func TestIntegrationSoftAssertions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code": 1, "data": {"id": 7, "name": "bob"}}`))
+	}))
+	defer srv.Close()
+
+	planPath := filepath.Join(t.TempDir(), "plan.yaml")
+	planContent := `name: "soft"
+base_url: "` + srv.URL + `"
+steps:
+  - name: "soft"
+    soft: true
+    request:
+      url: /user
+    assert:
+      - type: json
+        path: code
+        op: "=="
+        expect: 0
+      - type: json
+        path: data.id
+        op: "=="
+        expect: 7
+      - type: json
+        path: data.name
+        op: "=="
+        expect: alice
+  - name: "hard"
+    request:
+      url: /user
+    assert:
+      - type: json
+        path: code
+        op: "=="
+        expect: 0
+      - type: json
+        path: data.id
+        op: "=="
+        expect: 7
+`
+	if err := os.WriteFile(planPath, []byte(planContent), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	plan, err := config.LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if res.Success || len(res.Steps) != 1 {
+		t.Fatalf("expected the soft step to fail and stop the run, got %+v", res)
+	}
+	got := res.Steps[0].Assertions
+	if len(got) != 3 || got[0].Pass || !got[1].Pass || got[2].Pass || got[2].Skipped {
+		t.Fatalf("expected every assertion to be evaluated, got %+v", got)
+	}
+	if !strings.HasSuffix(res.Steps[0].Error, "(and 1 more failed assertions)") {
+		t.Fatalf("unexpected error %q", res.Steps[0].Error)
+	}
+
+	plan.Steps = plan.Steps[1:]
+	res = runner.Execute(plan, runner.RunnerOptions{})
+	got = res.Steps[0].Assertions
+	if len(got) != 2 || got[0].Pass || !got[1].Skipped || got[1].Message != "json data.id == 7" {
+		t.Fatalf("expected the second assertion to be skipped, got %+v", got)
+	}
+}
+
+func TestIntegrationFailFastSkipsBudgetAndContract(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code": 1}`))
+	}))
+	defer srv.Close()
+
+	specPath := filepath.Join(t.TempDir(), "spec.yaml")
+	spec := `openapi: 3.1.0
+info:
+  title: users
+  version: "1"
+paths:
+  /user:
+    get:
+      responses:
+        "200":
+          description: ok
+`
+	if err := os.WriteFile(specPath, []byte(spec), 0o644); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+	s, err := openapi.Load(specPath)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	plan := &config.Plan{Name: "fail fast", BaseURL: srv.URL, LatencyBudget: config.Duration(time.Minute), Steps: []config.Step{{
+		Name:    "hard",
+		Request: config.Request{URL: "/user"},
+		Assert:  []config.Assertion{{Type: "json", Path: "code", Op: "==", Expect: 0}},
+	}}}
+
+	res := runner.Execute(plan, runner.RunnerOptions{OpenAPI: s})
+	got := res.Steps[0].Assertions
+	if len(got) != 4 || got[0].Pass || got[0].Skipped {
+		t.Fatalf("expected the json assertion to fail first, got %+v", got)
+	}
+	for _, r := range got[1:] {
+		if !r.Skipped {
+			t.Fatalf("budget and contract checks should be skipped after a failure, got %+v", got)
+		}
+	}
+	if got[1].Message != `latency <= "1m0s"` || got[2].Message != "openapi: request matches GET /user" || res.Steps[0].Operation != "GET /user" {
+		t.Fatalf("unexpected skipped results %+v (operation %q)", got[1:], res.Steps[0].Operation)
+	}
+
+	plan.Soft = true
+	res = runner.Execute(plan, runner.RunnerOptions{OpenAPI: s})
+	got = res.Steps[0].Assertions
+	if len(got) != 4 || !got[1].Pass || !got[2].Pass || !got[3].Pass {
+		t.Fatalf("soft mode should still evaluate budget and contract, got %+v", got)
+	}
+}
@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"apitest/internal/assert"
+	"apitest/internal/config"
+	"apitest/internal/httpx"
+)
+
+// runSSE opens the event stream described by req and collects events on sr.
+// The stream is bounded by the sse window rather than the client timeout.
+func runSSE(sse config.SSE, req config.Request, baseURL string, clientOpts httpx.ClientOptions, vars map[string]string, sr *StepResult) (httpx.RequestInfo, httpx.ResponseInfo, error) {
+	client, err := httpx.NewClient(0, clientOpts)
+	if err != nil {
+		return httpx.RequestInfo{}, httpx.ResponseInfo{}, err
+	}
+	window := time.Duration(sse.TimeoutMS) * time.Millisecond
+	if window <= 0 {
+		window = time.Duration(req.TimeoutMS) * time.Millisecond
+	}
+	if window <= 0 {
+		window = config.DefaultTimeoutMS * time.Millisecond
+	}
+	opts := httpx.SSEOptions{MaxEvents: sse.MaxEvents, Window: window}
+	if len(sse.Until) > 0 {
+		opts.Until = func(doc string) bool {
+			for _, r := range assert.EvaluateResponse(sse.Until, httpx.ResponseInfo{Body: doc}, vars) {
+				if !r.Pass {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	reqInfo, respInfo, stream, err := httpx.DoSSE(context.Background(), client, baseURL, req, vars, opts)
+	sr.Events = stream.Events
+	sr.StopReason = stream.Stop
+	return reqInfo, respInfo, err
+}
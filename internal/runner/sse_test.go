@@ -0,0 +1,75 @@
+package runner_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"apitest/internal/config"
+	"apitest/internal/runner"
+)
+
+func TestIntegrationSSEStep(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "event: progress\nid: %d\ndata: {\"pct\": %d}\n\n", i, i*30)
+		}
+		fmt.Fprint(w, "event: done\nid: job-7\ndata: {\"result\": \"ok\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	planPath := filepath.Join(t.TempDir(), "plan.yaml")
+	planContent := `name: "sse"
+base_url: "` + srv.URL + `"
+steps:
+  - name: "progress"
+    request:
+      method: POST
+      url: /jobs/7/events
+    sse:
+      timeout_ms: 2000
+      until:
+        - type: json
+          path: event
+          op: "=="
+          expect: done
+    assert:
+      - type: json
+        path: count
+        op: "=="
+        expect: 4
+      - type: json
+        path: events[2].json.pct
+        op: gt
+        expect: 80
+      - type: json
+        path: stop
+        op: "=="
+        expect: until
+    extract:
+      job:
+        from: json
+        path: events[3].id
+`
+	if err := os.WriteFile(planPath, []byte(planContent), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	plan, err := config.LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if !res.Success {
+		t.Fatalf("sse step failed: %s", res.Steps[0].Error)
+	}
+	step := res.Steps[0]
+	if step.Extracted["job"] != "job-7" || step.StopReason != "until" || len(step.Events) != 4 {
+		t.Fatalf("unexpected sse result: %v %s %d", step.Extracted, step.StopReason, len(step.Events))
+	}
+}
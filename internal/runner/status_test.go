@@ -0,0 +1,64 @@
+package runner_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"apitest/internal/config"
+	"apitest/internal/runner"
+)
+
+func TestIntegrationExpectStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	planPath := filepath.Join(t.TempDir(), "plan.yaml")
+	planContent := `name: "status"
+base_url: "` + srv.URL + `"
+expect_status: 2xx
+steps:
+  - name: "created"
+    request:
+      url: /created
+  - name: "expected 404"
+    request:
+      url: /missing
+    assert:
+      - type: status
+        op: in
+        expect: [404, 410]
+  - name: "unexpected 404"
+    request:
+      url: /missing
+`
+	if err := os.WriteFile(planPath, []byte(planContent), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	plan, err := config.LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if res.Success || res.FailedStep != "unexpected 404" {
+		t.Fatalf("expected only the last step to fail, failed %q", res.FailedStep)
+	}
+	if got := res.Steps[0].Assertions; len(got) != 1 || got[0].Message != "status == 2xx" {
+		t.Fatalf("expected the plan default on the first step, got %+v", got)
+	}
+	if got := res.Steps[1].Assertions; len(got) != 1 || !got[0].Pass {
+		t.Fatalf("expected the explicit status assertion only, got %+v", got)
+	}
+	if res.Steps[2].Error != "status 404 != 2xx" {
+		t.Fatalf("unexpected error %q", res.Steps[2].Error)
+	}
+}
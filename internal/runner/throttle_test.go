@@ -0,0 +1,67 @@
+package runner_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"apitest/internal/config"
+	"apitest/internal/runner"
+)
+
+func TestIntegrationRetryAndRateLimit(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/busy" && atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	planPath := filepath.Join(t.TempDir(), "plan.yaml")
+	planContent := `name: "throttle"
+base_url: "` + srv.URL + `"
+rate_limit:
+  rate: 5/s
+retry:
+  max_wait_ms: 1000
+steps:
+  - name: "first"
+    request:
+      url: /ping
+  - name: "busy"
+    request:
+      url: /busy
+    assert:
+      - type: status
+        op: "=="
+        expect: 200
+`
+	if err := os.WriteFile(planPath, []byte(planContent), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	plan, err := config.LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if !res.Success {
+		t.Fatalf("plan failed: %s", res.Steps[len(res.Steps)-1].Error)
+	}
+	waits := res.Steps[1].Waits
+	// the limiter is shared with the first step, then the 429 is retried and
+	// the retry itself is throttled again
+	reasons := []string{}
+	for _, w := range waits {
+		reasons = append(reasons, w.Reason)
+	}
+	if len(waits) != 3 || reasons[0] != "rate_limit" || reasons[1] != "retry" || reasons[2] != "rate_limit" {
+		t.Fatalf("unexpected waits %v", reasons)
+	}
+}
@@ -0,0 +1,242 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"apitest/internal/assert"
+	"apitest/internal/config"
+	"apitest/internal/httpx"
+	"apitest/internal/templ"
+	"apitest/internal/wsx"
+)
+
+// TranscriptEntry is one event of a websocket session, relative to the connect time.
+type TranscriptEntry struct {
+	At        time.Duration
+	Direction string // send, recv or close
+	Data      string
+}
+
+// runWebSocket connects, runs the script and records the transcript, script
+// assertions and extracted values on sr. The returned response describes the
+// handshake so step-level assertions can check it.
+func runWebSocket(ws config.WebSocket, baseURL string, clientOpts httpx.ClientOptions, vars map[string]string, sr *StepResult) (httpx.RequestInfo, httpx.ResponseInfo, error) {
+	ri := httpx.RequestInfo{Method: http.MethodGet, URL: ws.URL}
+	target, err := templ.ApplyString(ws.URL, vars)
+	if err != nil {
+		return ri, httpx.ResponseInfo{}, fmt.Errorf("url template: %w", err)
+	}
+	target = websocketURL(baseURL, target)
+	ri.URL = target
+
+	header := http.Header{}
+	for _, param := range ws.Headers {
+		values := make([]string, 0, len(param.Values))
+		for _, v := range param.Values {
+			repl, err := templ.ApplyString(v, vars)
+			if err != nil {
+				return ri, httpx.ResponseInfo{}, fmt.Errorf("header %s: %w", param.Name, err)
+			}
+			values = append(values, repl)
+			header.Add(param.Name, repl)
+		}
+		ri.Headers.Set(param.Name, values...)
+	}
+
+	dial, err := clientOpts.DialContext()
+	if err != nil {
+		return ri, httpx.ResponseInfo{}, err
+	}
+	dialer := &wsx.Dialer{DialContext: dial, Subprotocols: ws.Subprotocols}
+	if clientOpts.Insecure {
+		dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	}
+	timeout := time.Duration(ws.TimeoutMS) * time.Millisecond
+	if timeout <= 0 {
+		timeout = config.DefaultTimeoutMS * time.Millisecond
+	}
+	var waits []httpx.Wait
+	if clientOpts.Limiter != nil {
+		host := hostOf(target)
+		wait, err := clientOpts.Limiter.Wait(context.Background(), host)
+		if wait > 0 {
+			waits = append(waits, httpx.Wait{Reason: "rate_limit", Duration: wait, Host: host})
+		}
+		if err != nil {
+			return ri, httpx.ResponseInfo{Waits: waits}, err
+		}
+	}
+	dialCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	conn, resp, err := dialer.Dial(dialCtx, target, header)
+	respInfo := httpx.ResponseInfo{Duration: time.Since(start), Waits: waits}
+	if resp != nil {
+		respInfo.StatusCode = resp.StatusCode
+		respInfo.Headers = resp.Header
+	}
+	if err != nil {
+		return ri, respInfo, fmt.Errorf("websocket connect: %w", err)
+	}
+
+	s := &wsSession{conn: conn, start: time.Now(), vars: templ.MergeContexts(vars, nil), sr: sr, handshake: respInfo, timeout: timeout}
+	err = s.run(ws.Script)
+	if !s.closed {
+		_ = conn.Close()
+		s.record("close", fmt.Sprintf("%d", wsx.CloseNormal))
+	}
+	return ri, respInfo, err
+}
+
+type wsSession struct {
+	conn      *wsx.Conn
+	start     time.Time
+	vars      map[string]string
+	sr        *StepResult
+	handshake httpx.ResponseInfo
+	timeout   time.Duration
+	closed    bool
+}
+
+func (s *wsSession) record(direction, data string) {
+	s.sr.Transcript = append(s.sr.Transcript, TranscriptEntry{At: time.Since(s.start), Direction: direction, Data: data})
+}
+
+func (s *wsSession) run(script []config.WebSocketAction) error {
+	for i, action := range script {
+		if s.closed && (action.Send != nil || action.Expect != nil) {
+			return fmt.Errorf("script[%d]: connection already closed", i)
+		}
+		var err error
+		switch {
+		case action.Send != nil:
+			err = s.send(action.Send)
+		case action.Expect != nil:
+			err = s.expect(*action.Expect)
+		case action.SleepMS > 0:
+			time.Sleep(time.Duration(action.SleepMS) * time.Millisecond)
+		case action.Close:
+			err = s.conn.Close()
+			s.closed = true
+			s.record("close", fmt.Sprintf("%d", wsx.CloseNormal))
+		default:
+			err = errors.New("empty action")
+		}
+		if err != nil {
+			return fmt.Errorf("script[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (s *wsSession) send(payload interface{}) error {
+	var text string
+	if str, ok := payload.(string); ok {
+		repl, err := templ.ApplyString(str, s.vars)
+		if err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+		text = repl
+	} else {
+		replaced, err := templ.ApplyInterface(payload, s.vars)
+		if err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+		data, err := json.Marshal(replaced)
+		if err != nil {
+			return fmt.Errorf("send marshal: %w", err)
+		}
+		text = string(data)
+	}
+	s.record("send", text)
+	return s.conn.WriteMessage(wsx.TextMessage, []byte(text))
+}
+
+func (s *wsSession) expect(exp config.WebSocketExpect) error {
+	timeout := s.timeout
+	if exp.TimeoutMS > 0 {
+		timeout = time.Duration(exp.TimeoutMS) * time.Millisecond
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := s.conn.SetReadDeadline(deadline); err != nil {
+			return err
+		}
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			var ce *wsx.CloseError
+			var ne net.Error
+			switch {
+			case errors.As(err, &ce):
+				s.closed = true
+				s.record("close", strings.TrimPrefix(ce.Error(), "websocket closed: "))
+				return fmt.Errorf("expect: connection closed by server (%d)", ce.Code)
+			case errors.As(err, &ne) && ne.Timeout():
+				return fmt.Errorf("expect: no matching message within %s", timeout)
+			default:
+				return fmt.Errorf("expect: %w", err)
+			}
+		}
+		s.record("recv", string(data))
+
+		msg := s.handshake
+		msg.Body = string(data)
+		msg.BodySize = int64(len(data))
+		results := assert.EvaluateResponse(exp.Assert, msg, s.vars)
+		var failed *assert.Result
+		for i := range results {
+			if !results[i].Pass {
+				failed = &results[i]
+				break
+			}
+		}
+		if failed != nil && exp.SkipUnmatched {
+			continue
+		}
+		s.sr.Assertions = append(s.sr.Assertions, results...)
+		if failed != nil {
+			return errors.New(failed.Message)
+		}
+		extracted := map[string]string{}
+		if err := runExtract(exp.Extract, msg, extracted); err != nil {
+			return err
+		}
+		for k, v := range extracted {
+			s.vars[k] = v
+			s.sr.Extracted[k] = v
+		}
+		return nil
+	}
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// websocketURL resolves a relative url against the base URL and maps http(s)
+// schemes to ws(s).
+func websocketURL(baseURL, target string) string {
+	if !strings.Contains(target, "://") && baseURL != "" {
+		target = strings.TrimRight(baseURL, "/") + target
+	}
+	switch {
+	case strings.HasPrefix(target, "http://"):
+		return "ws://" + strings.TrimPrefix(target, "http://")
+	case strings.HasPrefix(target, "https://"):
+		return "wss://" + strings.TrimPrefix(target, "https://")
+	}
+	return target
+}
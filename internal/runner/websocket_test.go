@@ -0,0 +1,167 @@
+package runner_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"apitest/internal/config"
+	"apitest/internal/report"
+	"apitest/internal/runner"
+	"apitest/internal/wsx"
+)
+
+// newNotifyServer is a WebSocket stand-in: it acknowledges a subscribe message,
+// pushes a heartbeat and an event, then echoes the ack id back.
+func newNotifyServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := wsx.Upgrade(w, r, http.Header{"X-Server": {"notify"}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var sub map[string]string
+		if err := json.Unmarshal(data, &sub); err != nil || sub["op"] != "subscribe" {
+			return
+		}
+		_ = conn.WriteMessage(wsx.TextMessage, []byte(`{"type":"heartbeat"}`))
+		_ = conn.WriteMessage(wsx.TextMessage, []byte(`{"type":"event","topic":"`+sub["topic"]+`","id":42}`))
+		_, data, err = conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(wsx.TextMessage, []byte("ack:"+string(data)))
+		_, _, _ = conn.ReadMessage()
+	}))
+}
+
+func TestIntegrationWebSocketScript(t *testing.T) {
+	srv := newNotifyServer(t)
+	defer srv.Close()
+
+	planPath := filepath.Join(t.TempDir(), "plan.yaml")
+	planContent := `name: "ws"
+base_url: "` + srv.URL + `"
+vars:
+  topic: orders
+steps:
+  - name: "notify"
+    websocket:
+      url: /ws
+      headers:
+        Authorization: Bearer t1
+      timeout_ms: 2000
+      script:
+        - send:
+            op: subscribe
+            topic: "{{topic}}"
+        - expect:
+            skip_unmatched: true
+            assert:
+              - type: json
+                path: type
+                op: "=="
+                expect: event
+              - type: json
+                path: topic
+                op: "=="
+                expect: "{{topic}}"
+            extract:
+              event_id:
+                from: json
+                path: id
+        - send: "{{event_id}}"
+        - expect:
+            timeout_ms: 500
+            assert:
+              - type: body
+                op: contains
+                expect: ack:42
+        - close: true
+    assert:
+      - type: status
+        op: "=="
+        expect: 101
+      - type: header
+        name: X-Server
+        op: "=="
+        expect: notify
+  - name: "uses extracted"
+    websocket:
+      url: "/ws?id={{event_id}}"
+      script:
+        - expect:
+            timeout_ms: 100
+`
+	if err := os.WriteFile(planPath, []byte(planContent), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	plan, err := config.LoadPlan(planPath)
+	if err != nil {
+		t.Fatalf("load plan: %v", err)
+	}
+
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	first := res.Steps[0]
+	if !first.Success {
+		t.Fatalf("websocket step failed: %s", first.Error)
+	}
+	if first.Extracted["event_id"] != "42" || len(first.Assertions) != 5 {
+		t.Fatalf("extracted %v, assertions %d", first.Extracted, len(first.Assertions))
+	}
+	var dirs []string
+	for _, e := range first.Transcript {
+		dirs = append(dirs, e.Direction)
+	}
+	if strings.Join(dirs, ",") != "send,recv,recv,send,recv,close" {
+		t.Fatalf("unexpected transcript %v", dirs)
+	}
+
+	// the second step connects without the token and must fail the handshake
+	if res.Success || res.FailedStep != "uses extracted" {
+		t.Fatalf("expected second step to fail, got %+v", res)
+	}
+	second := res.Steps[1]
+	if second.Response.StatusCode != http.StatusUnauthorized || !strings.Contains(second.Request.URL, "id=42") {
+		t.Fatalf("second step: status %d url %s", second.Response.StatusCode, second.Request.URL)
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "report.md")
+	if err := report.GenerateMarkdown(res, reportPath); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	data, _ := os.ReadFile(reportPath)
+	if !strings.Contains(string(data), "### WebSocket Transcript") || !strings.Contains(string(data), "`ack:42`") {
+		t.Fatalf("transcript missing from report:\n%s", data)
+	}
+}
+
+func TestIntegrationWebSocketExpectTimeout(t *testing.T) {
+	srv := newNotifyServer(t)
+	defer srv.Close()
+
+	plan := &config.Plan{Name: "ws", BaseURL: srv.URL, Steps: []config.Step{{
+		Name: "silent",
+		WebSocket: &config.WebSocket{
+			URL:     "/ws",
+			Headers: config.Params{{Name: "Authorization", Values: []string{"Bearer t1"}}},
+			Script:  []config.WebSocketAction{{Expect: &config.WebSocketExpect{TimeoutMS: 50}}},
+		},
+	}}}
+	res := runner.Execute(plan, runner.RunnerOptions{})
+	if res.Success || !strings.Contains(res.Steps[0].Error, "no matching message within 50ms") {
+		t.Fatalf("expected timeout, got %q", res.Steps[0].Error)
+	}
+}
@@ -0,0 +1,657 @@
+// Package schema validates JSON documents against JSON Schema. It covers the
+// commonly used keywords of draft-07 and draft 2020-12 and reports every
+// violation with the JSON pointer of the offending value.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Violation is one failed keyword at an instance location.
+type Violation struct {
+	// Pointer is the JSON pointer of the value inside the validated document;
+	// empty for the document itself, which String shows as (root) because "/"
+	// would point at the key "".
+	Pointer string
+	Message string
+}
+
+func (v Violation) String() string {
+	ptr := v.Pointer
+	if ptr == "" {
+		ptr = "(root)"
+	}
+	return ptr + ": " + v.Message
+}
+
+// Schema is a loaded schema together with the documents its $refs point to.
+type Schema struct {
+	root scope
+	docs map[string]interface{}
+}
+
+// scope is a schema node and the file it came from, so relative $refs can be
+// resolved against that file.
+type scope struct {
+	node interface{}
+	file string // absolute path, empty for inline schemas
+	dir  string
+}
+
+// New wraps an inline schema; relative $refs resolve against dir.
+func New(doc interface{}, dir string) *Schema {
+	return &Schema{root: scope{node: doc, dir: dir}, docs: map[string]interface{}{}}
+}
+
+// Load reads a JSON or YAML schema file.
+func Load(path string) (*Schema, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{docs: map[string]interface{}{}}
+	doc, err := s.load(abs)
+	if err != nil {
+		return nil, err
+	}
+	s.root = scope{node: doc, file: abs, dir: filepath.Dir(abs)}
+	return s, nil
+}
+
+func (s *Schema) load(abs string) (interface{}, error) {
+	if doc, ok := s.docs[abs]; ok {
+		return doc, nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+	var doc interface{}
+	trimmed := bytes.TrimSpace(data)
+	if strings.EqualFold(filepath.Ext(abs), ".json") || bytes.HasPrefix(trimmed, []byte("{")) {
+		err = json.Unmarshal(trimmed, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse schema %s: %w", filepath.Base(abs), err)
+	}
+	s.docs[abs] = doc
+	return doc, nil
+}
+
+// At returns a schema for node, a part of the document s was loaded from, so
+// its $refs resolve against that document.
+func (s *Schema) At(node interface{}) *Schema {
+	return &Schema{root: scope{node: node, file: s.root.file, dir: s.root.dir}, docs: s.docs}
+}
+
+// Document returns the root node of the loaded schema document.
+func (s *Schema) Document() interface{} {
+	return s.root.node
+}
+
+// Resolve follows a $ref from the root of the document.
+func (s *Schema) Resolve(ref string) (interface{}, error) {
+	sc, err := s.resolve(s.root, ref)
+	if err != nil {
+		return nil, err
+	}
+	return sc.node, nil
+}
+
+// DecodeJSON decodes a document keeping numbers exact.
+func DecodeJSON(data string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Validate checks instance and returns every violation found.
+func (s *Schema) Validate(instance interface{}) []Violation {
+	v := &validator{schema: s}
+	v.validate(s.root, instance, "")
+	return v.out
+}
+
+type validator struct {
+	schema *Schema
+	out    []Violation
+	depth  int
+}
+
+func (v *validator) fail(ptr, format string, args ...interface{}) {
+	v.out = append(v.out, Violation{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+}
+
+// check runs sc against inst in isolation and reports whether it matched.
+func (v *validator) check(sc scope, inst interface{}, ptr string) []Violation {
+	sub := &validator{schema: v.schema, depth: v.depth}
+	sub.validate(sc, inst, ptr)
+	return sub.out
+}
+
+func (v *validator) validate(sc scope, inst interface{}, ptr string) {
+	switch node := sc.node.(type) {
+	case bool:
+		if !node {
+			v.fail(ptr, "no value is allowed here")
+		}
+		return
+	case map[string]interface{}:
+		v.validateObject(sc, node, inst, ptr)
+	case nil:
+		return
+	default:
+		v.fail(ptr, "invalid schema node %T", sc.node)
+	}
+}
+
+func (v *validator) validateObject(sc scope, node map[string]interface{}, inst interface{}, ptr string) {
+	if ref, ok := node["$ref"].(string); ok {
+		v.depth++
+		if v.depth > 64 {
+			v.fail(ptr, "$ref %s recurses too deeply", ref)
+		} else if target, err := v.schema.resolve(sc, ref); err != nil {
+			v.fail(ptr, "%v", err)
+		} else {
+			v.validate(target, inst, ptr)
+		}
+		v.depth--
+	}
+	sub := func(key string) scope { return scope{node: node[key], file: sc.file, dir: sc.dir} }
+
+	if t, ok := node["type"]; ok {
+		types := toStrings(t)
+		matched := false
+		for _, name := range types {
+			if isType(inst, name) {
+				matched = true
+				break
+			}
+		}
+		if nullable, _ := node["nullable"].(bool); nullable && inst == nil {
+			// OpenAPI 3.0 spelling of type: [..., "null"]
+			matched = true
+		}
+		if !matched {
+			v.fail(ptr, "expected %s, got %s", strings.Join(types, " or "), typeName(inst))
+		}
+	}
+	if enum, ok := node["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if Equal(e, inst) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(ptr, "value %s is not one of %s", compact(inst), compact(enum))
+		}
+	}
+	if c, ok := node["const"]; ok && !Equal(c, inst) {
+		v.fail(ptr, "value %s does not equal const %s", compact(inst), compact(c))
+	}
+
+	switch val := inst.(type) {
+	case string:
+		v.validateString(node, val, ptr)
+	case json.Number, float64, int, int64:
+		v.validateNumber(node, toNumber(val), ptr)
+	case map[string]interface{}:
+		v.validateProperties(sc, node, val, ptr)
+	case []interface{}:
+		v.validateItems(sc, node, val, ptr)
+	}
+
+	if list, ok := node["allOf"].([]interface{}); ok {
+		for i := range list {
+			v.validate(scope{node: list[i], file: sc.file, dir: sc.dir}, inst, ptr)
+		}
+	}
+	if list, ok := node["anyOf"].([]interface{}); ok {
+		matched := false
+		for i := range list {
+			if len(v.check(scope{node: list[i], file: sc.file, dir: sc.dir}, inst, ptr)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(ptr, "value matches none of the anyOf schemas")
+		}
+	}
+	if list, ok := node["oneOf"].([]interface{}); ok {
+		matches := 0
+		for i := range list {
+			if len(v.check(scope{node: list[i], file: sc.file, dir: sc.dir}, inst, ptr)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			v.fail(ptr, "value matches %d of the oneOf schemas, expected exactly 1", matches)
+		}
+	}
+	if _, ok := node["not"]; ok && len(v.check(sub("not"), inst, ptr)) == 0 {
+		v.fail(ptr, "value must not match the not schema")
+	}
+	if _, ok := node["if"]; ok {
+		if len(v.check(sub("if"), inst, ptr)) == 0 {
+			if _, ok := node["then"]; ok {
+				v.validate(sub("then"), inst, ptr)
+			}
+		} else if _, ok := node["else"]; ok {
+			v.validate(sub("else"), inst, ptr)
+		}
+	}
+}
+
+func (v *validator) validateString(node map[string]interface{}, s string, ptr string) {
+	length := utf8.RuneCountInString(s)
+	if n, ok := number(node["minLength"]); ok && float64(length) < n {
+		v.fail(ptr, "string length %d is shorter than %v", length, n)
+	}
+	if n, ok := number(node["maxLength"]); ok && float64(length) > n {
+		v.fail(ptr, "string length %d is longer than %v", length, n)
+	}
+	if pattern, ok := node["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(ptr, "invalid pattern %q: %v", pattern, err)
+		} else if !re.MatchString(s) {
+			v.fail(ptr, "%q does not match pattern %q", s, pattern)
+		}
+	}
+	if format, ok := node["format"].(string); ok && !CheckFormat(format, s) {
+		v.fail(ptr, "%q is not a valid %s", s, format)
+	}
+}
+
+func (v *validator) validateNumber(node map[string]interface{}, n float64, ptr string) {
+	if min, ok := number(node["minimum"]); ok && n < min {
+		v.fail(ptr, "%v is less than minimum %v", n, min)
+	}
+	if max, ok := number(node["maximum"]); ok && n > max {
+		v.fail(ptr, "%v is greater than maximum %v", n, max)
+	}
+	if min, ok := number(node["exclusiveMinimum"]); ok && n <= min {
+		v.fail(ptr, "%v is not greater than %v", n, min)
+	}
+	if max, ok := number(node["exclusiveMaximum"]); ok && n >= max {
+		v.fail(ptr, "%v is not less than %v", n, max)
+	}
+	if m, ok := number(node["multipleOf"]); ok && m > 0 {
+		q := n / m
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(ptr, "%v is not a multiple of %v", n, m)
+		}
+	}
+}
+
+func (v *validator) validateProperties(sc scope, node map[string]interface{}, obj map[string]interface{}, ptr string) {
+	if req, ok := node["required"].([]interface{}); ok {
+		for _, r := range req {
+			name := fmt.Sprint(r)
+			if _, ok := obj[name]; !ok {
+				v.fail(ptr, "missing required property %q", name)
+			}
+		}
+	}
+	if n, ok := number(node["minProperties"]); ok && float64(len(obj)) < n {
+		v.fail(ptr, "object has %d properties, fewer than %v", len(obj), n)
+	}
+	if n, ok := number(node["maxProperties"]); ok && float64(len(obj)) > n {
+		v.fail(ptr, "object has %d properties, more than %v", len(obj), n)
+	}
+
+	props, _ := node["properties"].(map[string]interface{})
+	patterns, _ := node["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := node["additionalProperties"]
+	names, hasNames := node["propertyNames"]
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child := ptr + "/" + escapePointer(key)
+		if hasNames {
+			for _, viol := range v.check(scope{node: names, file: sc.file, dir: sc.dir}, key, child) {
+				v.fail(child, "property name %s", viol.Message)
+			}
+		}
+		matched := false
+		if s, ok := props[key]; ok {
+			matched = true
+			v.validate(scope{node: s, file: sc.file, dir: sc.dir}, obj[key], child)
+		}
+		for pattern, s := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil || !re.MatchString(key) {
+				continue
+			}
+			matched = true
+			v.validate(scope{node: s, file: sc.file, dir: sc.dir}, obj[key], child)
+		}
+		if matched || !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok {
+			if !allowed {
+				v.fail(child, "additional property %q is not allowed", key)
+			}
+			continue
+		}
+		v.validate(scope{node: additional, file: sc.file, dir: sc.dir}, obj[key], child)
+	}
+}
+
+func (v *validator) validateItems(sc scope, node map[string]interface{}, arr []interface{}, ptr string) {
+	if n, ok := number(node["minItems"]); ok && float64(len(arr)) < n {
+		v.fail(ptr, "array has %d items, fewer than %v", len(arr), n)
+	}
+	if n, ok := number(node["maxItems"]); ok && float64(len(arr)) > n {
+		v.fail(ptr, "array has %d items, more than %v", len(arr), n)
+	}
+	if unique, _ := node["uniqueItems"].(bool); unique {
+		for i := 0; i < len(arr); i++ {
+			for j := i + 1; j < len(arr); j++ {
+				if Equal(arr[i], arr[j]) {
+					v.fail(ptr, "items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+
+	// tuple validation: prefixItems (2020-12) or an items array (draft-07)
+	prefix, _ := node["prefixItems"].([]interface{})
+	rest, hasRest := node["items"]
+	if tuple, ok := rest.([]interface{}); ok {
+		prefix = tuple
+		rest, hasRest = node["additionalItems"]
+	}
+	for i, item := range arr {
+		child := ptr + "/" + strconv.Itoa(i)
+		switch {
+		case i < len(prefix):
+			v.validate(scope{node: prefix[i], file: sc.file, dir: sc.dir}, item, child)
+		case hasRest:
+			if allowed, ok := rest.(bool); ok && !allowed {
+				v.fail(child, "additional item is not allowed")
+				continue
+			}
+			v.validate(scope{node: rest, file: sc.file, dir: sc.dir}, item, child)
+		}
+	}
+
+	if contains, ok := node["contains"]; ok {
+		count := 0
+		for i, item := range arr {
+			if len(v.check(scope{node: contains, file: sc.file, dir: sc.dir}, item, ptr+"/"+strconv.Itoa(i))) == 0 {
+				count++
+			}
+		}
+		min, hasMin := number(node["minContains"])
+		if !hasMin {
+			min = 1
+		}
+		if float64(count) < min {
+			v.fail(ptr, "array contains %d matching items, expected at least %v", count, min)
+		}
+		if max, ok := number(node["maxContains"]); ok && float64(count) > max {
+			v.fail(ptr, "array contains %d matching items, expected at most %v", count, max)
+		}
+	}
+}
+
+// resolve follows a $ref relative to the scope it appears in. Only local
+// files and JSON pointer fragments are supported.
+func (s *Schema) resolve(sc scope, ref string) (scope, error) {
+	file, fragment, _ := strings.Cut(ref, "#")
+	target := scope{node: s.root.node, file: s.root.file, dir: s.root.dir}
+	if sc.file != "" {
+		target = scope{node: s.docs[sc.file], file: sc.file, dir: sc.dir}
+	}
+	if file != "" {
+		if u, err := url.Parse(file); err == nil && u.Scheme != "" && u.Scheme != "file" {
+			return scope{}, fmt.Errorf("cannot resolve $ref %s: only local files are supported", ref)
+		}
+		path := strings.TrimPrefix(file, "file://")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(sc.dir, path)
+		}
+		doc, err := s.load(path)
+		if err != nil {
+			return scope{}, fmt.Errorf("cannot resolve $ref %s: %v", ref, err)
+		}
+		target = scope{node: doc, file: path, dir: filepath.Dir(path)}
+	}
+	if fragment == "" {
+		return target, nil
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		return scope{}, fmt.Errorf("cannot resolve $ref %s: only JSON pointer fragments are supported", ref)
+	}
+	node := target.node
+	for _, token := range strings.Split(fragment[1:], "/") {
+		if unescaped, err := url.PathUnescape(token); err == nil {
+			token = unescaped
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]interface{}:
+			next, ok := n[token]
+			if !ok {
+				return scope{}, fmt.Errorf("cannot resolve $ref %s: %q not found", ref, token)
+			}
+			node = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return scope{}, fmt.Errorf("cannot resolve $ref %s: index %q out of range", ref, token)
+			}
+			node = n[i]
+		default:
+			return scope{}, fmt.Errorf("cannot resolve $ref %s", ref)
+		}
+	}
+	target.node = node
+	return target, nil
+}
+
+var (
+	emailRe    = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidRe     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	phoneRe    = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+	hostnameRe = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+)
+
+// CheckFormat validates the well-known string formats; unknown formats pass.
+func CheckFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "time":
+		if _, err := time.Parse("15:04:05Z07:00", s); err == nil {
+			return true
+		}
+		_, err := time.Parse("15:04:05.999999999Z07:00", s)
+		return err == nil
+	case "email":
+		return emailRe.MatchString(s)
+	case "uuid":
+		return uuidRe.MatchString(s)
+	case "phone":
+		// E.164 length, spaces and dashes allowed: +86 138-0013-8000
+		return phoneRe.MatchString(strings.NewReplacer(" ", "", "-", "").Replace(s))
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && strings.Contains(s, ".")
+	case "ipv6":
+		return net.ParseIP(s) != nil && strings.Contains(s, ":")
+	case "hostname":
+		return len(s) <= 253 && hostnameRe.MatchString(s)
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	case "uri-reference":
+		_, err := url.Parse(s)
+		return err == nil
+	case "regex":
+		_, err := regexp.Compile(s)
+		return err == nil
+	default:
+		return true
+	}
+}
+
+func isType(inst interface{}, name string) bool {
+	switch name {
+	case "null":
+		return inst == nil
+	case "boolean":
+		_, ok := inst.(bool)
+		return ok
+	case "string":
+		_, ok := inst.(string)
+		return ok
+	case "object":
+		_, ok := inst.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := inst.([]interface{})
+		return ok
+	case "number":
+		_, ok := number(inst)
+		return ok
+	case "integer":
+		n, ok := number(inst)
+		return ok && n == math.Trunc(n)
+	}
+	return false
+}
+
+func typeName(inst interface{}) string {
+	switch inst.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if isType(inst, "integer") {
+		return "integer"
+	}
+	return "number"
+}
+
+// Equal compares decoded JSON values structurally, numbers by value.
+func Equal(a, b interface{}) bool {
+	if an, ok := number(a); ok {
+		bn, ok := number(b)
+		return ok && an == bn
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			w, ok := bv[k]
+			if !ok || !Equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !Equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toNumber(v interface{}) float64 {
+	n, _ := number(v)
+	return n
+}
+
+func toStrings(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, s := range t {
+			out = append(out, fmt.Sprint(s))
+		}
+		return out
+	}
+	return nil
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func compact(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
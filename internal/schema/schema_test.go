@@ -0,0 +1,143 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustDecode(t *testing.T, data string) interface{} {
+	t.Helper()
+	v, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return v
+}
+
+func TestValidateCollectsEveryViolation(t *testing.T) {
+	s := New(mustDecode(t, `{
+		"type": "object",
+		"required": ["id", "email", "role"],
+		"additionalProperties": false,
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"email": {"type": "string", "format": "email"},
+			"code": {"type": "string", "pattern": "^[A-Z]{3}$"},
+			"role": {"enum": ["admin", "user"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true}
+		}
+	}`), "")
+	got := s.Validate(mustDecode(t, `{"id": 1.5, "email": "nope", "code": "ab", "tags": ["a", 2, "a"], "extra/x": true, "": 0}`))
+	want := []string{
+		`(root): missing required property "role"`,
+		`/: additional property "" is not allowed`,
+		`/code: "ab" does not match pattern "^[A-Z]{3}$"`,
+		`/email: "nope" is not a valid email`,
+		`/extra~1x: additional property "extra/x" is not allowed`,
+		`/id: expected integer, got number`,
+		`/tags: items 0 and 2 are equal`,
+		`/tags/1: expected string, got integer`,
+	}
+	var lines []string
+	for _, v := range got {
+		lines = append(lines, v.String())
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected violations:\n%s", strings.Join(lines, "\n"))
+	}
+
+	if v := s.Validate(mustDecode(t, `{"id": 3, "email": "a@b.io", "role": "user", "tags": ["x"]}`)); len(v) != 0 {
+		t.Fatalf("expected valid document, got %v", v)
+	}
+}
+
+func TestValidateRefsAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("user.yaml", `type: object
+required: [id, address]
+properties:
+  id:
+    type: integer
+  address:
+    $ref: "common/defs.json#/$defs/address"
+`)
+	write("common/defs.json", `{"$defs": {
+		"address": {"type": "object", "required": ["city"], "properties": {"zip": {"$ref": "#/$defs/zip"}}},
+		"zip": {"type": "string", "pattern": "^[0-9]{6}$"}
+	}}`)
+
+	s, err := Load(filepath.Join(dir, "user.yaml"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	got := s.Validate(mustDecode(t, `{"id": 7, "address": {"zip": "12"}}`))
+	if len(got) != 2 || got[0].Pointer != "/address" || got[1].Pointer != "/address/zip" {
+		t.Fatalf("unexpected violations: %v", got)
+	}
+
+	inline := New(map[string]interface{}{"$ref": "user.yaml"}, dir)
+	if got := inline.Validate(mustDecode(t, `{"id": 7, "address": {"city": "x", "zip": "100000"}}`)); len(got) != 0 {
+		t.Fatalf("expected inline ref to resolve against dir: %v", got)
+	}
+	missing := New(map[string]interface{}{"$ref": "nope.json"}, dir)
+	if got := missing.Validate(mustDecode(t, `{}`)); len(got) != 1 || !strings.Contains(got[0].Message, "cannot resolve $ref") {
+		t.Fatalf("expected unresolved ref violation: %v", got)
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	s := New(mustDecode(t, `{
+		"$defs": {"pos": {"type": "number", "exclusiveMinimum": 0}},
+		"type": "array",
+		"prefixItems": [{"type": "string"}],
+		"items": {"anyOf": [{"$ref": "#/$defs/pos"}, {"type": "null"}]},
+		"contains": {"type": "null"},
+		"maxItems": 4
+	}`), "")
+	if got := s.Validate(mustDecode(t, `["a", 1, null]`)); len(got) != 0 {
+		t.Fatalf("expected valid: %v", got)
+	}
+	got := s.Validate(mustDecode(t, `[1, -2, 3, 4, 5]`))
+	if len(got) != 4 {
+		t.Fatalf("unexpected violations: %v", got)
+	}
+
+	oneOf := New(mustDecode(t, `{"oneOf": [{"type": "integer"}, {"type": "number"}]}`), "")
+	if got := oneOf.Validate(mustDecode(t, `3`)); len(got) != 1 {
+		t.Fatalf("integer matches both oneOf branches: %v", got)
+	}
+	cond := New(mustDecode(t, `{"if": {"properties": {"kind": {"const": "card"}}}, "then": {"required": ["last4"]}, "else": false}`), "")
+	if got := cond.Validate(mustDecode(t, `{"kind": "card"}`)); len(got) != 1 || !strings.Contains(got[0].Message, "last4") {
+		t.Fatalf("unexpected if/then result: %v", got)
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	valid := map[string]string{
+		"date-time": "2024-05-01T12:30:00+08:00",
+		"date":      "2024-05-01",
+		"uuid":      "3f0c8a52-6a1f-4c1e-9a53-2b5a3c1d9e10",
+		"ipv4":      "10.0.0.1",
+		"ipv6":      "::1",
+		"uri":       "https://example.com/a",
+		"hostname":  "api.example.com",
+	}
+	for format, s := range valid {
+		if !CheckFormat(format, s) {
+			t.Fatalf("%s should accept %q", format, s)
+		}
+	}
+	if CheckFormat("date-time", "2024-05-01 12:30") || CheckFormat("ipv4", "::1") || CheckFormat("uuid", "123") {
+		t.Fatalf("expected invalid formats to fail")
+	}
+}
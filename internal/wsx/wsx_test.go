@@ -0,0 +1,93 @@
+package wsx
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDialEchoAndControlFrames(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, http.Header{"Sec-WebSocket-Protocol": {"chat"}})
+		if err != nil {
+			return
+		}
+		defer conn.conn.Close()
+		// fragmented text message with a ping in between
+		_ = conn.writeFrameRaw(false, TextMessage, []byte("hel"))
+		_ = conn.writeFrameRaw(true, PingMessage, []byte("p"))
+		_ = conn.writeFrameRaw(true, 0, []byte("lo"))
+		for {
+			op, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(op, data)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	d := &Dialer{Subprotocols: []string{"chat"}}
+	conn, resp, err := d.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/chat", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Protocol") != "chat" {
+		t.Fatalf("handshake response %d %v", resp.StatusCode, resp.Header)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	op, data, err := conn.ReadMessage()
+	if err != nil || op != TextMessage || string(data) != "hello" {
+		t.Fatalf("fragmented message: %d %q %v", op, data, err)
+	}
+
+	big := bytes.Repeat([]byte("x"), 70000)
+	if err := conn.WriteMessage(BinaryMessage, big); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	op, data, err = conn.ReadMessage()
+	if err != nil || op != BinaryMessage || !bytes.Equal(data, big) {
+		t.Fatalf("large echo: %d %d %v", op, len(data), err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestReadMessageReportsClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(CloseMessage, append(binary.BigEndian.AppendUint16(nil, 4001), "bye"...))
+		_, _, _ = conn.ReadMessage()
+		conn.conn.Close()
+	}))
+	defer srv.Close()
+
+	conn, _, err := (&Dialer{}).Dial(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_, _, err = conn.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != 4001 || ce.Text != "bye" {
+		t.Fatalf("expected close error, got %v", err)
+	}
+
+	rejected := httptest.NewServer(http.NotFoundHandler())
+	defer rejected.Close()
+	if _, resp, err := (&Dialer{}).Dial(context.Background(), rejected.URL, nil); err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected rejected handshake, got %v", err)
+	}
+}
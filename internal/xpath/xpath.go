@@ -0,0 +1,1141 @@
+// Package xpath implements an XPath 1.0 subset over a small XML tree, for XML
+// assertions and extraction. Unprefixed names match elements by local name in
+// any namespace; prefixed names are resolved through the namespaces map.
+// Whitespace-only text nodes are dropped while parsing.
+package xpath
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NodeType distinguishes the kinds of nodes in a parsed document.
+type NodeType int
+
+const (
+	RootNode NodeType = iota
+	ElementNode
+	AttributeNode
+	TextNode
+	CommentNode
+)
+
+// Node is a single node of the parsed document.
+type Node struct {
+	Type     NodeType
+	Name     xml.Name
+	Prefix   string
+	Data     string
+	Attr     []*Node
+	Children []*Node
+	Parent   *Node
+	order    int
+}
+
+// Parse reads an XML document into a node tree.
+func Parse(data string) (*Node, error) {
+	dec := xml.NewDecoder(strings.NewReader(data))
+	dec.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+		// bodies are already decoded to UTF-8 before assertions run
+		return r, nil
+	}
+	root := &Node{Type: RootNode}
+	cur := root
+	order := 0
+	next := func() int {
+		order++
+		return order
+	}
+	prefixes := []map[string]string{{}}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			scope := map[string]string{}
+			for k, v := range prefixes[len(prefixes)-1] {
+				scope[k] = v
+			}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" {
+					scope[a.Value] = a.Name.Local
+				}
+			}
+			prefixes = append(prefixes, scope)
+			el := &Node{Type: ElementNode, Name: t.Name, Prefix: scope[t.Name.Space], Parent: cur, order: next()}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					continue
+				}
+				el.Attr = append(el.Attr, &Node{Type: AttributeNode, Name: a.Name, Prefix: scope[a.Name.Space], Data: a.Value, Parent: el, order: next()})
+			}
+			cur.Children = append(cur.Children, el)
+			cur = el
+		case xml.EndElement:
+			if cur.Parent == nil {
+				return nil, errors.New("unbalanced end element")
+			}
+			cur = cur.Parent
+			prefixes = prefixes[:len(prefixes)-1]
+		case xml.CharData:
+			text := string(t)
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			if n := len(cur.Children); n > 0 && cur.Children[n-1].Type == TextNode {
+				cur.Children[n-1].Data += text
+				continue
+			}
+			cur.Children = append(cur.Children, &Node{Type: TextNode, Data: text, Parent: cur, order: next()})
+		case xml.Comment:
+			cur.Children = append(cur.Children, &Node{Type: CommentNode, Data: string(t), Parent: cur, order: next()})
+		}
+	}
+	if cur != root {
+		return nil, errors.New("unexpected end of document")
+	}
+	return root, nil
+}
+
+// Valid reports whether data parses as XML with at least one element.
+func Valid(data string) bool {
+	root, err := Parse(data)
+	if err != nil {
+		return false
+	}
+	for _, c := range root.Children {
+		if c.Type == ElementNode {
+			return true
+		}
+	}
+	return false
+}
+
+// StringValue returns the XPath string-value of the node.
+func (n *Node) StringValue() string {
+	switch n.Type {
+	case AttributeNode, TextNode, CommentNode:
+		return n.Data
+	}
+	var sb strings.Builder
+	var walk func(*Node)
+	walk = func(x *Node) {
+		for _, c := range x.Children {
+			switch c.Type {
+			case TextNode:
+				sb.WriteString(c.Data)
+			case ElementNode:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// QName returns the node name with its document prefix.
+func (n *Node) QName() string {
+	if n.Prefix != "" {
+		return n.Prefix + ":" + n.Name.Local
+	}
+	return n.Name.Local
+}
+
+// Result is the value of an evaluated expression: a node-set, string, number or boolean.
+type Result struct {
+	value interface{}
+}
+
+// Nodes returns the selected nodes, or nil for scalar results.
+func (r Result) Nodes() []*Node {
+	nodes, _ := r.value.([]*Node)
+	return nodes
+}
+
+// Exists is true for non-empty node-sets and for any scalar result.
+func (r Result) Exists() bool {
+	if nodes, ok := r.value.([]*Node); ok {
+		return len(nodes) > 0
+	}
+	return r.value != nil
+}
+
+// String converts the result with the XPath string() rules.
+func (r Result) String() string {
+	return toString(r.value)
+}
+
+// Number converts the result with the XPath number() rules.
+func (r Result) Number() float64 {
+	return toNumber(r.value)
+}
+
+// IsNumber reports whether the expression itself produced a number (e.g. count()).
+func (r Result) IsNumber() bool {
+	_, ok := r.value.(float64)
+	return ok
+}
+
+// Eval evaluates expr against the document root.
+func Eval(root *Node, expr string, namespaces map[string]string) (Result, error) {
+	e, err := compile(expr)
+	if err != nil {
+		return Result{}, fmt.Errorf("xpath %q: %w", expr, err)
+	}
+	ev := &evaluator{ns: namespaces}
+	v, err := ev.eval(e, evalCtx{node: root, pos: 1, size: 1})
+	if err != nil {
+		return Result{}, fmt.Errorf("xpath %q: %w", expr, err)
+	}
+	return Result{value: v}, nil
+}
+
+// Query parses data and evaluates expr in one call.
+func Query(data, expr string, namespaces map[string]string) (Result, error) {
+	root, err := Parse(data)
+	if err != nil {
+		return Result{}, fmt.Errorf("parse xml: %w", err)
+	}
+	return Eval(root, expr, namespaces)
+}
+
+// ---- lexer ----
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokName
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+}
+
+func lex(s string) ([]token, error) {
+	var out []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, errors.New("unterminated string literal")
+			}
+			out = append(out, token{tokString, s[i+1 : i+1+end]})
+			i += end + 2
+		case (c >= '0' && c <= '9') || (c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+			j := i
+			for j < len(s) && ((s[j] >= '0' && s[j] <= '9') || s[j] == '.') {
+				j++
+			}
+			out = append(out, token{tokNumber, s[i:j]})
+			i = j
+		case isNameStart(c):
+			j := i
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			// prefix:local or prefix:*, but not the axis separator "::"
+			if j+1 < len(s) && s[j] == ':' && s[j+1] != ':' {
+				if s[j+1] == '*' {
+					j += 2
+				} else if isNameStart(s[j+1]) {
+					j++
+					for j < len(s) && isNameChar(s[j]) {
+						j++
+					}
+				}
+			}
+			out = append(out, token{tokName, s[i:j]})
+			i = j
+		default:
+			two := ""
+			if i+1 < len(s) {
+				two = s[i : i+2]
+			}
+			switch two {
+			case "//", "::", "..", "!=", "<=", ">=":
+				out = append(out, token{tokOp, two})
+				i += 2
+				continue
+			}
+			if strings.IndexByte("/()[]@,|=<>+-*.$", c) < 0 {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			out = append(out, token{tokOp, string(c)})
+			i++
+		}
+	}
+	return out, nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || c == '-' || c == '.' || (c >= '0' && c <= '9')
+}
+
+// ---- parser ----
+
+type expr interface{}
+
+type binaryExpr struct {
+	op   string
+	l, r expr
+}
+
+type negExpr struct{ e expr }
+
+type literalExpr struct{ v interface{} }
+
+type funcExpr struct {
+	name string
+	args []expr
+}
+
+type pathExpr struct {
+	filter   expr // optional primary expression the steps start from
+	absolute bool
+	steps    []step
+}
+
+type step struct {
+	axis  string
+	test  nodeTest
+	preds []expr
+}
+
+type nodeTest struct {
+	kind   string // name, node, text, comment
+	prefix string
+	local  string // "*" for any
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func compile(s string) (expr, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return token{kind: tokEOF}
+}
+
+func (p *parser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == text
+}
+
+func (p *parser) isKeyword(text string) bool {
+	t := p.peek()
+	return t.kind == tokName && t.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if !p.isOp(text) {
+		return fmt.Errorf("expected %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	return p.parseBinary([]string{"or"}, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	return p.parseBinary([]string{"and"}, p.parseEquality)
+}
+
+func (p *parser) parseEquality() (expr, error) {
+	return p.parseBinary([]string{"=", "!="}, p.parseRelational)
+}
+
+func (p *parser) parseRelational() (expr, error) {
+	return p.parseBinary([]string{"<", "<=", ">", ">="}, p.parseAdditive)
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	return p.parseBinary([]string{"+", "-"}, p.parseMultiplicative)
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	return p.parseBinary([]string{"*", "div", "mod"}, p.parseUnary)
+}
+
+func (p *parser) parseBinary(ops []string, next func() (expr, error)) (expr, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		matched := ""
+		for _, op := range ops {
+			if p.isOp(op) || p.isKeyword(op) {
+				matched = op
+				break
+			}
+		}
+		if matched == "" {
+			return left, nil
+		}
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: matched, l: left, r: right}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.isOp("-") {
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{e}, nil
+	}
+	return p.parseUnion()
+}
+
+func (p *parser) parseUnion() (expr, error) {
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("|") {
+		p.pos++
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "|", l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parsePath() (expr, error) {
+	t := p.peek()
+	isPrimary := t.kind == tokString || t.kind == tokNumber || (t.kind == tokOp && t.text == "(") ||
+		(t.kind == tokName && p.pos+1 < len(p.toks) && p.toks[p.pos+1].text == "(" && !isNodeTypeName(t.text))
+	if isPrimary {
+		prim, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		var preds []expr
+		for p.isOp("[") {
+			pred, err := p.parsePredicate()
+			if err != nil {
+				return nil, err
+			}
+			preds = append(preds, pred)
+		}
+		if len(preds) > 0 {
+			prim = pathExpr{filter: prim, steps: []step{{axis: "self", test: nodeTest{kind: "node"}, preds: preds}}}
+		}
+		if !p.isOp("/") && !p.isOp("//") {
+			return prim, nil
+		}
+		path := pathExpr{filter: prim}
+		if err := p.parseSteps(&path); err != nil {
+			return nil, err
+		}
+		return path, nil
+	}
+	path := pathExpr{}
+	if p.isOp("/") || p.isOp("//") {
+		path.absolute = true
+		if p.isOp("/") {
+			p.pos++
+			// a lone "/" selects the root
+			if !p.startsStep() {
+				return path, nil
+			}
+			if err := p.parseStepInto(&path); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := p.parseStepInto(&path); err != nil {
+		return nil, err
+	}
+	if err := p.parseSteps(&path); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func isNodeTypeName(s string) bool {
+	return s == "node" || s == "text" || s == "comment"
+}
+
+func (p *parser) startsStep() bool {
+	t := p.peek()
+	return t.kind == tokName || (t.kind == tokOp && (t.text == "@" || t.text == "*" || t.text == "." || t.text == ".."))
+}
+
+func (p *parser) parseSteps(path *pathExpr) error {
+	for p.isOp("/") || p.isOp("//") {
+		if p.isOp("//") {
+			path.steps = append(path.steps, step{axis: "descendant-or-self", test: nodeTest{kind: "node"}})
+		}
+		p.pos++
+		if err := p.parseStepInto(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseStepInto(path *pathExpr) error {
+	if path.absolute && len(path.steps) == 0 && p.pos > 0 && p.toks[p.pos-1].text == "//" {
+		path.steps = append(path.steps, step{axis: "descendant-or-self", test: nodeTest{kind: "node"}})
+	}
+	if p.isOp("//") && len(path.steps) == 0 && path.absolute {
+		p.pos++
+		path.steps = append(path.steps, step{axis: "descendant-or-self", test: nodeTest{kind: "node"}})
+	}
+	s, err := p.parseStep()
+	if err != nil {
+		return err
+	}
+	path.steps = append(path.steps, s)
+	return nil
+}
+
+func (p *parser) parseStep() (step, error) {
+	if p.isOp(".") {
+		p.pos++
+		return step{axis: "self", test: nodeTest{kind: "node"}}, nil
+	}
+	if p.isOp("..") {
+		p.pos++
+		return step{axis: "parent", test: nodeTest{kind: "node"}}, nil
+	}
+	s := step{axis: "child"}
+	if p.isOp("@") {
+		p.pos++
+		s.axis = "attribute"
+	} else if t := p.peek(); t.kind == tokName && p.pos+1 < len(p.toks) && p.toks[p.pos+1].text == "::" {
+		s.axis = t.text
+		p.pos += 2
+		switch s.axis {
+		case "child", "descendant", "descendant-or-self", "parent", "ancestor", "ancestor-or-self",
+			"following-sibling", "preceding-sibling", "self", "attribute":
+		default:
+			return s, fmt.Errorf("unsupported axis %s", s.axis)
+		}
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tokOp && t.text == "*":
+		p.pos++
+		s.test = nodeTest{kind: "name", local: "*"}
+	case t.kind == tokName:
+		p.pos++
+		if isNodeTypeName(t.text) && p.isOp("(") {
+			p.pos++
+			if err := p.expect(")"); err != nil {
+				return s, err
+			}
+			s.test = nodeTest{kind: t.text}
+			break
+		}
+		prefix, local := "", t.text
+		if i := strings.IndexByte(t.text, ':'); i >= 0 {
+			prefix, local = t.text[:i], t.text[i+1:]
+		}
+		s.test = nodeTest{kind: "name", prefix: prefix, local: local}
+	default:
+		return s, fmt.Errorf("expected node test, got %q", t.text)
+	}
+	for p.isOp("[") {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return s, err
+		}
+		s.preds = append(s.preds, pred)
+	}
+	return s, nil
+}
+
+func (p *parser) parsePredicate() (expr, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return e, p.expect("]")
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokString:
+		p.pos++
+		return literalExpr{t.text}, nil
+	case tokNumber:
+		p.pos++
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, err
+		}
+		return literalExpr{f}, nil
+	case tokOp:
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	p.pos += 2
+	fn := funcExpr{name: t.text}
+	for !p.isOp(")") {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		fn.args = append(fn.args, arg)
+		if p.isOp(",") {
+			p.pos++
+			continue
+		}
+		if !p.isOp(")") {
+			return nil, fmt.Errorf("expected ) after arguments of %s", fn.name)
+		}
+	}
+	p.pos++
+	return fn, nil
+}
+
+// ---- evaluator ----
+
+type evalCtx struct {
+	node *Node
+	pos  int
+	size int
+}
+
+type evaluator struct {
+	ns map[string]string
+}
+
+func (ev *evaluator) eval(e expr, ctx evalCtx) (interface{}, error) {
+	switch x := e.(type) {
+	case literalExpr:
+		return x.v, nil
+	case negExpr:
+		v, err := ev.eval(x.e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return -toNumber(v), nil
+	case binaryExpr:
+		return ev.evalBinary(x, ctx)
+	case funcExpr:
+		return ev.call(x, ctx)
+	case pathExpr:
+		return ev.evalPath(x, ctx)
+	default:
+		return nil, fmt.Errorf("unknown expression %T", e)
+	}
+}
+
+func (ev *evaluator) evalBinary(x binaryExpr, ctx evalCtx) (interface{}, error) {
+	l, err := ev.eval(x.l, ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "or":
+		if toBool(l) {
+			return true, nil
+		}
+	case "and":
+		if !toBool(l) {
+			return false, nil
+		}
+	}
+	r, err := ev.eval(x.r, ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "or", "and":
+		return toBool(r), nil
+	case "|":
+		ln, lok := l.([]*Node)
+		rn, rok := r.([]*Node)
+		if !lok || !rok {
+			return nil, errors.New("union of non node-sets")
+		}
+		return docOrder(append(append([]*Node{}, ln...), rn...)), nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compareValues(x.op, l, r), nil
+	case "+":
+		return toNumber(l) + toNumber(r), nil
+	case "-":
+		return toNumber(l) - toNumber(r), nil
+	case "*":
+		return toNumber(l) * toNumber(r), nil
+	case "div":
+		return toNumber(l) / toNumber(r), nil
+	case "mod":
+		return math.Mod(toNumber(l), toNumber(r)), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", x.op)
+}
+
+func (ev *evaluator) evalPath(x pathExpr, ctx evalCtx) (interface{}, error) {
+	var nodes []*Node
+	switch {
+	case x.filter != nil:
+		v, err := ev.eval(x.filter, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(x.steps) == 0 {
+			return v, nil
+		}
+		ns, ok := v.([]*Node)
+		if !ok {
+			return nil, errors.New("path applied to a non node-set")
+		}
+		nodes = ns
+	case x.absolute:
+		root := ctx.node
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		nodes = []*Node{root}
+	default:
+		nodes = []*Node{ctx.node}
+	}
+	for _, s := range x.steps {
+		var next []*Node
+		for _, n := range nodes {
+			candidates := axisNodes(s.axis, n)
+			var matched []*Node
+			for _, c := range candidates {
+				if ev.matches(s.test, s.axis, c) {
+					matched = append(matched, c)
+				}
+			}
+			for _, pred := range s.preds {
+				filtered, err := ev.filter(matched, pred)
+				if err != nil {
+					return nil, err
+				}
+				matched = filtered
+			}
+			next = append(next, matched...)
+		}
+		nodes = docOrder(next)
+	}
+	return nodes, nil
+}
+
+func (ev *evaluator) filter(nodes []*Node, pred expr) ([]*Node, error) {
+	var out []*Node
+	for i, n := range nodes {
+		v, err := ev.eval(pred, evalCtx{node: n, pos: i + 1, size: len(nodes)})
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := v.(float64); ok {
+			if int(f) == i+1 && f == math.Trunc(f) {
+				out = append(out, n)
+			}
+			continue
+		}
+		if toBool(v) {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+func (ev *evaluator) matches(t nodeTest, axis string, n *Node) bool {
+	switch t.kind {
+	case "node":
+		return true
+	case "text":
+		return n.Type == TextNode
+	case "comment":
+		return n.Type == CommentNode
+	}
+	principal := ElementNode
+	if axis == "attribute" {
+		principal = AttributeNode
+	}
+	if n.Type != principal {
+		return false
+	}
+	if t.prefix != "" {
+		uri, ok := ev.ns[t.prefix]
+		if !ok {
+			// fall back to the prefix used in the document itself
+			if n.Prefix != t.prefix {
+				return false
+			}
+		} else if n.Name.Space != uri {
+			return false
+		}
+	}
+	return t.local == "*" || t.local == n.Name.Local
+}
+
+// axisNodes returns the nodes along axis in proximity order: document order for
+// forward axes and reverse document order for ancestor, ancestor-or-self and
+// preceding-sibling, so predicate positions count outward from n. Each step's
+// result is put back into document order by docOrder.
+func axisNodes(axis string, n *Node) []*Node {
+	switch axis {
+	case "child":
+		return n.Children
+	case "attribute":
+		return n.Attr
+	case "self":
+		return []*Node{n}
+	case "parent":
+		if n.Parent != nil {
+			return []*Node{n.Parent}
+		}
+		return nil
+	case "ancestor", "ancestor-or-self":
+		var out []*Node
+		if axis == "ancestor-or-self" {
+			out = append(out, n)
+		}
+		for p := n.Parent; p != nil; p = p.Parent {
+			out = append(out, p)
+		}
+		return out
+	case "descendant", "descendant-or-self":
+		var out []*Node
+		if axis == "descendant-or-self" {
+			out = append(out, n)
+		}
+		var walk func(*Node)
+		walk = func(x *Node) {
+			for _, c := range x.Children {
+				out = append(out, c)
+				walk(c)
+			}
+		}
+		walk(n)
+		return out
+	case "following-sibling", "preceding-sibling":
+		if n.Parent == nil || n.Type == AttributeNode {
+			return nil
+		}
+		sibs := n.Parent.Children
+		for i, s := range sibs {
+			if s == n {
+				if axis == "following-sibling" {
+					return sibs[i+1:]
+				}
+				out := make([]*Node, 0, i)
+				for j := i - 1; j >= 0; j-- {
+					out = append(out, sibs[j])
+				}
+				return out
+			}
+		}
+	}
+	return nil
+}
+
+func docOrder(nodes []*Node) []*Node {
+	seen := make(map[*Node]bool, len(nodes))
+	out := nodes[:0:0]
+	for _, n := range nodes {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].order < out[j].order })
+	return out
+}
+
+func (ev *evaluator) call(f funcExpr, ctx evalCtx) (interface{}, error) {
+	args := make([]interface{}, len(f.args))
+	for i, a := range f.args {
+		v, err := ev.eval(a, ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	argOrCtx := func() interface{} {
+		if len(args) > 0 {
+			return args[0]
+		}
+		return []*Node{ctx.node}
+	}
+	firstNode := func() *Node {
+		if nodes, ok := argOrCtx().([]*Node); ok && len(nodes) > 0 {
+			return nodes[0]
+		}
+		return nil
+	}
+	str := func(i int) string {
+		if i < len(args) {
+			return toString(args[i])
+		}
+		return ""
+	}
+	switch f.name {
+	case "last":
+		return float64(ctx.size), nil
+	case "position":
+		return float64(ctx.pos), nil
+	case "count":
+		nodes, ok := argOrCtx().([]*Node)
+		if !ok {
+			return nil, errors.New("count() expects a node-set")
+		}
+		return float64(len(nodes)), nil
+	case "name", "local-name", "namespace-uri":
+		n := firstNode()
+		if n == nil {
+			return "", nil
+		}
+		switch f.name {
+		case "name":
+			return n.QName(), nil
+		case "local-name":
+			return n.Name.Local, nil
+		default:
+			return n.Name.Space, nil
+		}
+	case "string":
+		return toString(argOrCtx()), nil
+	case "concat":
+		var sb strings.Builder
+		for i := range args {
+			sb.WriteString(str(i))
+		}
+		return sb.String(), nil
+	case "contains":
+		return strings.Contains(str(0), str(1)), nil
+	case "starts-with":
+		return strings.HasPrefix(str(0), str(1)), nil
+	case "ends-with":
+		return strings.HasSuffix(str(0), str(1)), nil
+	case "substring-before":
+		before, _, _ := strings.Cut(str(0), str(1))
+		if !strings.Contains(str(0), str(1)) {
+			return "", nil
+		}
+		return before, nil
+	case "substring-after":
+		_, after, _ := strings.Cut(str(0), str(1))
+		return after, nil
+	case "substring":
+		runes := []rune(str(0))
+		start := int(math.Round(toNumber(args[1]))) - 1
+		end := len(runes)
+		if len(args) > 2 {
+			end = start + int(math.Round(toNumber(args[2])))
+		}
+		if start < 0 {
+			start = 0
+		}
+		if end > len(runes) {
+			end = len(runes)
+		}
+		if start >= end {
+			return "", nil
+		}
+		return string(runes[start:end]), nil
+	case "string-length":
+		return float64(len([]rune(toString(argOrCtx())))), nil
+	case "normalize-space":
+		return strings.Join(strings.Fields(toString(argOrCtx())), " "), nil
+	case "not":
+		return !toBool(args[0]), nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "boolean":
+		return toBool(args[0]), nil
+	case "number":
+		return toNumber(argOrCtx()), nil
+	case "sum":
+		nodes, ok := argOrCtx().([]*Node)
+		if !ok {
+			return nil, errors.New("sum() expects a node-set")
+		}
+		total := 0.0
+		for _, n := range nodes {
+			total += toNumber(n.StringValue())
+		}
+		return total, nil
+	case "floor":
+		return math.Floor(toNumber(args[0])), nil
+	case "ceiling":
+		return math.Ceil(toNumber(args[0])), nil
+	case "round":
+		return math.Floor(toNumber(args[0]) + 0.5), nil
+	default:
+		return nil, fmt.Errorf("unsupported function %s()", f.name)
+	}
+}
+
+// ---- conversions ----
+
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		if math.IsNaN(x) {
+			return "NaN"
+		}
+		if x == math.Trunc(x) && math.Abs(x) < 1e15 {
+			return strconv.FormatInt(int64(x), 10)
+		}
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case []*Node:
+		if len(x) == 0 {
+			return ""
+		}
+		return x[0].StringValue()
+	default:
+		return ""
+	}
+}
+
+func toNumber(v interface{}) float64 {
+	switch x := v.(type) {
+	case float64:
+		return x
+	case bool:
+		if x {
+			return 1
+		}
+		return 0
+	default:
+		f, err := strconv.ParseFloat(strings.TrimSpace(toString(v)), 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return f
+	}
+}
+
+func toBool(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case float64:
+		return x != 0 && !math.IsNaN(x)
+	case string:
+		return x != ""
+	case []*Node:
+		return len(x) > 0
+	default:
+		return false
+	}
+}
+
+// compareValues applies XPath 1.0 comparison rules, including the existential
+// semantics for node-sets.
+func compareValues(op string, l, r interface{}) bool {
+	if ln, ok := l.([]*Node); ok {
+		for _, n := range ln {
+			if compareValues(op, n.StringValue(), r) {
+				return true
+			}
+		}
+		return false
+	}
+	if rn, ok := r.([]*Node); ok {
+		for _, n := range rn {
+			if compareValues(op, l, n.StringValue()) {
+				return true
+			}
+		}
+		return false
+	}
+	switch op {
+	case "=", "!=":
+		var eq bool
+		_, lb := l.(bool)
+		_, rb := r.(bool)
+		_, lf := l.(float64)
+		_, rf := r.(float64)
+		switch {
+		case lb || rb:
+			eq = toBool(l) == toBool(r)
+		case lf || rf:
+			eq = toNumber(l) == toNumber(r)
+		default:
+			eq = toString(l) == toString(r)
+		}
+		if op == "=" {
+			return eq
+		}
+		return !eq
+	}
+	a, b := toNumber(l), toNumber(r)
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
@@ -0,0 +1,73 @@
+package xpath
+
+import "testing"
+
+const orders = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <m:Orders xmlns:m="urn:shop" total="3">
+      <m:Order id="a1"><m:Price>10.5</m:Price><m:Status>paid</m:Status></m:Order>
+      <m:Order id="a2"><m:Price>4</m:Price><m:Status>open</m:Status></m:Order>
+      <m:Order id="a3"><m:Price>20</m:Price><m:Status>paid</m:Status></m:Order>
+    </m:Orders>
+  </soap:Body>
+</soap:Envelope>`
+
+func TestEval(t *testing.T) {
+	ns := map[string]string{"s": "http://schemas.xmlsoap.org/soap/envelope/", "shop": "urn:shop"}
+	cases := map[string]string{
+		"/s:Envelope/s:Body/shop:Orders/@total":              "3",
+		"//Order[2]/@id":                                     "a2",
+		"//Order[last()]/@id":                                "a3",
+		"//shop:Order[Status='open']/Price":                  "4",
+		"count(//Order[Status='paid'])":                      "2",
+		"sum(//Price)":                                       "34.5",
+		"//Order[Price > 10 and @id != 'a1']/@id":            "a3",
+		"string(//Order[1]/Status)":                          "paid",
+		"local-name(/*)":                                     "Envelope",
+		"name(//Orders)":                                     "m:Orders",
+		"//Order[contains(@id, '3')]/Price/text()":           "20",
+		"normalize-space(' a  b ')":                          "a b",
+		"//Order[@id='a1']/following-sibling::*[1]/@id":      "a2",
+		"//Status[.='open']/../@id":                          "a2",
+		"count(//Order[@id='a1'] | //Order[@id='a3'])":       "2",
+		"//Order[@id='a3']/preceding-sibling::Order[1]/@id":  "a2",
+		"//Order[@id='a3']/preceding-sibling::*[last()]/@id": "a1",
+		"//Order[@id='a3']/preceding-sibling::*/@id":         "a1",
+		"//Price[.='4']/ancestor::*[1]/@id":                  "a2",
+		"local-name(//Price[.='4']/ancestor-or-self::*[3])":  "Orders",
+	}
+	root, err := Parse(orders)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for expr, expect := range cases {
+		res, err := Eval(root, expr, ns)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if res.String() != expect {
+			t.Fatalf("%s = %q, expect %q", expr, res.String(), expect)
+		}
+	}
+}
+
+func TestEvalNamespacesAndMissing(t *testing.T) {
+	root, err := Parse(orders)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	res, err := Eval(root, "//shop:Order", map[string]string{"shop": "urn:other"})
+	if err != nil {
+		t.Fatalf("eval: %v", err)
+	}
+	if res.Exists() {
+		t.Fatalf("prefix bound to another namespace should not match")
+	}
+	if _, err := Eval(root, "//Order[", nil); err == nil {
+		t.Fatalf("expected syntax error")
+	}
+	if _, err := Parse("<a><b></a>"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
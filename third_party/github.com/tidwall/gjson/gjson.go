@@ -1,10 +1,24 @@
+// Package gjson is a small implementation of the gjson API and path syntax
+// (https://github.com/tidwall/gjson/blob/master/SYNTAX.md) on top of
+// encoding/json.
+//
+// Supported: dotted keys with \ escapes and * ? wildcards, array indexes,
+// "#" counts and projections, #(...) and #(...)# queries with
+// == != < <= > >= % !% and nested queries, "|" pipes, @modifiers with
+// arguments, {..} and [..] multipaths, !literals and ".." JSON lines.
+// Unlike upstream, an empty path selects the whole document and the legacy
+// name[n] index form is accepted. Index and Indexes are filled in by Get for
+// values that come straight from the input.
 package gjson
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
+	"unsafe"
 )
 
 type Type int
@@ -18,190 +32,1137 @@ const (
 	True
 )
 
-type Result struct {
-	value interface{}
-	Type  Type
+// String returns a string representation of the type.
+func (t Type) String() string {
+	switch t {
+	case False:
+		return "False"
+	case Number:
+		return "Number"
+	case String:
+		return "String"
+	case JSON:
+		return "JSON"
+	case True:
+		return "True"
+	default:
+		return "Null"
+	}
 }
 
-func (r Result) Exists() bool {
-	return r.value != nil
+// Result represents a json value returned from Get.
+type Result struct {
+	// Type is the json type
+	Type Type
+	// Raw is the raw json
+	Raw string
+	// Str is the json string
+	Str string
+	// Num is the json number
+	Num float64
+	// Index of raw value in original json, zero means index unknown
+	Index int
+	// Indexes of all the elements that match on a path containing the '#'
+	// query character.
+	Indexes []int
+
+	// parts are the raw elements collected by a projection or #(...)#
+	// query, kept so Get can locate them in the input.
+	parts []string
 }
 
-func (r Result) Value() interface{} { return r.value }
+// Exists returns true if value exists, including an explicit null.
+func (t Result) Exists() bool {
+	return t.Type != Null || len(t.Raw) != 0
+}
 
-func (r Result) String() string {
-	switch v := r.value.(type) {
-	case string:
+// Value returns one of these types:
+//
+//	bool, for JSON booleans
+//	float64, for JSON numbers
+//	string, for JSON string literals
+//	nil, for JSON null
+//	map[string]interface{}, for JSON objects
+//	[]interface{}, for JSON arrays
+func (t Result) Value() interface{} {
+	switch t.Type {
+	case False:
+		return false
+	case True:
+		return true
+	case Number:
+		return t.Num
+	case String:
+		return t.Str
+	case JSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(t.Raw), &v); err != nil {
+			return nil
+		}
 		return v
-	case json.Number:
-		return v.String()
-	case float64:
-		return strconv.FormatFloat(v, 'f', -1, 64)
-	case bool:
-		return strconv.FormatBool(v)
 	default:
-		return fmt.Sprint(v)
+		return nil
+	}
+}
+
+// String returns a string representation of the value. Integers keep their
+// raw digits so large ids survive without float rounding.
+func (t Result) String() string {
+	switch t.Type {
+	case False:
+		return "false"
+	case True:
+		return "true"
+	case Number:
+		if isInteger(t.Raw) {
+			return t.Raw
+		}
+		return strconv.FormatFloat(t.Num, 'f', -1, 64)
+	case String:
+		return t.Str
+	case JSON:
+		return t.Raw
+	default:
+		return ""
 	}
 }
 
-func (r Result) Num() float64 {
-	switch v := r.value.(type) {
-	case json.Number:
-		f, _ := v.Float64()
-		return f
-	case float64:
-		return v
-	case int:
-		return float64(v)
-	case int64:
-		return float64(v)
-	case string:
-		f, _ := strconv.ParseFloat(v, 64)
+// Float returns a float64 representation.
+func (t Result) Float() float64 {
+	switch t.Type {
+	case True:
+		return 1
+	case String:
+		f, _ := strconv.ParseFloat(t.Str, 64)
 		return f
+	case Number:
+		return t.Num
 	default:
 		return 0
 	}
 }
 
-func (r Result) Bool() bool {
-	switch v := r.value.(type) {
-	case bool:
-		return v
-	case string:
-		b, _ := strconv.ParseBool(v)
+// Int returns an integer representation.
+func (t Result) Int() int64 {
+	switch t.Type {
+	case True:
+		return 1
+	case String:
+		n, err := strconv.ParseInt(t.Str, 10, 64)
+		if err != nil {
+			f, _ := strconv.ParseFloat(t.Str, 64)
+			return int64(f)
+		}
+		return n
+	case Number:
+		if isInteger(t.Raw) {
+			if n, err := strconv.ParseInt(t.Raw, 10, 64); err == nil {
+				return n
+			}
+		}
+		return int64(t.Num)
+	default:
+		return 0
+	}
+}
+
+// Bool returns a boolean representation.
+func (t Result) Bool() bool {
+	switch t.Type {
+	case True:
+		return true
+	case String:
+		b, _ := strconv.ParseBool(strings.ToLower(t.Str))
 		return b
+	case Number:
+		return t.Num != 0
 	default:
 		return false
 	}
 }
 
+// IsObject returns true if the result value is a JSON object.
+func (t Result) IsObject() bool {
+	return t.Type == JSON && len(t.Raw) > 0 && t.Raw[0] == '{'
+}
+
+// IsArray returns true if the result value is a JSON array.
+func (t Result) IsArray() bool {
+	return t.Type == JSON && len(t.Raw) > 0 && t.Raw[0] == '['
+}
+
+// Array returns back an array of values. If the result represents a null
+// value or is non-existent, then an empty array will be returned. If the
+// result is not a JSON array, the return value will be an array containing
+// one result.
+func (t Result) Array() []Result {
+	if t.Type == Null {
+		return []Result{}
+	}
+	if !t.IsArray() {
+		return []Result{t}
+	}
+	elems, _ := arrayElems(t.Raw)
+	out := make([]Result, len(elems))
+	for i, raw := range elems {
+		out[i] = parseRaw(raw)
+	}
+	return out
+}
+
+// ForEach iterates through values. For objects the key is the member name,
+// for arrays it is an empty result. Returning false stops the iteration.
+func (t Result) ForEach(iterator func(key, value Result) bool) {
+	if !t.Exists() {
+		return
+	}
+	switch {
+	case t.IsObject():
+		entries, _ := objectEntries(t.Raw)
+		for _, e := range entries {
+			if !iterator(stringResult(e.key), parseRaw(e.raw)) {
+				return
+			}
+		}
+	case t.IsArray():
+		elems, _ := arrayElems(t.Raw)
+		for _, raw := range elems {
+			if !iterator(Result{}, parseRaw(raw)) {
+				return
+			}
+		}
+	default:
+		iterator(Result{}, t)
+	}
+}
+
+// Map returns back a map of values. The result should be a JSON object.
+func (t Result) Map() map[string]Result {
+	out := map[string]Result{}
+	if !t.IsObject() {
+		return out
+	}
+	entries, _ := objectEntries(t.Raw)
+	for _, e := range entries {
+		if _, ok := out[e.key]; !ok {
+			out[e.key] = parseRaw(e.raw)
+		}
+	}
+	return out
+}
+
+// Valid returns true if the input is valid json.
 func Valid(data string) bool {
-	var v interface{}
-	return json.Unmarshal([]byte(data), &v) == nil
+	return json.Valid([]byte(data))
 }
 
+// Parse parses the json and returns a result.
 func Parse(data string) Result {
-	var v interface{}
-	if err := json.Unmarshal([]byte(data), &v); err != nil {
-		return Result{Type: Null}
+	data = strings.TrimSpace(data)
+	if !json.Valid([]byte(data)) {
+		return Result{}
 	}
-	return Result{value: v, Type: detectType(v)}
+	return parseRaw(data)
 }
 
+// Get searches json for the specified path. A path starting with ".." treats
+// the input as JSON lines, one document per line, queried like an array.
 func Get(data, path string) Result {
-	root := Parse(data)
-	if root.value == nil {
-		return Result{Type: Null}
-	}
-	parts := splitPath(path)
-	current := root.value
-	for _, p := range parts {
-		switch node := current.(type) {
-		case map[string]interface{}:
-			val, ok := node[p.name]
-			if !ok {
-				return Result{Type: Null}
+	if strings.HasPrefix(path, "..") {
+		var lines []string
+		for _, line := range strings.Split(data, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && json.Valid([]byte(line)) {
+				lines = append(lines, line)
 			}
-			current = selectIndex(val, p.index)
-		case []interface{}:
-			if p.name != "" {
-				return Result{Type: Null}
+		}
+		return eval(parseRaw(rawArray(lines)), path[2:])
+	}
+	res := Parse(data)
+	if !res.Exists() {
+		return Result{}
+	}
+	return fillIndex(data, eval(res, path), 0)
+}
+
+// Get searches the result for the specified path.
+func (t Result) Get(path string) Result {
+	if !t.Exists() {
+		return Result{}
+	}
+	return fillIndex(t.Raw, eval(t, path), t.Index)
+}
+
+// fillIndex sets Index, or Indexes for '#' paths, from where the raw values
+// sit inside json, which must be the text the result was evaluated on.
+func fillIndex(json string, res Result, base int) Result {
+	if res.parts != nil {
+		res.Indexes = make([]int, 0, len(res.parts))
+		for _, part := range res.parts {
+			if off := offsetIn(json, part); off >= 0 {
+				res.Indexes = append(res.Indexes, base+off)
 			}
-			current = selectIndex(node, p.index)
-		default:
-			return Result{Type: Null}
 		}
+		res.parts = nil
+		return res
+	}
+	if off := offsetIn(json, res.Raw); off > 0 {
+		res.Index = base + off
 	}
-	return Result{value: current, Type: detectType(current)}
+	return res
 }
 
-// Get navigates within an existing result using a path.
-func (r Result) Get(path string) Result {
-	if r.value == nil {
-		return Result{Type: Null}
+// offsetIn returns the position of sub inside s when sub is a slice of s,
+// or -1 for values built during evaluation.
+func offsetIn(s, sub string) int {
+	if s == "" || sub == "" {
+		return -1
 	}
-	parts := splitPath(path)
-	current := r.value
-	for _, p := range parts {
-		switch node := current.(type) {
-		case map[string]interface{}:
-			val, ok := node[p.name]
-			if !ok {
-				return Result{Type: Null}
+	start := uintptr(unsafe.Pointer(unsafe.StringData(s)))
+	p := uintptr(unsafe.Pointer(unsafe.StringData(sub)))
+	if p < start || p+uintptr(len(sub)) > start+uintptr(len(s)) {
+		return -1
+	}
+	return int(p - start)
+}
+
+// eval applies path to res one component at a time.
+func eval(res Result, path string) Result {
+	if path == "" {
+		return res
+	}
+	comp, rest, sep := nextComponent(path)
+	switch {
+	case comp == "#":
+		if !res.IsArray() {
+			return Result{}
+		}
+		elems, _ := arrayElems(res.Raw)
+		if rest == "" || sep == '|' {
+			return eval(numberResult(len(elems)), rest)
+		}
+		return project(elems, rest)
+	case strings.HasPrefix(comp, "#("):
+		if !res.IsArray() {
+			return Result{}
+		}
+		query, all, ok := parseQuery(comp)
+		if !ok {
+			return Result{}
+		}
+		elems, _ := arrayElems(res.Raw)
+		var matched []string
+		for _, raw := range elems {
+			if query.matches(parseRaw(raw)) {
+				if !all {
+					return eval(parseRaw(raw), rest)
+				}
+				matched = append(matched, raw)
+			}
+		}
+		if !all {
+			return Result{}
+		}
+		if rest == "" {
+			res := parseRaw(rawArray(matched))
+			res.parts = matched
+			return res
+		}
+		if sep == '|' {
+			return eval(parseRaw(rawArray(matched)), rest)
+		}
+		return project(matched, rest)
+	case strings.HasPrefix(comp, "@"):
+		return eval(applyModifier(res, comp[1:]), rest)
+	case strings.HasPrefix(comp, "!"):
+		lit := strings.TrimSpace(comp[1:])
+		if !json.Valid([]byte(lit)) {
+			return Result{}
+		}
+		return eval(parseRaw(lit), rest)
+	case strings.HasPrefix(comp, "{") || strings.HasPrefix(comp, "["):
+		if mp, ok := multipath(res, comp); ok {
+			return eval(mp, rest)
+		}
+	}
+	return eval(child(res, comp), rest)
+}
+
+// project maps the path before the first pipe over elems, keeping existing
+// results, then applies whatever follows the pipe to the collected array.
+func project(elems []string, path string) Result {
+	each, after := splitPipe(path)
+	out := make([]string, 0, len(elems))
+	parts := []string{}
+	for _, raw := range elems {
+		if r := eval(parseRaw(raw), each); r.Exists() {
+			out = append(out, r.Raw)
+			if r.parts != nil {
+				// nested projections locate their innermost values
+				parts = append(parts, r.parts...)
+			} else {
+				parts = append(parts, r.Raw)
 			}
-			current = selectIndex(val, p.index)
-		case []interface{}:
-			if p.name != "" {
-				return Result{Type: Null}
+		}
+	}
+	res := parseRaw(rawArray(out))
+	if after != "" {
+		return eval(res, after)
+	}
+	res.parts = parts
+	return res
+}
+
+// child selects one key or index.
+func child(res Result, comp string) Result {
+	switch {
+	case res.IsObject():
+		entries, _ := objectEntries(res.Raw)
+		if hasWildcard(comp) {
+			for _, e := range entries {
+				if wildcardMatch(comp, e.key) {
+					return parseRaw(e.raw)
+				}
+			}
+			return Result{}
+		}
+		key := unescape(comp)
+		for _, e := range entries {
+			if e.key == key {
+				return parseRaw(e.raw)
 			}
-			current = selectIndex(node, p.index)
-		default:
-			return Result{Type: Null}
+		}
+		if name, idx, ok := legacyIndex(comp); ok {
+			return indexResult(child(res, name), idx)
+		}
+	case res.IsArray():
+		if idx, err := strconv.Atoi(comp); err == nil && isInteger(comp) {
+			return indexResult(res, idx)
+		}
+		if name, idx, ok := legacyIndex(comp); ok {
+			return indexResult(child(res, name), idx)
 		}
 	}
-	return Result{value: current, Type: detectType(current)}
+	return Result{}
 }
 
-type pathPart struct {
-	name  string
-	index *int
+// legacyIndex splits the name[n] form supported by earlier versions.
+func legacyIndex(comp string) (string, int, bool) {
+	open := strings.LastIndex(comp, "[")
+	if open < 0 || !strings.HasSuffix(comp, "]") {
+		return "", 0, false
+	}
+	idx, err := strconv.Atoi(comp[open+1 : len(comp)-1])
+	if err != nil {
+		return "", 0, false
+	}
+	return comp[:open], idx, true
 }
 
-func splitPath(path string) []pathPart {
-	if path == "" {
-		return nil
+func indexResult(res Result, idx int) Result {
+	if !res.IsArray() {
+		return Result{}
+	}
+	elems, _ := arrayElems(res.Raw)
+	if idx < 0 || idx >= len(elems) {
+		return Result{}
+	}
+	return parseRaw(elems[idx])
+}
+
+// nextComponent splits off the first path component. sep is the separator
+// that followed it ('.' or '|'), or 0 at the end of the path.
+func nextComponent(path string) (comp, rest string, sep byte) {
+	i := 0
+	switch {
+	case strings.HasPrefix(path, "!"):
+		i = 1 + jsonValueLen(path[1:])
+	case strings.HasPrefix(path, "@"):
+		colon := strings.IndexAny(path, ":.|")
+		if colon >= 0 && path[colon] == ':' {
+			i = colon + 1
+			if n := jsonValueLen(path[i:]); n > 0 {
+				i += n
+			}
+		}
+	}
+	depth := 0
+	for ; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			i++
+		case '"':
+			if depth > 0 {
+				i = skipString(path, i)
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '.', '|':
+			if depth <= 0 {
+				return path[:i], path[i+1:], c
+			}
+		}
+	}
+	return path, "", 0
+}
+
+// splitPipe splits path at its first top-level pipe.
+func splitPipe(path string) (string, string) {
+	consumed := 0
+	rest := path
+	for rest != "" {
+		comp, next, sep := nextComponent(rest)
+		consumed += len(comp)
+		if sep == '|' {
+			return path[:consumed], next
+		}
+		if sep == 0 {
+			break
+		}
+		consumed++
+		rest = next
+	}
+	return path, ""
+}
+
+// skipString returns the index of the closing quote of the string at i.
+func skipString(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j
+		}
+	}
+	return len(s) - 1
+}
+
+// jsonValueLen returns the length of the JSON value at the start of s, or 0.
+func jsonValueLen(s string) int {
+	dec := json.NewDecoder(strings.NewReader(s))
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return 0
+	}
+	return int(dec.InputOffset())
+}
+
+// query is a parsed #(...) condition.
+type query struct {
+	path  string
+	op    string
+	value Result
+	raw   string
+}
+
+var queryOps = []string{"==", "!=", "<=", ">=", "!%", "=", "<", ">", "%"}
+
+func parseQuery(comp string) (query, bool, bool) {
+	all := strings.HasSuffix(comp, ")#")
+	body := strings.TrimSuffix(comp, "#")
+	if !strings.HasSuffix(body, ")") {
+		return query{}, false, false
+	}
+	body = body[2 : len(body)-1]
+	depth := 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+			continue
+		case '"':
+			if depth > 0 {
+				i = skipString(body, i)
+			}
+			continue
+		case '(', '[', '{':
+			depth++
+			continue
+		case ')', ']', '}':
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+		for _, op := range queryOps {
+			if strings.HasPrefix(body[i:], op) {
+				q := query{path: strings.TrimSpace(body[:i]), op: op}
+				q.raw = strings.TrimSpace(body[i+len(op):])
+				if json.Valid([]byte(q.raw)) {
+					q.value = parseRaw(q.raw)
+				} else {
+					// bare words compare as strings
+					q.value = stringResult(q.raw)
+				}
+				if op == "=" {
+					q.op = "=="
+				}
+				return q, all, true
+			}
+		}
+	}
+	return query{path: strings.TrimSpace(body)}, all, true
+}
+
+func (q query) matches(elem Result) bool {
+	lhs := elem
+	if q.path != "" {
+		lhs = eval(elem, q.path)
+	}
+	if q.op == "" {
+		return lhs.Exists()
 	}
-	rawParts := strings.Split(path, ".")
-	parts := make([]pathPart, 0, len(rawParts))
-	for _, rp := range rawParts {
-		if rp == "" {
+	if !lhs.Exists() {
+		return false
+	}
+	rhs := q.value
+	switch rhs.Type {
+	case String:
+		if lhs.Type != String {
+			return false
+		}
+		switch q.op {
+		case "%":
+			return wildcardMatch(rhs.Str, lhs.Str)
+		case "!%":
+			return !wildcardMatch(rhs.Str, lhs.Str)
+		}
+		return compareOrdered(q.op, strings.Compare(lhs.Str, rhs.Str))
+	case Number:
+		if lhs.Type != Number {
+			return false
+		}
+		c := 0
+		if lhs.Num < rhs.Num {
+			c = -1
+		} else if lhs.Num > rhs.Num {
+			c = 1
+		}
+		return compareOrdered(q.op, c)
+	case True, False:
+		if lhs.Type != True && lhs.Type != False {
+			return false
+		}
+		c := 0
+		if lhs.Type != rhs.Type {
+			c = 1
+			if lhs.Type == False {
+				c = -1
+			}
+		}
+		return compareOrdered(q.op, c)
+	case Null:
+		return compareOrdered(q.op, boolCmp(lhs.Type == Null))
+	default:
+		return compareOrdered(q.op, boolCmp(compact(lhs.Raw) == compact(rhs.Raw)))
+	}
+}
+
+func boolCmp(equal bool) int {
+	if equal {
+		return 0
+	}
+	return 1
+}
+
+func compareOrdered(op string, c int) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// multipath builds a new object or array from the comma separated paths in
+// comp, each evaluated against res. Missing values are left out.
+func multipath(res Result, comp string) (Result, bool) {
+	isObject := comp[0] == '{'
+	closer := byte('}')
+	if !isObject {
+		closer = ']'
+	}
+	if comp[len(comp)-1] != closer {
+		return Result{}, false
+	}
+	var keys, raws []string
+	for _, item := range splitTopLevel(comp[1:len(comp)-1], ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
 			continue
 		}
-		part := pathPart{name: rp}
-		if strings.Contains(rp, "[") && strings.HasSuffix(rp, "]") {
-			name := rp[:strings.Index(rp, "[")]
-			idxStr := rp[strings.Index(rp, "[")+1 : len(rp)-1]
-			if idxStr != "" {
-				if idx, err := strconv.Atoi(idxStr); err == nil {
-					part.name = name
-					part.index = &idx
+		key := ""
+		if isObject && strings.HasPrefix(item, `"`) {
+			end := skipString(item, 0)
+			if rest := strings.TrimSpace(item[end+1:]); strings.HasPrefix(rest, ":") {
+				if err := json.Unmarshal([]byte(item[:end+1]), &key); err != nil {
+					return Result{}, false
 				}
+				item = strings.TrimSpace(rest[1:])
+			}
+		}
+		r := eval(res, item)
+		if !r.Exists() {
+			continue
+		}
+		if key == "" {
+			key = lastKey(item)
+		}
+		keys = append(keys, key)
+		raws = append(raws, r.Raw)
+	}
+	if isObject {
+		return parseRaw(rawObject(keys, raws)), true
+	}
+	return parseRaw(rawArray(raws)), true
+}
+
+// lastKey names a multipath member after the last component of its path.
+func lastKey(path string) string {
+	key := path
+	for rest := path; rest != ""; {
+		comp, next, _ := nextComponent(rest)
+		key, rest = comp, next
+	}
+	if strings.HasPrefix(key, "!") {
+		return strings.TrimSpace(key[1:])
+	}
+	return unescape(key)
+}
+
+func splitTopLevel(s string, sep byte) []string {
+	var out []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			i = skipString(s, i)
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, s[start:])
+}
+
+// applyModifier runs an @name[:args] modifier.
+func applyModifier(res Result, spec string) Result {
+	name, arg, _ := strings.Cut(spec, ":")
+	opts := Parse(arg)
+	switch name {
+	case "this":
+		return res
+	case "valid":
+		if res.Exists() && json.Valid([]byte(res.Raw)) {
+			return res
+		}
+		return Result{}
+	case "reverse":
+		switch {
+		case res.IsArray():
+			elems, _ := arrayElems(res.Raw)
+			for i, j := 0, len(elems)-1; i < j; i, j = i+1, j-1 {
+				elems[i], elems[j] = elems[j], elems[i]
+			}
+			return parseRaw(rawArray(elems))
+		case res.IsObject():
+			entries, _ := objectEntries(res.Raw)
+			keys, raws := make([]string, len(entries)), make([]string, len(entries))
+			for i, e := range entries {
+				keys[len(entries)-1-i], raws[len(entries)-1-i] = e.key, e.raw
+			}
+			return parseRaw(rawObject(keys, raws))
+		}
+		return res
+	case "ugly":
+		if !res.Exists() {
+			return res
+		}
+		return parseRaw(compact(res.Raw))
+	case "pretty":
+		if !res.Exists() {
+			return res
+		}
+		raw := compact(res.Raw)
+		if opts.Get("sortKeys").Bool() {
+			raw = sortKeys(raw)
+		}
+		indent := "  "
+		if v := opts.Get("indent"); v.Type == String {
+			indent = v.Str
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(raw), opts.Get("prefix").Str, indent); err != nil {
+			return res
+		}
+		return parseRaw(buf.String())
+	case "flatten":
+		if !res.IsArray() {
+			return res
+		}
+		return parseRaw(rawArray(flatten(res.Raw, opts.Get("deep").Bool())))
+	case "join":
+		if !res.IsArray() {
+			return res
+		}
+		preserve := opts.Get("preserve").Bool()
+		var keys, raws []string
+		pos := map[string]int{}
+		elems, _ := arrayElems(res.Raw)
+		for _, raw := range elems {
+			entries, ok := objectEntries(raw)
+			if !ok {
+				continue
+			}
+			for _, e := range entries {
+				if i, seen := pos[e.key]; seen && !preserve {
+					raws[i] = e.raw
+					continue
+				}
+				pos[e.key] = len(keys)
+				keys = append(keys, e.key)
+				raws = append(raws, e.raw)
+			}
+		}
+		return parseRaw(rawObject(keys, raws))
+	case "keys", "values":
+		if !res.IsObject() {
+			if res.IsArray() && name == "values" {
+				return res
+			}
+			return parseRaw("[]")
+		}
+		entries, _ := objectEntries(res.Raw)
+		out := make([]string, len(entries))
+		for i, e := range entries {
+			if name == "keys" {
+				out[i] = quote(e.key)
+			} else {
+				out[i] = e.raw
+			}
+		}
+		return parseRaw(rawArray(out))
+	case "tostr":
+		if !res.Exists() {
+			return res
+		}
+		return parseRaw(quote(res.Raw))
+	case "fromstr":
+		if res.Type != String || !json.Valid([]byte(res.Str)) {
+			return Result{}
+		}
+		return parseRaw(strings.TrimSpace(res.Str))
+	case "group":
+		if !res.IsObject() {
+			return Result{}
+		}
+		var rows [][2][]string
+		entries, _ := objectEntries(res.Raw)
+		for _, e := range entries {
+			elems, ok := arrayElems(e.raw)
+			if !ok {
+				continue
+			}
+			for i, raw := range elems {
+				for len(rows) <= i {
+					rows = append(rows, [2][]string{})
+				}
+				rows[i][0] = append(rows[i][0], e.key)
+				rows[i][1] = append(rows[i][1], raw)
+			}
+		}
+		out := make([]string, len(rows))
+		for i, row := range rows {
+			out[i] = rawObject(row[0], row[1])
+		}
+		return parseRaw(rawArray(out))
+	case "dig":
+		key := arg
+		if opts.Type == String {
+			key = opts.Str
+		}
+		var out []string
+		dig(res.Raw, key, &out)
+		return parseRaw(rawArray(out))
+	}
+	return Result{}
+}
+
+func flatten(raw string, deep bool) []string {
+	elems, _ := arrayElems(raw)
+	var out []string
+	for _, e := range elems {
+		if inner, ok := arrayElems(e); ok {
+			if deep {
+				out = append(out, flatten(e, true)...)
+			} else {
+				out = append(out, inner...)
 			}
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// dig collects the values of key at any depth, in document order.
+func dig(raw, key string, out *[]string) {
+	if entries, ok := objectEntries(raw); ok {
+		for _, e := range entries {
+			if e.key == key {
+				*out = append(*out, e.raw)
+			}
+			dig(e.raw, key, out)
+		}
+		return
+	}
+	if elems, ok := arrayElems(raw); ok {
+		for _, e := range elems {
+			dig(e, key, out)
+		}
+	}
+}
+
+func sortKeys(raw string) string {
+	if entries, ok := objectEntries(raw); ok {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+		keys, raws := make([]string, len(entries)), make([]string, len(entries))
+		for i, e := range entries {
+			keys[i], raws[i] = e.key, sortKeys(e.raw)
+		}
+		return rawObject(keys, raws)
+	}
+	if elems, ok := arrayElems(raw); ok {
+		for i := range elems {
+			elems[i] = sortKeys(elems[i])
+		}
+		return rawArray(elems)
+	}
+	return raw
+}
+
+type entry struct {
+	key string
+	raw string
+}
+
+// objectEntries returns the members of a raw object in document order.
+func objectEntries(raw string) ([]entry, bool) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "{") {
+		return nil, false
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, false
+	}
+	var out []entry
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, _ := tok.(string)
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return nil, false
 		}
-		parts = append(parts, part)
+		// slice the input rather than copy so Get can report offsets
+		end := int(dec.InputOffset())
+		out = append(out, entry{key: key, raw: raw[end-len(val) : end]})
 	}
-	return parts
+	return out, true
 }
 
-func selectIndex(value interface{}, idx *int) interface{} {
-	if idx == nil {
-		return value
+// arrayElems returns the raw elements of a raw array.
+func arrayElems(raw string) ([]string, bool) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "[") {
+		return nil, false
 	}
-	switch arr := value.(type) {
-	case []interface{}:
-		if *idx >= 0 && *idx < len(arr) {
-			return arr[*idx]
+	dec := json.NewDecoder(strings.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, false
+	}
+	out := []string{}
+	for dec.More() {
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return nil, false
 		}
+		end := int(dec.InputOffset())
+		out = append(out, raw[end-len(val):end])
 	}
-	return nil
+	return out, true
 }
 
-func detectType(v interface{}) Type {
-	switch v := v.(type) {
-	case nil:
-		return Null
-	case bool:
-		if v {
-			return True
+func parseRaw(raw string) Result {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Result{}
+	}
+	switch raw[0] {
+	case '{', '[':
+		return Result{Type: JSON, Raw: raw}
+	case '"':
+		var s string
+		if err := json.Unmarshal([]byte(raw), &s); err != nil {
+			return Result{}
 		}
-		return False
-	case json.Number, float64, int, int64:
-		return Number
-	case string:
-		return String
-	case map[string]interface{}, []interface{}:
-		return JSON
+		return Result{Type: String, Raw: raw, Str: s}
+	case 't':
+		return Result{Type: True, Raw: raw}
+	case 'f':
+		return Result{Type: False, Raw: raw}
+	case 'n':
+		return Result{Type: Null, Raw: raw}
 	default:
-		return JSON
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return Result{}
+		}
+		return Result{Type: Number, Raw: raw, Num: f}
+	}
+}
+
+func numberResult(n int) Result {
+	return Result{Type: Number, Raw: strconv.Itoa(n), Num: float64(n)}
+}
+
+func stringResult(s string) Result {
+	return Result{Type: String, Raw: quote(s), Str: s}
+}
+
+func rawArray(raws []string) string {
+	return "[" + strings.Join(raws, ",") + "]"
+}
+
+func rawObject(keys, raws []string) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(quote(keys[i]))
+		sb.WriteByte(':')
+		sb.WriteString(raws[i])
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func compact(raw string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(raw)); err != nil {
+		return raw
+	}
+	return buf.String()
+}
+
+func isInteger(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func hasWildcard(comp string) bool {
+	for i := 0; i < len(comp); i++ {
+		switch comp[i] {
+		case '\\':
+			i++
+		case '*', '?':
+			return true
+		}
+	}
+	return false
+}
+
+func unescape(comp string) string {
+	if !strings.Contains(comp, `\`) {
+		return comp
+	}
+	var sb strings.Builder
+	for i := 0; i < len(comp); i++ {
+		if comp[i] == '\\' && i+1 < len(comp) {
+			i++
+		}
+		sb.WriteByte(comp[i])
+	}
+	return sb.String()
+}
+
+// wildcardMatch matches s against a pattern where * is any run of
+// characters, ? is one character and \ escapes the next character.
+func wildcardMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if wildcardMatch(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(s)
+			pattern, s = pattern[1:], s[size:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
 	}
+	return s == ""
 }
@@ -0,0 +1,176 @@
+package gjson
+
+import "testing"
+
+const doc = `{
+  "name": {"first": "Tom", "last": "Anderson"},
+  "age": 37,
+  "children": ["Sara", "Alex", "Jack"],
+  "fav.movie": "Deer Hunter",
+  "deleted": null,
+  "id": 1234567890123456789,
+  "friends": [
+    {"first": "Dale", "last": "Murphy", "age": 44, "nets": ["ig", "fb", "tw"]},
+    {"first": "Roger", "last": "Craig", "age": 68, "nets": ["fb", "tw"]},
+    {"first": "Jane", "last": "Murphy", "age": 47, "nets": ["ig", "tw"]}
+  ]
+}`
+
+func TestPathSyntax(t *testing.T) {
+	cases := []struct{ path, want string }{
+		{"name.last", "Anderson"},
+		{"age", "37"},
+		{"children", `["Sara", "Alex", "Jack"]`},
+		{"children.#", "3"},
+		{"children.1", "Alex"},
+		{"child*.2", "Jack"},
+		{"c?ildren.0", "Sara"},
+		{`fav\.movie`, "Deer Hunter"},
+		{"friends.#.first", `["Dale","Roger","Jane"]`},
+		{"friends.1.last", "Craig"},
+		{"friends[1].last", "Craig"},
+		{"id", "1234567890123456789"},
+		{`friends.#(last=="Murphy").first`, "Dale"},
+		{`friends.#(last=="Murphy")#.first`, `["Dale","Jane"]`},
+		{`friends.#(last="Murphy")#|#`, "2"},
+		{`friends.#(age>45)#.last`, `["Craig","Murphy"]`},
+		{`friends.#(age<=44).first`, "Dale"},
+		{`friends.#(first%"D*").last`, "Murphy"},
+		{`friends.#(first!%"D*").last`, "Craig"},
+		{`friends.#(nets.#(=="fb"))#.first`, `["Dale","Roger"]`},
+		{`friends.#(nets)#|#`, "3"},
+		{"friends.#.nets.#", "[3,2,2]"},
+		{"friends.#.missing", "[]"},
+		{"friends.#.first|1", "Roger"},
+		{"children|@reverse", `["Jack","Alex","Sara"]`},
+		{"children|@reverse|0", "Jack"},
+		{"children.@reverse.0", "Jack"},
+		{"name|@keys", `["first","last"]`},
+		{"name|@values", `["Tom","Anderson"]`},
+		{"name|@reverse", `{"last":"Anderson","first":"Tom"}`},
+		{"name|@ugly", `{"first":"Tom","last":"Anderson"}`},
+		{"name|@tostr", `{"first": "Tom", "last": "Anderson"}`},
+		{`@dig:first`, `["Tom","Dale","Roger","Jane"]`},
+		{`{name.first,age,"the_murphys":friends.#(last="Murphy")#.first}`, `{"first":"Tom","age":37,"the_murphys":["Dale","Jane"]}`},
+		{`[name.first,age,nothing]`, `["Tom",37]`},
+		{`{name.first,"alive":!true}`, `{"first":"Tom","alive":true}`},
+		{`!"a.b"`, "a.b"},
+		{"friends.#.{first,age}", `[{"first":"Dale","age":44},{"first":"Roger","age":68},{"first":"Jane","age":47}]`},
+	}
+	for _, tc := range cases {
+		if got := Get(doc, tc.path).String(); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestMissingAndNull(t *testing.T) {
+	for _, path := range []string{"nothing", "name.middle", "children.5", "children.#(==\"Bob\")", "age.#", "name.#", `friends.#(age>100).first`} {
+		if res := Get(doc, path); res.Exists() {
+			t.Errorf("%s: expected missing, got %s", path, res.Raw)
+		}
+	}
+	res := Get(doc, "deleted")
+	if !res.Exists() || res.Type != Null || res.Value() != nil || res.String() != "" {
+		t.Fatalf("expected existing null, got %+v", res)
+	}
+	if res := Get(doc, ""); res.Type != JSON || !res.IsObject() {
+		t.Fatalf("empty path should select the document, got %+v", res)
+	}
+}
+
+func TestModifiers(t *testing.T) {
+	cases := []struct{ json, path, want string }{
+		{`[1,[2],[3,[4,5]]]`, "@flatten", `[1,2,3,[4,5]]`},
+		{`[1,[2],[3,[4,5]]]`, `@flatten:{"deep":true}`, `[1,2,3,4,5]`},
+		{`[{"first":"Tom","age":37},{"age":41}]`, "@join", `{"first":"Tom","age":41}`},
+		{`[{"first":"Tom","age":37},{"age":41}]`, `@join:{"preserve":true}`, `{"first":"Tom","age":37,"age":41}`},
+		{`{"id":["123","456","789"],"val":[2,1]}`, "@group", `[{"id":"123","val":2},{"id":"456","val":1},{"id":"789"}]`},
+		{`{"b":1,"a":[{"d":1,"c":2}]}`, `@pretty:{"sortKeys":true}`, "{\n  \"a\": [\n    {\n      \"c\": 2,\n      \"d\": 1\n    }\n  ],\n  \"b\": 1\n}"},
+		{`{"a": 1}`, `@pretty:{"indent":"\t"}`, "{\n\t\"a\": 1\n}"},
+		{`{"data":"{\"id\":7}"}`, "data|@fromstr|id", "7"},
+		{`{"id":7}`, "@this.id", "7"},
+		{`{"id":7}`, "@valid.id", "7"},
+	}
+	for _, tc := range cases {
+		if got := Get(tc.json, tc.path).String(); got != tc.want {
+			t.Errorf("%s on %s: got %q, want %q", tc.path, tc.json, got, tc.want)
+		}
+	}
+	if res := Get(`{"a":1}`, "@unknown"); res.Exists() {
+		t.Fatalf("unknown modifier should not exist, got %s", res.Raw)
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	lines := `{"name": "Gilbert", "age": 61}
+{"name": "Alexa", "age": 34}
+{"name": "May", "age": 57}
+{"name": "Deloise", "age": 44}`
+	cases := []struct{ path, want string }{
+		{"..#", "4"},
+		{"..1", `{"name": "Alexa", "age": 34}`},
+		{"..#.name", `["Gilbert","Alexa","May","Deloise"]`},
+		{`..#(name="May").age`, "57"},
+	}
+	for _, tc := range cases {
+		if got := Get(lines, tc.path).String(); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestResultAccessors(t *testing.T) {
+	res := Get(doc, "friends.1")
+	if res.Get("age").Int() != 68 || res.Get("first").Str != "Roger" || !res.IsObject() {
+		t.Fatalf("unexpected friend %+v", res)
+	}
+	names := []string{}
+	for _, f := range Get(doc, "friends").Array() {
+		names = append(names, f.Get("first").String())
+	}
+	if len(names) != 3 || names[2] != "Jane" {
+		t.Fatalf("unexpected names %v", names)
+	}
+	keys := []string{}
+	Get(doc, "name").ForEach(func(k, v Result) bool {
+		keys = append(keys, k.String()+"="+v.String())
+		return true
+	})
+	if len(keys) != 2 || keys[0] != "first=Tom" || keys[1] != "last=Anderson" {
+		t.Fatalf("unexpected iteration %v", keys)
+	}
+	m, ok := Get(doc, "name").Value().(map[string]interface{})
+	if !ok || m["first"] != "Tom" {
+		t.Fatalf("unexpected value %#v", Get(doc, "name").Value())
+	}
+	if v, ok := Get(doc, "age").Value().(float64); !ok || v != 37 {
+		t.Fatalf("numbers should decode to float64, got %#v", Get(doc, "age").Value())
+	}
+	if Get(doc, "children.0").Bool() || !Parse(`true`).Bool() || Parse(`"1.5"`).Float() != 1.5 {
+		t.Fatalf("unexpected conversions")
+	}
+	if Valid(`{"a":`) || Parse(`{"a":`).Exists() {
+		t.Fatalf("invalid json should not parse")
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	at := func(i int, raw string) bool { return i > 0 && doc[i:i+len(raw)] == raw }
+	if r := Get(doc, `fav\.movie`); !at(r.Index, `"Deer Hunter"`) {
+		t.Fatalf("unexpected index %d", r.Index)
+	}
+	if r := Get(doc, "friends.1").Get("last"); !at(r.Index, `"Craig"`) {
+		t.Fatalf("nested Get should offset from the parent, got %d", r.Index)
+	}
+	r := Get(doc, `friends.#(last=="Murphy")#.first`)
+	if len(r.Indexes) != 2 || !at(r.Indexes[0], `"Dale"`) || !at(r.Indexes[1], `"Jane"`) {
+		t.Fatalf("unexpected indexes %v", r.Indexes)
+	}
+	if r := Get(doc, "friends.#.nets.#(==\"tw\")#"); len(r.Indexes) != 3 || !at(r.Indexes[2], `"tw"`) {
+		t.Fatalf("nested projections should index innermost values, got %v", r.Indexes)
+	}
+	if r := Get(doc, "children|@reverse"); r.Index != 0 || r.Indexes != nil {
+		t.Fatalf("modified values have no index, got %d %v", r.Index, r.Indexes)
+	}
+}
@@ -1,8 +1,10 @@
 package yaml
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 )
@@ -17,6 +19,7 @@ func Unmarshal(data []byte, out interface{}) error {
 	if value == nil {
 		return nil
 	}
+	value = fitTarget(value, reflect.TypeOf(out))
 	b, err := json.Marshal(value)
 	if err != nil {
 		return err
@@ -34,9 +37,141 @@ type parseResult struct {
 	next  int
 }
 
+// orderedMap keeps mapping keys in document order when re-encoded as JSON, so
+// order-sensitive settings (query parameters, headers) survive Unmarshal.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: map[string]interface{}{}}
+}
+
+func (m *orderedMap) set(key string, val interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = val
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vb, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// flowValue is an unquoted flow collection ([a, b] or {k: v}). It keeps the
+// source text so string-typed targets receive the scalar as written.
+type flowValue struct {
+	raw   string
+	value interface{}
+}
+
+func (f *flowValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.value)
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// fitTarget walks the parsed document alongside the Go type it will be
+// decoded into and turns flow collections back into their source text where
+// a string is expected. A nil t means the target type is unknown.
+func fitTarget(v interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() != reflect.String && reflect.PtrTo(t).Implements(jsonUnmarshaler) {
+		// custom decoders interpret the value themselves
+		t = nil
+	}
+	switch val := v.(type) {
+	case *flowValue:
+		if t != nil && t.Kind() == reflect.String {
+			return val.raw
+		}
+		return fitTarget(val.value, t)
+	case *orderedMap:
+		for _, k := range val.keys {
+			val.values[k] = fitTarget(val.values[k], fieldType(t, k))
+		}
+		return val
+	case []interface{}:
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for i := range val {
+			val[i] = fitTarget(val[i], elem)
+		}
+		return val
+	}
+	return v
+}
+
+// fieldType returns the type the JSON decoder uses for key inside t.
+func fieldType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		var fold reflect.Type
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					if inner := fieldType(ft, key); inner != nil {
+						return inner
+					}
+					continue
+				}
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if name == key {
+				return f.Type
+			}
+			if fold == nil && strings.EqualFold(name, key) {
+				fold = f.Type
+			}
+		}
+		return fold
+	}
+	return nil
+}
+
 func parseBlock(lines []string, start, indent int) (interface{}, int, error) {
 	var mode string
-	obj := map[string]interface{}{}
+	obj := newOrderedMap()
 	arr := []interface{}{}
 	i := start
 	for i < len(lines) {
@@ -102,21 +237,24 @@ func parseBlock(lines []string, start, indent int) (interface{}, int, error) {
 			return nil, i, fmt.Errorf("mixing list and map not supported")
 		}
 		mode = "map"
-		parts := strings.SplitN(trimmed, ":", 2)
-		if len(parts) != 2 {
+		key, valText, ok := splitKey(trimmed)
+		if !ok {
 			return nil, i, fmt.Errorf("invalid line %d", i+1)
 		}
-		key := strings.TrimSpace(parts[0])
-		valText := strings.TrimSpace(parts[1])
 		i++
-		if valText == "" {
-			var err error
-			obj[key], i, err = parseBlock(lines, i, indent+2)
+		if style := valText; style != "" && (style[0] == '|' || style[0] == '>') && len(style) <= 2 {
+			text, next := parseBlockScalar(lines, i, indent, style)
+			obj.set(key, text)
+			i = next
+		} else if valText == "" {
+			val, next, err := parseBlock(lines, i, indent+2)
 			if err != nil {
-				return nil, i, err
+				return nil, next, err
 			}
+			obj.set(key, val)
+			i = next
 		} else {
-			obj[key] = parseScalar(valText)
+			obj.set(key, parseScalar(valText))
 		}
 	}
 
@@ -126,7 +264,114 @@ func parseBlock(lines []string, start, indent int) (interface{}, int, error) {
 	return obj, i, nil
 }
 
+// splitKey separates a mapping line into key and value. Keys may be quoted and
+// may contain colons (e.g. "soap:Body"), as long as they are not followed by a space.
+func splitKey(line string) (string, string, bool) {
+	if line[0] == '"' || line[0] == '\'' {
+		end := strings.IndexByte(line[1:], line[0])
+		if end >= 0 {
+			rest := strings.TrimSpace(line[end+2:])
+			if strings.HasPrefix(rest, ":") {
+				return line[1 : end+1], strings.TrimSpace(rest[1:]), true
+			}
+		}
+	}
+	if idx := strings.Index(line, ": "); idx >= 0 {
+		return strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+2:]), true
+	}
+	if strings.HasSuffix(line, ":") {
+		return strings.TrimSpace(line[:len(line)-1]), "", true
+	}
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block more indented than
+// the parent key, honouring the strip (-) and keep (+) chomping indicators.
+func parseBlockScalar(lines []string, start, parentIndent int, style string) (string, int) {
+	i := start
+	blockIndent := -1
+	var body []string
+	for i < len(lines) {
+		line := strings.TrimRight(lines[i], "\r")
+		if strings.TrimSpace(line) == "" {
+			body = append(body, "")
+			i++
+			continue
+		}
+		ind := countIndent(line)
+		if ind <= parentIndent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = ind
+		}
+		if ind < blockIndent {
+			break
+		}
+		body = append(body, line[blockIndent:])
+		i++
+	}
+	trailing := 0
+	for len(body) > 0 && body[len(body)-1] == "" {
+		body = body[:len(body)-1]
+		trailing++
+	}
+	var text string
+	if style[0] == '>' {
+		var sb strings.Builder
+		for j, ln := range body {
+			if j > 0 {
+				if ln == "" || body[j-1] == "" {
+					sb.WriteString("\n")
+				} else {
+					sb.WriteString(" ")
+				}
+			}
+			sb.WriteString(ln)
+		}
+		text = sb.String()
+	} else {
+		text = strings.Join(body, "\n")
+	}
+	switch {
+	case strings.HasSuffix(style, "-"):
+	case strings.HasSuffix(style, "+"):
+		text += "\n" + strings.Repeat("\n", trailing)
+	default:
+		if len(body) > 0 {
+			text += "\n"
+		}
+	}
+	return text, i
+}
+
 func parseScalar(val string) interface{} {
+	if strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+		items := splitFlow(val[1 : len(val)-1])
+		arr := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			arr = append(arr, parseScalar(item))
+		}
+		return &flowValue{raw: val, value: arr}
+	}
+	// "{{var}}" is a template placeholder, not a flow mapping
+	if strings.HasPrefix(val, "{") && !strings.HasPrefix(val, "{{") && strings.HasSuffix(val, "}") {
+		obj := newOrderedMap()
+		for _, item := range splitFlow(val[1 : len(val)-1]) {
+			parts := strings.SplitN(item, ":", 2)
+			key := strings.Trim(strings.TrimSpace(parts[0]), `"'`)
+			var v interface{}
+			if len(parts) == 2 {
+				v = parseScalar(strings.TrimSpace(parts[1]))
+			}
+			obj.set(key, v)
+		}
+		return &flowValue{raw: val, value: obj}
+	}
 	if strings.HasPrefix(val, "\"") && strings.HasSuffix(val, "\"") {
 		return strings.Trim(val, "\"")
 	}
@@ -152,6 +397,36 @@ func parseScalar(val string) interface{} {
 	return val
 }
 
+// splitFlow splits the inside of a flow collection on top-level commas,
+// respecting quotes and nested brackets.
+func splitFlow(s string) []string {
+	var out []string
+	depth := 0
+	var quote rune
+	start := 0
+	for i, ch := range s {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '{':
+			depth++
+		case ch == ']' || ch == '}':
+			depth--
+		case ch == ',' && depth == 0:
+			out = append(out, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		out = append(out, last)
+	}
+	return out
+}
+
 func countIndent(s string) int {
 	count := 0
 	for _, ch := range s {